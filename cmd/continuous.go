@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/recorder"
+	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// recordContinuous records and transcribes takes until the session ends,
+// appending every transcript to --transcript-file. The model stays loaded
+// and the recording tool keeps running between takes, a take is transcribed
+// while the next one records.
+func recordContinuous(ctx context.Context, rec *recorder.Recorder, trans *transcriber.FileTranscriber, keys <-chan rune, status *statusLine) error {
+	var out io.Writer
+	if transcriptFile != "" {
+		f, err := os.OpenFile(transcriptFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open transcript file: %w", err)
+		}
+		defer f.Close()
+		out = f
+		fmt.Printf("Appending transcripts to: %s\n", transcriptFile)
+	}
+
+	rec.SetContinuous(true)
+	if liveCaptions {
+		rec.Frames()
+	}
+	if err := rec.StartRecording(); err != nil {
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	// A failing transcription ends the session
+	session, cancel := context.WithCancel(ctx)
+	defer cancel()
+	worker := startTakeWorker(trans, status, out, cancel)
+
+	fmt.Println("Press ENTER or s to end a take, p to pause or resume, d to discard it, q or Ctrl+C to finish.")
+	take := <-rec.Takes()
+	var live *liveTranscription
+	if take.Frames != nil {
+		live = startLive(trans, take.Frames)
+	}
+	stopped := false
+	var err error
+	for number := 1; ; number++ {
+		fmt.Printf("\nTake %d: recording...\n", number)
+		var liveDone <-chan struct{}
+		if live != nil {
+			liveDone = live.done
+		}
+
+		end := runTake(session, rec, take, keys, status, liveDone)
+		switch {
+		case end.last:
+			_, err = rec.StopRecording()
+			stopped = true
+		case end.discarded:
+			rec.DiscardTake(take)
+		default:
+			rec.EndTake(take)
+		}
+		<-take.Done()
+
+		// The next take records while this one is transcribed, its frames
+		// are held meanwhile
+		next, more := <-rec.Takes()
+		var nextFrames <-chan []float32
+		if more && next.Frames != nil {
+			nextFrames = bufferFrames(next.Frames)
+		}
+
+		printStopReason(take)
+		switch {
+		case end.discarded:
+			if live != nil {
+				live.abort()
+			}
+			fmt.Printf("Take %d discarded.\n", number)
+		case live != nil:
+			if segments, liveErr := live.wait(); liveErr != nil {
+				worker.fail(fmt.Errorf("transcription failed: %w", liveErr))
+			} else {
+				worker.print(take.Started, whisper.JoinSegments(segments))
+			}
+		default:
+			worker.add(number, take)
+		}
+
+		if !more || session.Err() != nil {
+			break
+		}
+		take, live = next, nil
+		if nextFrames != nil {
+			live = startLive(trans, nextFrames)
+		}
+	}
+
+	if !stopped {
+		_, err = rec.StopRecording()
+	}
+	if workerErr := worker.close(); workerErr != nil {
+		return workerErr
+	}
+	// The recording tool may have been interrupted too, what it recorded is kept
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to stop recording: %w", err)
+	}
+	return nil
+}
+
+// takeWorker transcribes the takes of a continuous session in order, in the
+// background, and prints their transcripts
+type takeWorker struct {
+	trans  *transcriber.FileTranscriber
+	status *statusLine
+	out    io.Writer
+	// cancel ends the session when a take cannot be transcribed
+	cancel func()
+
+	takes chan numberedTake
+	done  chan struct{}
+	// err is the first failure, live takes are printed from the session
+	mu  sync.Mutex
+	err error
+}
+
+// numberedTake is a take waiting to be transcribed
+type numberedTake struct {
+	number int
+	take   *recorder.Take
+}
+
+// startTakeWorker starts transcribing the takes added to the worker
+func startTakeWorker(trans *transcriber.FileTranscriber, status *statusLine, out io.Writer, cancel func()) *takeWorker {
+	w := &takeWorker{
+		trans:  trans,
+		status: status,
+		out:    out,
+		cancel: cancel,
+		takes:  make(chan numberedTake, 16),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run transcribes the takes until the worker is closed, after a failure the
+// rest is skipped
+func (w *takeWorker) run() {
+	defer close(w.done)
+	for job := range w.takes {
+		samples := job.take.Samples()
+		if w.failed() != nil || len(samples) == 0 {
+			continue
+		}
+		w.status.println(fmt.Sprintf("Transcribing take %d...", job.number))
+		text, err := w.trans.TranscribeFromSamples(samples)
+		if err != nil {
+			w.fail(fmt.Errorf("transcription failed: %w", err))
+			continue
+		}
+		w.print(job.take.Started, text)
+	}
+}
+
+// add queues a finished take for transcription
+func (w *takeWorker) add(number int, take *recorder.Take) {
+	w.takes <- numberedTake{number: number, take: take}
+}
+
+// print prints the transcript of a take with its time and appends it to the
+// transcript file
+func (w *takeWorker) print(started time.Time, text string) {
+	if text = strings.TrimSpace(text); text == "" || w.failed() != nil {
+		return
+	}
+	w.status.println(fmt.Sprintf("[%s] %s", started.Format("15:04:05"), text))
+	if w.out != nil {
+		if _, err := fmt.Fprintf(w.out, "[%s] %s\n", started.Format("2006-01-02 15:04:05"), text); err != nil {
+			w.fail(fmt.Errorf("failed to write transcript file: %w", err))
+		}
+	}
+}
+
+// fail records the first error and ends the session
+func (w *takeWorker) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// failed returns the first error
+func (w *takeWorker) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// close waits for the queued takes and returns the first error
+func (w *takeWorker) close() error {
+	close(w.takes)
+	<-w.done
+	return w.failed()
+}
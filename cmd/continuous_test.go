@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/recorder"
+	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWhisper numbers the transcribed takes
+type fakeWhisper struct {
+	mu      sync.Mutex
+	lengths []int
+	fail    int
+}
+
+func (f *fakeWhisper) Transcribe(samples []float32) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lengths = append(f.lengths, len(samples))
+	if len(f.lengths) == f.fail {
+		return "", fmt.Errorf("model failed")
+	}
+	return fmt.Sprintf(" take %d ", len(f.lengths)), nil
+}
+
+func (f *fakeWhisper) TranscribeSegments(samples []float32, language string) ([]whisper.Segment, error) {
+	return nil, nil
+}
+
+func (f *fakeWhisper) DetectLanguage(samples []float32) ([]whisper.LanguageProbability, error) {
+	return nil, nil
+}
+
+func (f *fakeWhisper) Close() {}
+
+// speechTakes is one tone standing in for speech per take, each followed by
+// enough silence to end the take
+func speechTakes(takes int) []float32 {
+	var samples []float32
+	for take := 0; take < takes; take++ {
+		for i := 0; i < 4*16000; i++ {
+			var sample float32
+			if i >= 16000 && i < 2*16000 {
+				sample = float32(0.1 * math.Sin(2*math.Pi*300*float64(i)/16000))
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// runContinuous records the samples continuously, appending to a transcript file
+func runContinuous(t *testing.T, client *fakeWhisper, samples []float32) (string, error) {
+	recorder.EnableTestMode(samples)
+	t.Cleanup(recorder.DisableTestMode)
+	transcriptFile = filepath.Join(t.TempDir(), "transcripts.txt")
+	t.Cleanup(func() { transcriptFile = "" })
+
+	rec := recorder.NewRecorder("")
+	require.NoError(t, rec.SetAutoStop(recorder.AutoStop{Silence: time.Second, WaitForSpeech: true}))
+	trans := transcriber.NewFileTranscriberWithClient(client)
+	err := recordContinuous(context.Background(), rec, trans, nil, nil)
+
+	written, readErr := os.ReadFile(transcriptFile)
+	require.NoError(t, readErr)
+	return string(written), err
+}
+
+func TestRecordContinuous(t *testing.T) {
+	client := &fakeWhisper{}
+	written, err := runContinuous(t, client, speechTakes(3))
+	require.NoError(t, err)
+
+	// Every take is transcribed once, in order, the silence after the last is not
+	lines := strings.Split(strings.TrimSpace(written), "\n")
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		assert.True(t, strings.HasSuffix(line, fmt.Sprintf("] take %d", i+1)), line)
+	}
+	require.Len(t, client.lengths, 3)
+	for _, length := range client.lengths {
+		// The padded speech and the silence ending it
+		assert.InDelta(t, 2.2*16000, length, 1600+480)
+	}
+}
+
+func TestRecordContinuous_TranscriptionFails(t *testing.T) {
+	client := &fakeWhisper{fail: 2}
+	written, err := runContinuous(t, client, speechTakes(3))
+	assert.ErrorContains(t, err, "transcription failed: model failed")
+	assert.Equal(t, 1, strings.Count(written, "\n"))
+	assert.Contains(t, written, "] take 1")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	detectDuration int
+	detectTop      int
+)
+
+// detectCmd represents the detect-language command
+var detectCmd = &cobra.Command{
+	Use:   "detect-language <file>",
+	Short: "Detect the language spoken in an audio file",
+	Long: `Analyse the beginning of the specified audio file and print the most likely
+spoken languages with their probabilities, without transcribing it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if detectDuration <= 0 {
+			return fmt.Errorf("duration must be positive")
+		}
+
+		opts, err := audioOptions()
+		if err != nil {
+			return err
+		}
+
+		// Get the model path
+		modelPath, err := getModelPath()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Detecting language of file: %s\n", args[0])
+		fmt.Printf("Using model: %s\n", getModelInfo())
+
+		// Only the beginning of the file is needed to identify the language
+		opts.MaxDuration = time.Duration(detectDuration) * time.Second
+		samples, err := audio.LoadAudioFileWithOptions(args[0], opts)
+		if err != nil {
+			return fmt.Errorf("failed to load audio file: %w", err)
+		}
+
+		client, err := whisper.NewClient(modelPath, "", numThreads)
+		if err != nil {
+			return fmt.Errorf("failed to load model: %w", err)
+		}
+		defer client.Close()
+
+		languages, err := client.DetectLanguage(samples)
+		if err != nil {
+			return fmt.Errorf("language detection failed: %w", err)
+		}
+		languages = whisper.TopLanguages(languages, detectTop)
+
+		// Print the ranking
+		fmt.Println("\nLanguages:")
+		fmt.Println("----------")
+		for _, lang := range languages {
+			fmt.Printf("%-5s %.4f\n", lang.Language, lang.Probability)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(detectCmd)
+
+	detectCmd.Flags().IntVar(&detectDuration, "duration", 30, "Number of seconds from the beginning of the audio to analyse")
+	detectCmd.Flags().IntVar(&detectTop, "top", 5, "Number of most likely languages to print (0 prints all)")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/piotrjaromin/transcript/internal/recorder"
+	"github.com/spf13/cobra"
+)
+
+// devicesCmd represents the devices command
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List the audio input devices",
+	Long: `List the capture devices of the recording tool, the names select one with
+record --device. By default the first installed tool of parec, arecord and ffmpeg is
+asked, --recorder-backend selects one. sox cannot list its devices.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+
+		backend, devices, err := recorder.ListDevices(recorderBackend)
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "json" {
+			return printJSON(map[string]interface{}{
+				"backend": backend,
+				"devices": devices,
+			})
+		}
+
+		fmt.Printf("Input devices of %s:\n", backend)
+		if len(devices) == 0 {
+			fmt.Println("  none found")
+		}
+		for _, device := range devices {
+			marker := " "
+			if device.Default {
+				marker = "*"
+			}
+			if device.Description == "" {
+				fmt.Printf("%s %s\n", marker, device.Name)
+				continue
+			}
+			fmt.Printf("%s %s\n      %s\n", marker, device.Name, device.Description)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devicesCmd)
+
+	devicesCmd.Flags().StringVar(&outputFormat, "format", "text", "Output format: text or json")
+	devicesCmd.Flags().StringVar(&recorderBackend, "recorder-backend", recorder.BackendAuto, "Recording tool to list the devices of: auto, parec, arecord or ffmpeg")
+}
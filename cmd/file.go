@@ -2,59 +2,417 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/piotrjaromin/transcript/internal/audio"
 	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
 	"github.com/spf13/cobra"
 )
 
 var (
-	filePath string
+	filePath      string
+	multilingual  bool
+	chunkDuration int
+	languages     []string
+
+	refineModelPath string
+	refineThreshold float32
+
+	ensembleModels []string
+
+	vad           bool
+	vadMargin     float64
+	vadMinEnergy  float64
+	vadPadding    time.Duration
+	vadMinSilence time.Duration
+
+	channelSelection string
+	channelLabels    []string
+
+	audioStream string
+
+	rawFormat   string
+	rawRate     int
+	rawChannels int
+
+	tempo float64
+
+	quality bool
 )
 
 // fileCmd represents the file command
 var fileCmd = &cobra.Command{
-	Use:   "file",
+	Use:   "file [path]",
 	Short: "Transcribe an audio file",
-	Long:  `Transcribe the specified audio file to text.`,
+	Long: `Transcribe the specified audio file to text.
+
+The path may be given with --file or as an argument, "-" reads the audio from
+standard input. Headerless PCM is described with --raw-format, --raw-rate and
+--raw-channels.
+
+With --quality the loudness, peak, clipping, signal-to-noise ratio and silence
+of a file are measured before transcribing and reported with the transcript,
+poor audio is flagged with a warning.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			if filePath != "" && filePath != args[0] {
+				return fmt.Errorf("the file path is given both with --file and as an argument")
+			}
+			filePath = args[0]
+		}
 		if filePath == "" {
 			return fmt.Errorf("file path is required")
 		}
-		
-		fmt.Printf("Transcribing file: %s\n", filePath)
-		fmt.Printf("Using model: %s\n", getModelInfo())
-		
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+		opts, err := audioOptions()
+		if err != nil {
+			return err
+		}
+		if opts.Raw, err = rawAudioFormat(cmd); err != nil {
+			return err
+		}
+		if err := audio.CheckTempo(tempo); err != nil {
+			return err
+		}
+		opts.Tempo = tempo
+		channelMode, channel, err := audio.ParseChannels(channelSelection)
+		if err != nil {
+			return err
+		}
+		if channelMode == audio.ChannelsSplit && (multilingual || refineModelPath != "" || len(ensembleModels) > 0) {
+			return fmt.Errorf("--channels split cannot be combined with --multilingual, --refine-model or --ensemble")
+		}
+		if vad && multilingual {
+			return fmt.Errorf("--vad cannot be combined with --multilingual")
+		}
+		if vad {
+			if err := audio.CheckVAD(vadOptions()); err != nil {
+				return err
+			}
+		}
+		allStreams := strings.EqualFold(audioStream, audio.StreamsAll)
+		if allStreams && (channelMode == audio.ChannelsSplit || multilingual || refineModelPath != "" || len(ensembleModels) > 0) {
+			return fmt.Errorf("--audio-stream all cannot be combined with --channels split, --multilingual, --refine-model or --ensemble")
+		}
+		// Piped input can be read only once and raw audio has no streams to probe
+		if (filePath == audio.StdinPath || opts.Raw != nil) && (audioStream != "" || channelMode == audio.ChannelsLoudest) {
+			return fmt.Errorf("--audio-stream and --channels loudest cannot be used with standard input or raw audio")
+		}
+
+		if filePath == audio.StdinPath {
+			fmt.Fprintln(infoWriter(), "Transcribing standard input")
+		} else {
+			fmt.Fprintf(infoWriter(), "Transcribing file: %s\n", filePath)
+		}
+
+		if !allStreams {
+			if opts.Stream, err = selectAudioStream(); err != nil {
+				return fmt.Errorf("failed to load audio file: %w", err)
+			}
+		}
+
+		switch channelMode {
+		case audio.ChannelsSingle:
+			opts.Channel = channel
+		case audio.ChannelsLoudest:
+			if opts.Channel, err = audio.LoudestChannel(filePath, opts); err != nil {
+				return fmt.Errorf("failed to load audio file: %w", err)
+			}
+			fmt.Fprintf(infoWriter(), "Using loudest channel: %d\n", opts.Channel)
+		}
+
+		// Piped input can be read only once, every audio stream is measured separately
+		if quality && filePath != audio.StdinPath && !allStreams {
+			if audioQuality, err = audio.AnalyzeQuality(filePath, opts); err != nil {
+				return fmt.Errorf("failed to load audio file: %w", err)
+			}
+			printQuality(audioQuality)
+		}
+
+		// The ensemble brings its own models
+		if len(ensembleModels) > 0 {
+			return transcribeEnsemble(opts)
+		}
+
+		fmt.Fprintf(infoWriter(), "Using model: %s\n", getModelInfo())
+
 		// Get the model path
 		modelPath, err := getModelPath()
 		if err != nil {
 			return err
 		}
-		
+
+		if refineModelPath != "" {
+			return transcribeTwoPass(modelPath, opts)
+		}
+
 		// Create a transcriber
-		transcriber, err := transcriber.NewFileTranscriber(modelPath, language, numThreads)
+		transcriber, err := newTranscriber(modelPath)
 		if err != nil {
 			return fmt.Errorf("failed to create transcriber: %w", err)
 		}
 		defer transcriber.Close()
-		
+		transcriber.SetAudioOptions(opts)
+
+		if allStreams {
+			return transcribeTracks(transcriber, opts)
+		}
+		if channelMode == audio.ChannelsSplit {
+			return transcribeChannels(transcriber, opts)
+		}
+		if multilingual {
+			return transcribeMultilingual(transcriber, opts)
+		}
+		if vad {
+			return transcribeSpeech(transcriber, opts)
+		}
+
 		// Transcribe the file
 		transcript, err := transcriber.Transcribe(filePath)
 		if err != nil {
 			return fmt.Errorf("transcription failed: %w", err)
 		}
-		
+
 		// Print the transcript
-		fmt.Println("\nTranscript:")
-		fmt.Println("----------")
-		fmt.Println(transcript)
-		
-		return nil
+		return printTranscript(transcript)
 	},
 }
 
+// selectAudioStream resolves --audio-stream, without a selection it points
+// out when the file has several audio streams to choose from
+func selectAudioStream() (int, error) {
+	if filePath == audio.StdinPath || rawFormat != "" {
+		return 0, nil
+	}
+	if audioStream != "" {
+		stream, err := audio.ResolveStream(filePath, audioStream)
+		if err == nil {
+			fmt.Fprintf(infoWriter(), "Using audio stream: %d\n", stream)
+		}
+		return stream, err
+	}
+
+	// Failing to probe is left to the decoder to report
+	tracks, err := audio.ProbeTracks(filePath)
+	if err == nil && len(tracks) > 1 {
+		fmt.Fprintf(infoWriter(), "The file has %d audio streams, select one with --audio-stream:\n", len(tracks))
+		for _, track := range tracks {
+			fmt.Fprintf(infoWriter(), "  %s\n", track)
+		}
+	}
+	return 0, nil
+}
+
+// rawAudioFormat returns the format of headerless PCM input given by the
+// --raw-* flags, nil when the input has a container to detect the format from
+func rawAudioFormat(cmd *cobra.Command) (*audio.RawFormat, error) {
+	if rawFormat == "" {
+		if cmd.Flags().Changed("raw-rate") || cmd.Flags().Changed("raw-channels") {
+			return nil, fmt.Errorf("--raw-rate and --raw-channels need --raw-format")
+		}
+		return nil, nil
+	}
+
+	format := &audio.RawFormat{Encoding: strings.ToLower(rawFormat), SampleRate: rawRate, Channels: rawChannels}
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	return format, nil
+}
+
+// transcribeTracks transcribes every audio stream of the file separately
+func transcribeTracks(trans *transcriber.FileTranscriber, opts audio.Options) error {
+	tracks, err := audio.ProbeTracks(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load audio file: %w", err)
+	}
+	fmt.Fprintf(infoWriter(), "Transcribing %d audio streams separately\n", len(tracks))
+
+	var results []trackTranscript
+	for _, track := range tracks {
+		fmt.Fprintf(infoWriter(), "Audio stream %s\n", track)
+		opts.Stream = track.Number
+		trans.SetAudioOptions(opts)
+
+		result := trackTranscript{Track: track}
+		if quality {
+			if result.Quality, err = audio.AnalyzeQuality(filePath, opts); err != nil {
+				return fmt.Errorf("failed to load audio stream %d: %w", track.Number, err)
+			}
+			printQuality(result.Quality)
+		}
+		if vad {
+			samples, err := audio.LoadAudioFileWithOptions(filePath, opts)
+			if err != nil {
+				return fmt.Errorf("failed to load audio stream %d: %w", track.Number, err)
+			}
+			if result.Segments, err = trans.TranscribeSpeech(samples, vadOptions()); err != nil {
+				return fmt.Errorf("transcription of audio stream %d failed: %w", track.Number, err)
+			}
+			transcriber.OriginalTimeline(result.Segments, opts)
+			result.Transcript = whisper.JoinSegments(result.Segments)
+		} else if result.Transcript, err = trans.Transcribe(filePath); err != nil {
+			return fmt.Errorf("transcription of audio stream %d failed: %w", track.Number, err)
+		}
+		results = append(results, result)
+	}
+
+	return printTracks(results)
+}
+
+// transcribeMultilingual transcribes code-switched audio detecting the language per chunk
+func transcribeMultilingual(trans *transcriber.FileTranscriber, opts audio.Options) error {
+	samples, err := audio.LoadAudioFileWithOptions(filePath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to load audio file: %w", err)
+	}
+
+	segments, err := trans.TranscribeMultilingual(samples, transcriber.MultilingualOptions{
+		ChunkDuration: time.Duration(chunkDuration) * time.Second,
+		Languages:     languages,
+	})
+	if err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	return printSegments(transcriber.OriginalTimeline(segments, opts))
+}
+
+// transcribeChannels transcribes every channel separately and labels the segments by channel
+func transcribeChannels(trans *transcriber.FileTranscriber, opts audio.Options) error {
+	channels, err := audio.LoadChannels(filePath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to load audio file: %w", err)
+	}
+	fmt.Fprintf(infoWriter(), "Transcribing %d channels separately\n", len(channels))
+
+	channelOpts := transcriber.ChannelOptions{Labels: channelLabels}
+	if vad {
+		vadOpts := vadOptions()
+		channelOpts.VAD = &vadOpts
+	}
+
+	segments, err := trans.TranscribeChannels(channels, channelOpts)
+	if err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	return printSegments(transcriber.OriginalTimeline(segments, opts))
+}
+
+// transcribeSpeech transcribes only the speech found by voice activity detection
+func transcribeSpeech(trans *transcriber.FileTranscriber, opts audio.Options) error {
+	samples, err := audio.LoadAudioFileWithOptions(filePath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to load audio file: %w", err)
+	}
+
+	segments, err := trans.TranscribeSpeech(samples, vadOptions())
+	if err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	return printSegments(transcriber.OriginalTimeline(segments, opts))
+}
+
+// addVADFlags adds the flags tuning voice activity detection to the command
+func addVADFlags(cmd *cobra.Command) {
+	defaultVAD := audio.DefaultVADOptions()
+	cmd.Flags().Float64Var(&vadMargin, "vad-margin", defaultVAD.EnergyMargin, "dB above the noise floor a frame must be to count as speech")
+	cmd.Flags().Float64Var(&vadMinEnergy, "vad-min-energy", defaultVAD.MinEnergy, "Lowest frame energy in dBFS counted as speech")
+	cmd.Flags().DurationVar(&vadPadding, "vad-padding", defaultVAD.Padding, "Audio kept before and after every speech region")
+	cmd.Flags().DurationVar(&vadMinSilence, "vad-min-silence", defaultVAD.MinSilence, "Pauses shorter than this do not split speech regions")
+}
+
+// vadOptions returns the voice activity detection settings of the --vad flags
+func vadOptions() audio.VADOptions {
+	opts := audio.DefaultVADOptions()
+	opts.EnergyMargin = vadMargin
+	opts.MinEnergy = vadMinEnergy
+	opts.Padding = vadPadding
+	opts.MinSilence = vadMinSilence
+	return opts
+}
+
+// transcribeTwoPass drafts with the main model and refines uncertain segments
+func transcribeTwoPass(draftModelPath string, opts audio.Options) error {
+	if multilingual || len(modelRoutes) > 0 || vad {
+		return fmt.Errorf("--refine-model cannot be combined with --multilingual, --route or --vad")
+	}
+
+	fmt.Fprintf(infoWriter(), "Refining with model: %s\n", refineModelPath)
+
+	trans, err := transcriber.NewTwoPassTranscriber(draftModelPath, refineModelPath, language, numThreads, refineThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to create transcriber: %w", err)
+	}
+	defer trans.Close()
+	trans.SetAudioOptions(opts)
+
+	segments, err := trans.Transcribe(filePath)
+	if err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	return printSegments(segments)
+}
+
+// transcribeEnsemble runs every ensemble model and votes on the words
+func transcribeEnsemble(opts audio.Options) error {
+	if multilingual || len(modelRoutes) > 0 || refineModelPath != "" || vad {
+		return fmt.Errorf("--ensemble cannot be combined with --multilingual, --route, --refine-model or --vad")
+	}
+
+	var models []transcriber.EnsembleModel
+	for _, name := range ensembleModels {
+		path, err := resolveModel(name)
+		if err != nil {
+			return err
+		}
+		models = append(models, transcriber.EnsembleModel{Name: name, Path: path})
+		fmt.Fprintf(infoWriter(), "Ensemble model %s: %s\n", name, path)
+	}
+
+	trans, err := transcriber.NewEnsembleTranscriber(models, language, numThreads)
+	if err != nil {
+		return fmt.Errorf("failed to create transcriber: %w", err)
+	}
+	defer trans.Close()
+	trans.SetAudioOptions(opts)
+
+	words, err := trans.Transcribe(filePath)
+	if err != nil {
+		return fmt.Errorf("transcription failed: %w", err)
+	}
+
+	return printEnsembleWords(words)
+}
+
 func init() {
 	rootCmd.AddCommand(fileCmd)
-	
-	fileCmd.Flags().StringVarP(&filePath, "file", "f", "", "Path to the audio file to transcribe (required)")
-	fileCmd.MarkFlagRequired("file")
+
+	fileCmd.Flags().StringVarP(&filePath, "file", "f", "", "Path to the audio file to transcribe, - reads standard input (required unless given as an argument)")
+	fileCmd.Flags().StringVar(&outputFormat, "format", "text", "Output format: text or json")
+	fileCmd.Flags().BoolVar(&multilingual, "multilingual", false, "Detect the language per chunk for audio switching between languages")
+	fileCmd.Flags().IntVar(&chunkDuration, "chunk-duration", int(transcriber.DefaultChunkDuration.Seconds()), "Seconds of audio per language detection chunk in multilingual mode")
+	fileCmd.Flags().StringSliceVar(&languages, "languages", nil, "Languages expected in multilingual mode, e.g. pl,en (optional, any language if not provided)")
+	fileCmd.Flags().StringVar(&refineModelPath, "refine-model", "", "Larger model used to re-decode low-confidence segments of the --model draft (optional)")
+	fileCmd.Flags().Float32Var(&refineThreshold, "refine-threshold", transcriber.DefaultRefineThreshold, "Segment confidence (0-1) below which the refine model is used")
+	fileCmd.Flags().StringSliceVar(&ensembleModels, "ensemble", nil, "Models to combine by word voting, as names (small,medium,large) or paths (optional)")
+
+	fileCmd.Flags().BoolVar(&vad, "vad", false, "Transcribe only the speech found by voice activity detection, skipping silence")
+	addVADFlags(fileCmd)
+	fileCmd.Flags().StringVar(&channelSelection, "channels", audio.ChannelsMix, "Channels to transcribe: mix, split (each channel separately, e.g. agent and customer), loudest, left, right or a channel number counting from 1")
+	fileCmd.Flags().StringSliceVar(&channelLabels, "channel-labels", nil, "Speaker labels of the channels in split mode, e.g. agent,customer (default channel 1, channel 2, ...)")
+	fileCmd.Flags().StringVar(&audioStream, "audio-stream", "", "Audio stream of files with several, e.g. movies with commentary or dubbing: a number counting from 1, a language tag such as eng or de, or all to transcribe each separately (default FFmpeg's pick)")
+	fileCmd.Flags().StringVar(&rawFormat, "raw-format", "", "Read headerless PCM in this sample format, e.g. s16le, mulaw or f32le (default detect the format from the content)")
+	fileCmd.Flags().IntVar(&rawRate, "raw-rate", audio.SampleRate, "Sample rate of raw PCM input in Hz")
+	fileCmd.Flags().IntVar(&rawChannels, "raw-channels", 1, "Channel count of raw PCM input")
+	fileCmd.Flags().BoolVar(&quality, "quality", false, "Measure the quality of the audio as recorded and report it before the transcript, decoding the audio once more")
+	fileCmd.Flags().Float64Var(&tempo, "tempo", 1, "Speed the audio up by this factor before transcription to save compute, e.g. 1.25 or 1.5, timestamps refer to the original audio")
 }
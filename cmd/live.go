@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// liveTranscription transcribes a recording in the background while
+// printing its captions
+type liveTranscription struct {
+	printer  *captionPrinter
+	done     chan struct{}
+	segments []whisper.Segment
+	err      error
+	// cancel stops the transcription of a discarded recording
+	cancel context.CancelFunc
+}
+
+// startLive starts transcribing the frames of a recording as they arrive
+func startLive(trans *transcriber.FileTranscriber, frames <-chan []float32) *liveTranscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	live := &liveTranscription{printer: newCaptionPrinter(os.Stdout), done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(live.done)
+		live.segments, live.err = trans.TranscribeLive(ctx, frames, transcriber.DefaultLiveOptions(), live.printer.update)
+	}()
+	return live
+}
+
+// wait returns the final transcript once the recording has ended
+func (l *liveTranscription) wait() ([]whisper.Segment, error) {
+	<-l.done
+	l.cancel()
+	l.printer.finish()
+	return l.segments, l.err
+}
+
+// abort stops the transcription of a discarded recording, the rest of its
+// audio is not transcribed and the tentative caption is cleared. The
+// recording must end too.
+func (l *liveTranscription) abort() {
+	l.cancel()
+	<-l.done
+	l.printer.abort()
+}
+
+// bufferFrames passes the frames on, holding them for as long as the receiver
+// is busy so the recording never waits for it
+func bufferFrames(in <-chan []float32) <-chan []float32 {
+	out := make(chan []float32)
+	go func() {
+		defer close(out)
+		var queue [][]float32
+		for in != nil || len(queue) > 0 {
+			// Sending is only enabled with a frame to send
+			var send chan []float32
+			var next []float32
+			if len(queue) > 0 {
+				send, next = out, queue[0]
+			}
+			select {
+			case frame, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				queue = append(queue, frame)
+			case send <- next:
+				queue = queue[1:]
+			}
+		}
+	}()
+	return out
+}
+
+// captionPrinter prints live captions. On a terminal the last line is redrawn
+// with the tentative text dimmed, and committed text stays once it fills a
+// line; otherwise only the committed text is printed as it grows.
+type captionPrinter struct {
+	out    io.Writer
+	redraw bool
+	width  int
+	// committed is the latest committed text, of which printed bytes were
+	// printed for good
+	committed string
+	printed   int
+}
+
+// newCaptionPrinter creates a printer for out, as wide as COLUMNS says the
+// terminal is
+func newCaptionPrinter(out *os.File) *captionPrinter {
+	p := &captionPrinter{out: out, redraw: isTerminal(out), width: 80}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 1 {
+		p.width = columns
+	}
+	return p
+}
+
+// update shows the caption, whose committed text continues the previous one
+func (p *captionPrinter) update(caption transcriber.Caption) {
+	p.committed = caption.Committed
+	committed := caption.Committed[min(p.printed, len(caption.Committed)):]
+	if !p.redraw {
+		if committed != "" {
+			fmt.Fprint(p.out, committed)
+			p.printed += len(committed)
+		}
+		return
+	}
+
+	// Full lines of committed text no longer change, the cursor stays on the
+	// last column free
+	for {
+		trimmed := strings.TrimLeft(committed, " ")
+		p.printed += len(committed) - len(trimmed)
+		committed = trimmed
+		if utf8.RuneCountInString(committed) < p.width {
+			break
+		}
+		line := breakLine(committed, p.width-1)
+		fmt.Fprintf(p.out, "\r\033[K%s\n", line)
+		p.printed += len(line)
+		committed = committed[len(line):]
+	}
+
+	tentative := caption.Tentative
+	if room := p.width - 1 - utf8.RuneCountInString(committed); tentative != "" && room > 2 {
+		if committed != "" {
+			tentative = " " + tentative
+		}
+		// The newest words are kept when the line is full
+		if runes := []rune(tentative); len(runes) > room {
+			tentative = "…" + string(runes[len(runes)-room+1:])
+		}
+		tentative = "\033[2m" + tentative + "\033[0m"
+	} else {
+		tentative = ""
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s%s", committed, tentative)
+}
+
+// finish ends the captions with the rest of the committed text
+func (p *captionPrinter) finish() {
+	if p.redraw {
+		p.update(transcriber.Caption{Committed: p.committed})
+	}
+	fmt.Fprintln(p.out)
+}
+
+// abort ends the captions of a discarded recording, clearing the line which
+// is still redrawn
+func (p *captionPrinter) abort() {
+	if p.redraw {
+		fmt.Fprint(p.out, "\r\033[K")
+	} else if p.printed > 0 {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// breakLine returns the start of text which fits in width runes, broken
+// after a word where there is one
+func breakLine(text string, width int) string {
+	end := len(text)
+	if runes := []rune(text); len(runes) > width {
+		end = len(string(runes[:width]))
+	}
+	if end == len(text) || text[end] == ' ' {
+		return text[:end]
+	}
+	if space := strings.LastIndex(text[:end], " "); space > 0 {
+		return text[:space]
+	}
+	return text[:end]
+}
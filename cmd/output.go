@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+var (
+	outputFormat string
+	// audioQuality is the quality of the transcribed input, reported along
+	// with the transcript when it was measured
+	audioQuality *audio.QualityReport
+)
+
+// infoWriter returns where progress messages go, JSON output keeps stdout clean
+func infoWriter() io.Writer {
+	if outputFormat == "json" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// validateOutputFormat checks the --format flag
+func validateOutputFormat() error {
+	switch outputFormat {
+	case "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format '%s', use text or json", outputFormat)
+	}
+}
+
+// printTranscript prints a plain transcript in the selected output format
+func printTranscript(transcript string) error {
+	if outputFormat == "json" {
+		return printJSON(withQuality(map[string]interface{}{"transcript": transcript}))
+	}
+
+	fmt.Println("\nTranscript:")
+	fmt.Println("----------")
+	fmt.Println(transcript)
+	return nil
+}
+
+// printSegments prints timed segments in the selected output format
+func printSegments(segments []whisper.Segment) error {
+	if outputFormat == "json" {
+		return printJSON(withQuality(map[string]interface{}{
+			"transcript": whisper.JoinSegments(segments),
+			"segments":   segments,
+		}))
+	}
+
+	fmt.Println("\nTranscript:")
+	fmt.Println("----------")
+	for _, segment := range segments {
+		label := ""
+		if segment.Language != "" {
+			label = fmt.Sprintf(" (%s)", segment.Language)
+		}
+		if segment.Speaker != "" {
+			label += fmt.Sprintf(" %s:", segment.Speaker)
+		}
+		fmt.Printf("[%s --> %s]%s %s\n",
+			formatTimestamp(segment.Start), formatTimestamp(segment.End), label, strings.TrimSpace(segment.Text))
+	}
+	return nil
+}
+
+// printEnsembleWords prints the voted words, JSON output includes the model each word came from
+func printEnsembleWords(words []transcriber.EnsembleWord) error {
+	if outputFormat == "json" {
+		return printJSON(withQuality(map[string]interface{}{
+			"transcript": transcriber.JoinWords(words),
+			"words":      words,
+		}))
+	}
+
+	return printTranscript(transcriber.JoinWords(words))
+}
+
+// trackTranscript is the transcript of one audio stream of a file
+type trackTranscript struct {
+	Track      audio.AudioTrack     `json:"track"`
+	Transcript string               `json:"transcript"`
+	Segments   []whisper.Segment    `json:"segments,omitempty"`
+	Quality    *audio.QualityReport `json:"quality,omitempty"`
+}
+
+// printTracks prints the transcript of every audio stream in the selected output format
+func printTracks(results []trackTranscript) error {
+	if outputFormat == "json" {
+		return printJSON(map[string]interface{}{"tracks": results})
+	}
+
+	for _, result := range results {
+		fmt.Printf("\nAudio stream %s:\n", result.Track)
+		fmt.Println("----------")
+		if len(result.Segments) == 0 {
+			fmt.Println(result.Transcript)
+			continue
+		}
+		for _, segment := range result.Segments {
+			fmt.Printf("[%s --> %s] %s\n",
+				formatTimestamp(segment.Start), formatTimestamp(segment.End), strings.TrimSpace(segment.Text))
+		}
+	}
+	return nil
+}
+
+// printQuality prints the quality of the input with the problems found
+func printQuality(report *audio.QualityReport) {
+	fmt.Fprintf(infoWriter(), "Audio quality: %.1f LUFS, peak %.1f dBFS, %.2f%% clipped, SNR %.0f dB, %.0f%% silence\n",
+		report.Loudness, report.Peak, report.ClippingRatio*100, report.SNR, report.SilenceRatio*100)
+	if len(report.Issues) > 0 {
+		fmt.Fprintf(infoWriter(), "Warning: poor audio quality: %s\n", strings.Join(report.Issues, ", "))
+	}
+}
+
+// withQuality adds the quality of the input to a JSON result, if it was measured
+func withQuality(result map[string]interface{}) map[string]interface{} {
+	if audioQuality != nil {
+		result["quality"] = audioQuality
+	}
+	return result
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatTimestamp formats a duration as HH:MM:SS.mmm
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
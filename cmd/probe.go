@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/spf13/cobra"
+)
+
+var probeStream string
+
+// probeCmd represents the probe command
+var probeCmd = &cobra.Command{
+	Use:   "probe <file>",
+	Short: "Describe an audio file without transcribing it",
+	Long: `Print the container, codec, duration, sample rate, channels and bit rate of the
+specified audio file and the share of it which contains speech, e.g. to estimate the
+cost of transcribing it. No model is needed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(); err != nil {
+			return err
+		}
+		opts, err := audioOptions()
+		if err != nil {
+			return err
+		}
+		if opts.Stream, err = audio.ResolveStream(args[0], probeStream); err != nil {
+			return fmt.Errorf("failed to load audio file: %w", err)
+		}
+
+		info, err := audio.Probe(args[0], opts)
+		if err != nil {
+			return fmt.Errorf("failed to probe audio file: %w", err)
+		}
+
+		if outputFormat == "json" {
+			return printJSON(info)
+		}
+
+		fmt.Printf("Container:   %s\n", info.Container)
+		fmt.Printf("Codec:       %s\n", info.Codec)
+		fmt.Printf("Duration:    %s\n", formatTimestamp(info.Duration))
+		fmt.Printf("Sample rate: %d Hz\n", info.SampleRate)
+		fmt.Printf("Channels:    %d\n", info.Channels)
+		fmt.Printf("Bit rate:    %d kb/s\n", info.BitRate/1000)
+		fmt.Printf("Speech:      %.0f%%\n", info.SpeechRatio*100)
+		if len(info.Tracks) > 1 {
+			fmt.Println("Audio streams:")
+			for _, track := range info.Tracks {
+				fmt.Printf("  %s\n", track)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(probeCmd)
+
+	probeCmd.Flags().StringVar(&outputFormat, "format", "text", "Output format: text or json")
+	probeCmd.Flags().StringVar(&probeStream, "audio-stream", "", "Audio stream to describe: a number counting from 1 or a language tag (default FFmpeg's pick)")
+}
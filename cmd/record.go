@@ -2,23 +2,66 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/piotrjaromin/transcript/internal/recorder"
-	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
 	"github.com/spf13/cobra"
 )
 
 var (
-	outputFile string
+	outputFile      string
+	recorderBackend string
+	captureDevice   string
+	captureRate     int
+	captureChannels int
+	liveCaptions    bool
+	stopSilence     time.Duration
+	waitForSpeech   bool
+	maxDuration     time.Duration
+	continuous      bool
+	transcriptFile  string
 )
 
 // recordCmd represents the record command
 var recordCmd = &cobra.Command{
 	Use:   "record",
 	Short: "Record and transcribe audio",
-	Long:  `Record audio from the microphone, then transcribe it to text and printout.`,
+	Long: `Record audio from the microphone, then transcribe it to text and printout.
+
+While recording, a status line shows the elapsed time, the input level and warnings
+about clipping and silence. p pauses and resumes, ENTER or s stops and transcribes,
+and d discards the recording. Keys act at once where stty can switch the terminal,
+otherwise they are followed by ENTER.
+
+The audio is recorded with the first installed tool of sox, parec (PulseAudio and
+PipeWire), arecord (ALSA) and ffmpeg, --recorder-backend selects one. The file:<path>
+backend reads an audio file instead, or standard input for file:-, and stops at its end.
+
+--device records another input device than the default, as listed by the devices
+command. --sample-rate and --channels set the recorded format, the audio is mixed down
+and resampled to 16kHz mono for transcription.
+
+--live transcribes while recording and prints captions as you speak: the text
+which no longer changes, followed by the dimmed tentative text of the last few
+seconds. The full transcript with timestamps is printed when the recording stops.
+
+--stop-silence and --max-duration stop the recording on their own, it then starts
+without waiting for ENTER, and --wait-for-speech discards the audio until speech starts.
+Together they record hands-free, e.g. in scripts:
+
+  transcript record --wait-for-speech --stop-silence 2s --max-duration 5m
+
+--continuous records take after take, with the model loaded once: a take ends on
+ENTER or on its own, e.g. after --stop-silence, and the next one starts at once
+while it is transcribed. The recording tool keeps running, so nothing said
+between takes is lost. Every transcript is printed with the time of its take and
+appended to --transcript-file. d discards the current take, q or Ctrl+C finishes.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get model path
 		modelPath, err := getModelPath()
@@ -28,11 +71,77 @@ var recordCmd = &cobra.Command{
 
 		fmt.Printf("Using model: %s\n", modelPath)
 
+		source, err := recorder.NewSource(recorderBackend, recorder.Capture{
+			Device:     captureDevice,
+			SampleRate: captureRate,
+			Channels:   captureChannels,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Recording with: %s\n", source.Name())
+		if captureDevice != "" {
+			fmt.Printf("Using device: %s\n", captureDevice)
+		}
+
+		if continuous && outputFile != "" {
+			return fmt.Errorf("--output cannot be used with --continuous, every take would overwrite it")
+		}
+		if transcriptFile != "" && !continuous {
+			return fmt.Errorf("--transcript-file needs --continuous")
+		}
+
 		// Create recorder
 		rec := recorder.NewRecorder(outputFile)
+		rec.SetSource(source)
+		err = rec.SetAutoStop(recorder.AutoStop{Silence: stopSilence, WaitForSpeech: waitForSpeech, MaxDuration: maxDuration})
+		if err != nil {
+			return err
+		}
 
-		fmt.Println("Press ENTER to start recording...")
-		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		// Create transcriber, before recording so live captions start right away
+		trans, err := newTranscriber(modelPath)
+		if err != nil {
+			return fmt.Errorf("failed to create transcriber: %w", err)
+		}
+		defer trans.Close()
+
+		// A file is read to its end without waiting for ENTER, and a recording
+		// which stops on its own starts right away
+		interactive := source.Name() != recorder.BackendFile
+		handsFree := stopSilence > 0 || maxDuration > 0
+		stdin := bufio.NewReader(os.Stdin)
+		if interactive && !handsFree && !continuous {
+			fmt.Println("Press ENTER to start recording...")
+			stdin.ReadBytes('\n')
+		}
+
+		// Keys control the recording, Ctrl+C is caught to restore the terminal
+		ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stopSignals()
+		var keys <-chan rune
+		var status *statusLine
+		var kb *keyboard
+		if interactive {
+			kb = openKeyboard(stdin)
+			defer kb.close()
+			keys = kb.keys
+			// Live captions take the line of the status
+			if isTerminal(os.Stdout) && !liveCaptions {
+				status = &statusLine{out: os.Stdout}
+			}
+		}
+
+		if continuous {
+			return recordContinuous(ctx, rec, trans, keys, status)
+		}
+
+		var live *liveTranscription
+		var liveDone <-chan struct{}
+		if liveCaptions {
+			live = startLive(trans, rec.Frames())
+			liveDone = live.done
+		}
 
 		// Start recording
 		err = rec.StartRecording()
@@ -40,28 +149,60 @@ var recordCmd = &cobra.Command{
 			return fmt.Errorf("failed to start recording: %w", err)
 		}
 
-		fmt.Println("Recording... Press ENTER again to stop recording and start transcription.")
-		bufio.NewReader(os.Stdin).ReadBytes('\n')
+		if waitForSpeech {
+			fmt.Println("Waiting for speech...")
+		}
+		if interactive {
+			fmt.Println("Recording... Press ENTER or s to stop and transcribe, p to pause or resume, d to discard.")
+		}
+		// The recording also ends when the backend exits, e.g. on a device error
+		end := runTake(ctx, rec, rec, keys, status, liveDone)
+		stopSignals()
+		if kb != nil {
+			kb.close()
+		}
+
+		// Ctrl+C also reaches the recording tool, the recording is given up
+		if end.discarded || ctx.Err() != nil {
+			rec.DiscardRecording()
+			if live != nil {
+				live.abort()
+			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("recording interrupted")
+			}
+			fmt.Println("Recording discarded.")
+			return nil
+		}
 
 		// Stop recording
 		_, err = rec.StopRecording()
+		var segments []whisper.Segment
+		var liveErr error
+		if live != nil {
+			// The rest of the recording is transcribed once it has ended
+			segments, liveErr = live.wait()
+		}
+		printStopReason(rec)
 		if err != nil {
 			return fmt.Errorf("failed to stop recording: %w", err)
 		}
+		if live != nil {
+			if liveErr != nil {
+				return fmt.Errorf("transcription failed: %w", liveErr)
+			}
+			return printSegments(segments)
+		}
 
 		// Get audio data
 		samples := rec.GetAudioData()
+		if len(samples) == 0 && waitForSpeech {
+			return fmt.Errorf("no speech was heard")
+		}
 		if len(samples) == 0 {
 			return fmt.Errorf("no audio data recorded")
 		}
 
-		// Create transcriber
-		trans, err := transcriber.NewFileTranscriber(modelPath, language, numThreads)
-		if err != nil {
-			return fmt.Errorf("failed to create transcriber: %w", err)
-		}
-		defer trans.Close()
-
 		// Transcribe audio
 		fmt.Println("Transcribing audio...")
 		transcript, err := trans.TranscribeFromSamples(samples)
@@ -78,7 +219,27 @@ var recordCmd = &cobra.Command{
 	},
 }
 
+// printStopReason tells why the recording or take stopped on its own
+func printStopReason(rec recording) {
+	switch rec.StopReason() {
+	case recorder.StopSilence:
+		fmt.Printf("Stopped after %s of silence.\n", stopSilence)
+	case recorder.StopMaxDuration:
+		fmt.Printf("Stopped at the maximum duration of %s.\n", maxDuration)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(recordCmd)
 	recordCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Path to save the recorded audio (optional)")
+	recordCmd.Flags().StringVar(&recorderBackend, "recorder-backend", recorder.BackendAuto, "Recording tool: auto, sox, parec, arecord, ffmpeg, or file:<path> to read audio from a file")
+	recordCmd.Flags().StringVar(&captureDevice, "device", "", "Input device to record, as listed by the devices command (default the system's default device)")
+	recordCmd.Flags().IntVar(&captureRate, "sample-rate", 16000, "Sample rate to record at, resampled to 16kHz for transcription")
+	recordCmd.Flags().IntVar(&captureChannels, "channels", 1, "Number of channels to record, mixed down to mono for transcription")
+	recordCmd.Flags().BoolVar(&liveCaptions, "live", false, "Transcribe while recording, printing captions as you speak")
+	recordCmd.Flags().DurationVar(&stopSilence, "stop-silence", 0, "Stop recording after this much silence following speech, e.g. 2s (default off)")
+	recordCmd.Flags().BoolVar(&waitForSpeech, "wait-for-speech", false, "Discard the audio until speech starts")
+	recordCmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "Stop recording after this long, including any wait for speech, e.g. 5m (default no limit)")
+	recordCmd.Flags().BoolVar(&continuous, "continuous", false, "Record and transcribe take after take, a take ends on ENTER or on its own, e.g. with --stop-silence")
+	recordCmd.Flags().StringVar(&transcriptFile, "transcript-file", "", "File the transcript of every take is appended to with its time, with --continuous")
 }
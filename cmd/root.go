@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/piotrjaromin/transcript/internal/audio"
 	"github.com/spf13/cobra"
 )
 
 var (
-	modelPath  string
-	language   string
+	modelPath   string
+	language    string
+	modelRoutes map[string]string
+	audioFilter string
+
+	noiseSuppression float64
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -37,4 +42,7 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&modelPath, "model", "", "Path to the whisper model file (if not provided, will use embedded model)")
 	rootCmd.PersistentFlags().StringVar(&language, "language", "auto", "Language of the audio (optional, auto-detected if not provided)")
+	rootCmd.PersistentFlags().StringToStringVar(&modelRoutes, "route", nil, "Per-language models, e.g. en=models/ggml-medium.en.bin,pl=models/ggml-large-v3.bin (--model then only detects the language)")
+	rootCmd.PersistentFlags().StringVar(&audioFilter, "audio-filter", "", "Audio preprocessing: comma separated presets (none, normalize, highpass, denoise, loudnorm) applied in order, or custom:<ffmpeg filters> (default normalize)")
+	rootCmd.PersistentFlags().Float64Var(&noiseSuppression, "noise-suppression", 0, fmt.Sprintf("Suppress stationary background noise such as fans or traffic in-process by up to this many dB, e.g. %d (default off)", audio.DefaultNoiseSuppression))
 }
@@ -3,13 +3,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/piotrjaromin/transcript/internal/server"
 	"github.com/spf13/cobra"
 )
 
 var (
-	port int
+	port             int
+	maxAudioDuration time.Duration
 )
 
 var numThreads = 4
@@ -29,7 +31,22 @@ var serverCmd = &cobra.Command{
 		fmt.Printf("Using model: %s\n", modelPath)
 		fmt.Printf("Default language: %s\n", language)
 
-		srv := server.NewServer(port, modelPath, language, numThreads)
+		for lang, path := range modelRoutes {
+			fmt.Printf("Routing language %s to model: %s\n", lang, path)
+		}
+
+		srv := server.NewServer(server.Config{
+			Port:             port,
+			ModelPath:        modelPath,
+			Language:         language,
+			NumThreads:       numThreads,
+			Routes:           modelRoutes,
+			AudioFilter:      audioFilter,
+			MaxAudioDuration: maxAudioDuration,
+			Tempo:            tempo,
+			NoiseSuppression: noiseSuppression,
+			VAD:              vadOptions(),
+		})
 		return srv.Start()
 	},
 }
@@ -38,5 +55,8 @@ func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().IntVar(&port, "port", 8080, "Port to run the HTTP server on")
 	serverCmd.Flags().StringVar(&modelPath, "model", "", "Path to the whisper model file (required)")
+	serverCmd.Flags().DurationVar(&maxAudioDuration, "max-audio-duration", 0, "Reject uploads with longer audio, e.g. 30m (default no limit)")
+	serverCmd.Flags().Float64Var(&tempo, "tempo", 1, "Speed the audio up by this factor before transcription to save compute, e.g. 1.25, requests may override it with the tempo field")
+	addVADFlags(serverCmd)
 	serverCmd.MarkFlagRequired("model")
 }
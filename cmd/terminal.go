@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/piotrjaromin/transcript/internal/recorder"
+)
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// keyboard reads keys typed while recording. Where stty can turn off line
+// buffering every key acts on its own, otherwise it is followed by ENTER,
+// which alone reads as '\n'.
+type keyboard struct {
+	keys chan rune
+	// saved holds the stty settings restored by close, empty in line mode
+	saved     string
+	closeOnce sync.Once
+}
+
+// openKeyboard starts reading keys from stdin, which has to be os.Stdin
+func openKeyboard(stdin *bufio.Reader) *keyboard {
+	k := &keyboard{keys: make(chan rune)}
+	if isTerminal(os.Stdin) {
+		if saved, err := stty("-g"); err == nil {
+			if _, err := stty("-icanon", "-echo", "min", "1"); err == nil {
+				k.saved = strings.TrimSpace(saved)
+			}
+		}
+	}
+
+	raw := k.saved != ""
+	go func() {
+		// The channel is never closed, without input no key arrives
+		for {
+			if raw {
+				key, _, err := stdin.ReadRune()
+				if err != nil {
+					return
+				}
+				k.keys <- key
+				continue
+			}
+			line, err := stdin.ReadString('\n')
+			if err != nil {
+				return
+			}
+			key := '\n'
+			if line = strings.TrimSpace(line); line != "" {
+				key = []rune(line)[0]
+			}
+			k.keys <- key
+		}
+	}()
+	return k
+}
+
+// close restores the terminal
+func (k *keyboard) close() {
+	k.closeOnce.Do(func() {
+		if k.saved != "" {
+			stty(k.saved)
+		}
+	})
+}
+
+// stty runs stty on the terminal of stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return string(output), err
+}
+
+// Keys acting on a recording
+const (
+	keyPause   = 'p'
+	keyStop    = 's'
+	keyDiscard = 'd'
+	keyQuit    = 'q'
+)
+
+// keyAction is what a key does to a recording
+type keyAction int
+
+const (
+	actionNone keyAction = iota
+	actionPause
+	actionStop
+	actionDiscard
+	actionQuit
+)
+
+// actionForKey returns the action of a key, in either case. Space pauses too,
+// and ENTER stops.
+func actionForKey(key rune) keyAction {
+	switch unicode.ToLower(key) {
+	case keyPause, ' ':
+		return actionPause
+	case keyStop, '\n', '\r':
+		return actionStop
+	case keyDiscard:
+		return actionDiscard
+	case keyQuit:
+		return actionQuit
+	}
+	return actionNone
+}
+
+// takeEnd tells how a take ended
+type takeEnd struct {
+	// discarded is set when the take was thrown away
+	discarded bool
+	// last is set when no take follows: on q, Ctrl+C or when the source ended
+	last bool
+}
+
+// recording is what runTake waits for the end of: a whole recording or a take
+// of a continuous session
+type recording interface {
+	Done() <-chan struct{}
+	StopReason() string
+}
+
+// runTake waits for the end of a recording: by a key, a stop condition, the
+// source ending or a failing live transcription. The status line, if any, is
+// redrawn meanwhile.
+func runTake(ctx context.Context, rec *recorder.Recorder, current recording, keys <-chan rune, status *statusLine, liveDone <-chan struct{}) takeEnd {
+	var ticks <-chan time.Time
+	if status != nil {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		defer status.clear()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case key := <-keys:
+			switch actionForKey(key) {
+			case actionPause:
+				if rec.Paused() {
+					rec.Resume()
+				} else {
+					rec.Pause()
+				}
+				// The status line shows it, otherwise it is told
+				if status == nil {
+					if rec.Paused() {
+						fmt.Println("Paused, press p to resume.")
+					} else {
+						fmt.Println("Resumed.")
+					}
+				}
+			case actionStop:
+				return takeEnd{}
+			case actionDiscard:
+				return takeEnd{discarded: true}
+			case actionQuit:
+				return takeEnd{last: true}
+			}
+		case <-ctx.Done():
+			return takeEnd{last: true}
+		case <-current.Done():
+			return takeEnd{last: current.StopReason() == recorder.StopSourceEnded}
+		case <-liveDone:
+			return takeEnd{}
+		case <-ticks:
+			status.draw(rec.Status())
+		}
+	}
+}
+
+const (
+	// meterWidth is the number of characters of the level meter
+	meterWidth = 20
+	// meterFloor is the level in dBFS at the left end of the meter
+	meterFloor = -60
+	// clipHold is how long a clipping warning stays after the last clip
+	clipHold = 2 * time.Second
+	// silenceShown is how long it has to be quiet before it is shown
+	silenceShown = 2 * time.Second
+)
+
+// statusLine draws the state of a recording on one terminal line: elapsed
+// time, level meter and clipping and silence warnings
+type statusLine struct {
+	out io.Writer
+	// clipped is when the audio clipped last
+	clipped time.Time
+	// mu keeps other output from breaking into a redraw
+	mu sync.Mutex
+}
+
+// draw redraws the line with the status
+func (l *statusLine) draw(status recorder.Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if status.Clipped > 0 {
+		l.clipped = now
+	}
+	fmt.Fprintf(l.out, "\r\033[K%s", formatStatus(status, now.Sub(l.clipped) < clipHold))
+}
+
+// formatStatus returns the text of the status line, with the clipping
+// warning when clipping is set
+func formatStatus(status recorder.Status, clipping bool) string {
+	state := "\033[31m●\033[0m REC   "
+	if status.Paused {
+		state = "\033[33m‖\033[0m PAUSED"
+	}
+	elapsed := int(status.Elapsed.Seconds())
+
+	// The meter shows the RMS level, with a mark at the peak
+	position := func(db float64) int {
+		return min(max(int((db-meterFloor)/-meterFloor*meterWidth), 0), meterWidth)
+	}
+	filled, peak := position(status.RMS), position(status.Peak)
+	meter := []rune(strings.Repeat("█", filled) + strings.Repeat("·", meterWidth-filled))
+	if peak > filled && peak <= meterWidth {
+		meter[peak-1] = '|'
+	}
+
+	parts := []string{
+		fmt.Sprintf("%s %02d:%02d", state, elapsed/60, elapsed%60),
+		fmt.Sprintf("[%s] %4.0f dB", string(meter), status.RMS),
+	}
+	if clipping {
+		parts = append(parts, "\033[31mCLIPPING, lower the gain\033[0m")
+	}
+	if !status.Paused && status.Silence >= silenceShown {
+		if status.Heard {
+			parts = append(parts, fmt.Sprintf("silence %ds", int(status.Silence.Seconds())))
+		} else {
+			parts = append(parts, "no speech yet")
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+// clear removes the line
+func (l *statusLine) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprint(l.out, "\r\033[K")
+}
+
+// println prints a line of other output in place of the status line, which
+// the next draw brings back; without a status line it is printed as it is
+func (l *statusLine) println(text string) {
+	if l == nil {
+		fmt.Println(text)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "\r\033[K%s\n", text)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/recorder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionForKey(t *testing.T) {
+	tests := []struct {
+		key    rune
+		action keyAction
+	}{
+		{'p', actionPause},
+		{'P', actionPause},
+		{' ', actionPause},
+		{'s', actionStop},
+		{'S', actionStop},
+		{'\n', actionStop},
+		{'\r', actionStop},
+		{'d', actionDiscard},
+		{'D', actionDiscard},
+		{'q', actionQuit},
+		{'Q', actionQuit},
+		{'x', actionNone},
+		{'1', actionNone},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.action, actionForKey(tt.key), "key %q", tt.key)
+	}
+}
+
+func TestFormatStatus(t *testing.T) {
+	level := audio.Level{RMS: -30, Peak: -12}
+
+	t.Run("recording", func(t *testing.T) {
+		text := formatStatus(recorder.Status{Level: level, Elapsed: 65 * time.Second, Heard: true}, false)
+		assert.Equal(t, "\033[31m●\033[0m REC    01:05  [██████████·····|····]  -30 dB", text)
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		// Silence is not counted while paused
+		text := formatStatus(recorder.Status{Level: level, Silence: 5 * time.Second, Paused: true}, false)
+		assert.True(t, strings.HasPrefix(text, "\033[33m‖\033[0m PAUSED 00:00"), text)
+		assert.NotContains(t, text, "silence")
+		assert.NotContains(t, text, "no speech")
+	})
+
+	t.Run("quiet", func(t *testing.T) {
+		text := formatStatus(recorder.Status{Level: audio.Level{RMS: -80, Peak: -80}}, false)
+		assert.Contains(t, text, "[····················]  -80 dB")
+	})
+
+	t.Run("clipping", func(t *testing.T) {
+		text := formatStatus(recorder.Status{Level: audio.Level{RMS: 0, Peak: 0}}, true)
+		assert.Contains(t, text, "[████████████████████]")
+		assert.Contains(t, text, "CLIPPING, lower the gain")
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		assert.NotContains(t, formatStatus(recorder.Status{Silence: time.Second, Heard: true}, false), "silence")
+		assert.True(t, strings.HasSuffix(formatStatus(recorder.Status{Silence: 3500 * time.Millisecond, Heard: true}, false), "  silence 3s"))
+		assert.True(t, strings.HasSuffix(formatStatus(recorder.Status{Silence: 3 * time.Second}, false), "  no speech yet"))
+	})
+}
+
+func TestStatusLine(t *testing.T) {
+	var out bytes.Buffer
+	line := &statusLine{out: &out}
+
+	// A clip keeps the warning up for a while
+	line.draw(recorder.Status{Level: audio.Level{Clipped: 3}})
+	line.draw(recorder.Status{})
+	assert.Equal(t, 2, strings.Count(out.String(), "CLIPPING"))
+
+	out.Reset()
+	line.println("[10:00:00] hello")
+	assert.Equal(t, "\r\033[K[10:00:00] hello\n", out.String())
+
+	out.Reset()
+	line.clear()
+	assert.Equal(t, "\r\033[K", out.String())
+}
+
+// fakeRecording ends when done is closed
+type fakeRecording struct {
+	done   chan struct{}
+	reason string
+}
+
+func (r *fakeRecording) Done() <-chan struct{} { return r.done }
+func (r *fakeRecording) StopReason() string    { return r.reason }
+
+func TestRunTake(t *testing.T) {
+	run := func(keys ...rune) (takeEnd, *recorder.Recorder) {
+		rec := recorder.NewRecorder("")
+		input := make(chan rune, len(keys))
+		for _, key := range keys {
+			input <- key
+		}
+		current := &fakeRecording{done: make(chan struct{})}
+		return runTake(context.Background(), rec, current, input, nil, nil), rec
+	}
+
+	end, rec := run('x', 'p', 's')
+	assert.Equal(t, takeEnd{}, end)
+	assert.True(t, rec.Paused())
+
+	end, rec = run('p', ' ', '\n')
+	assert.Equal(t, takeEnd{}, end)
+	assert.False(t, rec.Paused())
+
+	end, _ = run('d')
+	assert.Equal(t, takeEnd{discarded: true}, end)
+
+	end, _ = run('Q')
+	assert.Equal(t, takeEnd{last: true}, end)
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		end := runTake(ctx, recorder.NewRecorder(""), &fakeRecording{done: make(chan struct{})}, nil, nil, nil)
+		assert.Equal(t, takeEnd{last: true}, end)
+	})
+
+	t.Run("recording ended", func(t *testing.T) {
+		current := &fakeRecording{done: make(chan struct{}), reason: recorder.StopSilence}
+		close(current.done)
+		assert.Equal(t, takeEnd{}, runTake(context.Background(), recorder.NewRecorder(""), current, nil, nil, nil))
+
+		current = &fakeRecording{done: make(chan struct{}), reason: recorder.StopSourceEnded}
+		close(current.done)
+		assert.Equal(t, takeEnd{last: true}, runTake(context.Background(), recorder.NewRecorder(""), current, nil, nil, nil))
+	})
+
+	t.Run("live transcription failed", func(t *testing.T) {
+		liveDone := make(chan struct{})
+		close(liveDone)
+		end := runTake(context.Background(), recorder.NewRecorder(""), &fakeRecording{done: make(chan struct{})}, nil, nil, liveDone)
+		assert.Equal(t, takeEnd{}, end)
+	})
+}
@@ -5,6 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/transcriber"
+	"github.com/piotrjaromin/transcript/internal/whisper"
 )
 
 // Default model paths to check
@@ -17,7 +21,7 @@ var defaultModelPaths = []string{
 
 // For thread-safe model path resolution
 var (
-	modelOnce     sync.Once
+	modelOnce      sync.Once
 	foundModelPath string
 )
 
@@ -26,13 +30,13 @@ func getModelInfo() string {
 	if modelPath != "" {
 		return modelPath
 	}
-	
+
 	// Find model path using thread-safe method
 	path, _ := getModelPath()
 	if path != "" {
 		return fmt.Sprintf("default model found at %s", path)
 	}
-	
+
 	return "no model found, please specify with --model flag"
 }
 
@@ -49,7 +53,7 @@ func getModelPath() (string, error) {
 	if modelPath != "" {
 		return validateModelPath(modelPath)
 	}
-	
+
 	// Use sync.Once to ensure we only search for default models once
 	modelOnce.Do(func() {
 		for _, path := range defaultModelPaths {
@@ -60,10 +64,55 @@ func getModelPath() (string, error) {
 			}
 		}
 	})
-	
+
 	if foundModelPath != "" {
 		return foundModelPath, nil
 	}
-	
+
 	return "", fmt.Errorf("no model found, please specify with --model flag")
 }
+
+// resolveModel returns the path of a model given either as a path or by name,
+// e.g. "small" resolves to ./models/ggml-small.bin
+func resolveModel(name string) (string, error) {
+	if _, err := os.Stat(name); err == nil {
+		return name, nil
+	}
+
+	path, err := filepath.Abs(filepath.Join("models", "ggml-"+name+".bin"))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("model not found: %s", name)
+	}
+	return path, nil
+}
+
+// newTranscriber creates a file transcriber for the model, routing by
+// language when per-language models were configured with --route
+func newTranscriber(modelPath string) (*transcriber.FileTranscriber, error) {
+	if err := whisper.CheckRouting(language, modelRoutes); err != nil {
+		return nil, err
+	}
+	if len(modelRoutes) == 0 {
+		return transcriber.NewFileTranscriber(modelPath, language, numThreads)
+	}
+
+	router, err := whisper.NewRouter(modelPath, modelRoutes, numThreads)
+	if err != nil {
+		return nil, err
+	}
+	return transcriber.NewFileTranscriberWithClient(router), nil
+}
+
+// audioOptions returns the decoding options selected with --audio-filter
+func audioOptions() (audio.Options, error) {
+	if _, err := audio.ParseFilter(audioFilter); err != nil {
+		return audio.Options{}, err
+	}
+	if err := audio.CheckNoiseSuppression(noiseSuppression); err != nil {
+		return audio.Options{}, err
+	}
+	return audio.Options{Filter: audioFilter, NoiseSuppression: noiseSuppression}, nil
+}
@@ -1,19 +1,23 @@
 module github.com/piotrjaromin/transcript
 
-go 1.23
-
-toolchain go1.23.1
+go 1.24.0
 
 require (
 	github.com/ggerganov/whisper.cpp/bindings/go v0.0.0-20250314144020-e0f3c9d4dd25
 	github.com/gin-gonic/gin v1.9.1
 	github.com/go-audio/wav v1.1.0
 	github.com/spf13/cobra v1.8.0
-	github.com/stretchr/testify v1.9.0
+	github.com/stretchr/testify v1.11.1
 	github.com/u2takey/ffmpeg-go v0.4.1
 )
 
-require github.com/go-audio/audio v1.0.0
+require (
+	github.com/go-audio/audio v1.0.0
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/jfreymuth/oggvorbis v1.0.5
+	github.com/mewkiz/flac v1.0.7
+	github.com/pion/opus v0.1.0
+)
 
 require (
 	github.com/aws/aws-sdk-go v1.38.20 // indirect
@@ -28,12 +32,15 @@ require (
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.16.0 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
+	github.com/icza/bitio v1.0.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.6 // indirect
 	github.com/leodido/go-urn v1.2.4 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mewkiz/pkg v0.0.0-20190919212034-518ade7978e2 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.1.1 // indirect
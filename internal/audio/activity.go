@@ -0,0 +1,111 @@
+package audio
+
+import (
+	"time"
+)
+
+// activityHistory is how much audio the noise floor of a SpeechDetector is
+// estimated from
+const activityHistory = 30 * time.Second
+
+// SpeechDetector follows voice activity of audio as it arrives, e.g. while it
+// is recorded. Frames are classified as DetectSpeech does, against the noise
+// floor of the audio so far; speech counts once it lasts MinSpeech, with
+// pauses shorter than MinSilence.
+type SpeechDetector struct {
+	opts      VADOptions
+	frameSize int
+	// frame collects samples until a frame is complete
+	frame []float32
+	// energies holds the energies of recent frames for the noise floor
+	energies []float64
+	position int
+
+	// runStart and runEnd are the bounds of the latest run of speech frames,
+	// runEnd is -1 before any speech frame
+	runStart int
+	runEnd   int
+	// heard is set once a run lasted MinSpeech, speechStart is where the
+	// first such run started and lastSpeech where the latest ended
+	heard       bool
+	speechStart int
+	lastSpeech  int
+	// speech is the length of the finished speech regions
+	speech int
+}
+
+// NewSpeechDetector creates a detector with the given settings
+func NewSpeechDetector(opts VADOptions) *SpeechDetector {
+	frameSize := max(durationToSamples(opts.FrameDuration), 1)
+	return &SpeechDetector{opts: opts, frameSize: frameSize, runEnd: -1}
+}
+
+// Process analyses the next samples
+func (d *SpeechDetector) Process(samples []float32) {
+	for len(samples) > 0 {
+		n := min(d.frameSize-len(d.frame), len(samples))
+		d.frame = append(d.frame, samples[:n]...)
+		samples = samples[n:]
+		if len(d.frame) == d.frameSize {
+			d.processFrame()
+			d.frame = d.frame[:0]
+		}
+	}
+}
+
+// processFrame classifies a complete frame and follows the speech runs
+func (d *SpeechDetector) processFrame() {
+	energy, rate := analyseFrame(d.frame)
+	d.energies = append(d.energies, energy)
+	if limit := durationToSamples(activityHistory) / d.frameSize; len(d.energies) > limit {
+		d.energies = d.energies[len(d.energies)-limit:]
+	}
+	start := d.position
+	d.position += d.frameSize
+
+	if !isSpeech(energy, rate, speechThreshold(d.energies, d.opts), d.opts) {
+		return
+	}
+	if d.runEnd < 0 || start-d.runEnd >= durationToSamples(d.opts.MinSilence) {
+		d.speech += d.runSpeech()
+		d.runStart = start
+	}
+	d.runEnd = d.position
+	if d.runEnd-d.runStart >= durationToSamples(d.opts.MinSpeech) {
+		if !d.heard {
+			d.heard, d.speechStart = true, d.runStart
+		}
+		d.lastSpeech = d.runEnd
+	}
+}
+
+// Heard reports whether speech was heard so far
+func (d *SpeechDetector) Heard() bool {
+	return d.heard
+}
+
+// SpeechStart returns the position in samples where the first speech
+// started, with the padding of the settings, once it was heard
+func (d *SpeechDetector) SpeechStart() int {
+	return max(d.speechStart-durationToSamples(d.opts.Padding), 0)
+}
+
+// Silence returns how long it has been quiet, since speech was last heard or
+// since the start
+func (d *SpeechDetector) Silence() time.Duration {
+	return samplesToDuration(d.position + len(d.frame) - d.lastSpeech)
+}
+
+// SpeechDuration returns how long the speech regions heard so far are,
+// without padding
+func (d *SpeechDetector) SpeechDuration() time.Duration {
+	return samplesToDuration(d.speech + d.runSpeech())
+}
+
+// runSpeech returns the length of the latest run if it counts as speech
+func (d *SpeechDetector) runSpeech() int {
+	if d.runEnd < 0 || d.runEnd-d.runStart < durationToSamples(d.opts.MinSpeech) {
+		return 0
+	}
+	return d.runEnd - d.runStart
+}
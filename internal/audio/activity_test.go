@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeechDetector(t *testing.T) {
+	samples := testNoise(5 * time.Second)
+	addTone(samples, 2*time.Second, 3*time.Second, 0.1, 300)
+	// A click is too short to count as speech
+	addTone(samples, 4*time.Second, 4*time.Second+60*time.Millisecond, 0.1, 300)
+
+	detector := NewSpeechDetector(DefaultVADOptions())
+	feed := func(until time.Duration) {
+		for pos := detector.position + len(detector.frame); pos < durationToSamples(until); pos += 1000 {
+			detector.Process(samples[pos:min(pos+1000, durationToSamples(until))])
+		}
+	}
+
+	feed(2 * time.Second)
+	assert.False(t, detector.Heard())
+	assert.Equal(t, 2*time.Second, detector.Silence())
+
+	feed(2500 * time.Millisecond)
+	assert.True(t, detector.Heard())
+	// The speech start is padded
+	assert.InDelta(t, durationToSamples(1800*time.Millisecond), detector.SpeechStart(), float64(durationToSamples(30*time.Millisecond)))
+	assert.Less(t, detector.Silence(), 100*time.Millisecond)
+
+	feed(5 * time.Second)
+	assert.InDelta(t, float64(2*time.Second), float64(detector.Silence()), float64(30*time.Millisecond))
+	// Only the tone counts, not the click
+	assert.InDelta(t, float64(time.Second), float64(detector.SpeechDuration()), float64(60*time.Millisecond))
+}
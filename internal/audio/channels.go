@@ -0,0 +1,240 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Channel selection modes
+const (
+	// ChannelsMix mixes all channels into one
+	ChannelsMix = "mix"
+	// ChannelsSingle uses one channel chosen by number
+	ChannelsSingle = "single"
+	// ChannelsSplit decodes and transcribes every channel separately
+	ChannelsSplit = "split"
+	// ChannelsLoudest uses the channel with the highest level
+	ChannelsLoudest = "loudest"
+)
+
+// ParseChannels parses a channel selection: "mix", "split", "loudest", "left",
+// "right" or a channel number counting from 1. The channel is only set for
+// ChannelsSingle.
+func ParseChannels(spec string) (mode string, channel int, err error) {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case "", ChannelsMix:
+		return ChannelsMix, 0, nil
+	case ChannelsSplit:
+		return ChannelsSplit, 0, nil
+	case ChannelsLoudest:
+		return ChannelsLoudest, 0, nil
+	case "left":
+		return ChannelsSingle, 1, nil
+	case "right":
+		return ChannelsSingle, 2, nil
+	}
+
+	channel, err = strconv.Atoi(spec)
+	if err != nil || channel < 1 {
+		return "", 0, fmt.Errorf("invalid channel selection %q: use mix, split, loudest, left, right or a channel number counting from 1", spec)
+	}
+	return ChannelsSingle, channel, nil
+}
+
+// channelSink receives the decoded samples of every channel as they arrive,
+// the channels are numbered from 0
+type channelSink func(channel int, samples []float32)
+
+// LoadChannels decodes every channel of the audio file separately, each
+// resampled to whisper's sample rate. Options.Channel is ignored.
+func LoadChannels(filePath string, opts Options) ([][]float32, error) {
+	var channels [][]float32
+	_, err := streamChannels(filePath, opts, func(channel int, samples []float32) {
+		for len(channels) <= channel {
+			channels = append(channels, nil)
+		}
+		channels[channel] = append(channels[channel], samples...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// streamChannels decodes every channel of the audio file into the sink and
+// returns the number of samples per channel. Audio longer than the duration
+// limit is decoded only up to just past the limit.
+func streamChannels(filePath string, opts Options, sink channelSink) (int, error) {
+	if _, err := ParseFilter(opts.Filter); err != nil {
+		return 0, err
+	}
+	if err := checkRaw(opts); err != nil {
+		return 0, err
+	}
+	if err := CheckTempo(opts.Tempo); err != nil {
+		return 0, err
+	}
+	if err := CheckNoiseSuppression(opts.NoiseSuppression); err != nil {
+		return 0, err
+	}
+	opts.Channel = 0
+
+	samples, err := loadChannels(filePath, opts, sink)
+	if err != nil {
+		return 0, err
+	}
+	if samples == 0 {
+		return 0, audibleError(0, 0)
+	}
+	if limit := limitSamples(opts); limit > 0 && samples > limit {
+		return 0, errorf(ErrTooLong, "audio too long: longer than the limit of %s", opts.DurationLimit)
+	}
+	return samples, nil
+}
+
+// loadChannels picks the decoder for streamChannels
+func loadChannels(filePath string, opts Options, sink channelSink) (int, error) {
+	file, err := openInput(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	haveFFmpeg := ffmpegAvailable()
+	filtered := requestsFilter(opts.Filter)
+	if filtered && !haveFFmpeg {
+		return 0, errorf(ErrDecoderMissing, "unsupported audio filter: %q needs ffmpeg", opts.Filter)
+	}
+
+	reader := bufio.NewReaderSize(file, wavHeaderPeek)
+	native := !haveFFmpeg || opts.Raw != nil && !filtered
+	if format, ok := peekWAVFormat(reader); opts.Raw == nil && ok && format.sampleRate == SampleRate && !filtered {
+		native = true
+	}
+	if native {
+		decoder, err := openDecoder(reader, opts)
+		if err != nil {
+			return 0, err
+		}
+		if err := checkNativeStream(opts); err != nil {
+			return 0, err
+		}
+		return decodeChannels(decoder, opts, sink)
+	}
+
+	return loadChannelsFFmpeg(filePath, reader, peekContainer(reader), opts, sink)
+}
+
+// LoudestChannel returns the number, counting from 1, of the channel of the
+// audio file with the highest RMS level. The channels are measured in a
+// single pass without keeping their samples.
+func LoudestChannel(filePath string, opts Options) (int, error) {
+	// Normalized channels would all be equally loud
+	opts.Filter, opts.NoiseSuppression = FilterNone, 0
+	var energies []float64
+	_, err := streamChannels(filePath, opts, func(channel int, samples []float32) {
+		for len(energies) <= channel {
+			energies = append(energies, 0)
+		}
+		for _, s := range samples {
+			energies[channel] += float64(s) * float64(s)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// All channels have as many samples, the energy ranks them like the RMS
+	loudest, loudestEnergy := 0, -1.0
+	for i, energy := range energies {
+		if energy > loudestEnergy {
+			loudest, loudestEnergy = i+1, energy
+		}
+	}
+	return loudest, nil
+}
+
+// channelLimit returns the number of samples per channel to decode at most,
+// just past the duration limit so exceeding it is noticed, -1 for all
+func channelLimit(opts Options) int {
+	maxSamples := sampleLimit(opts)
+	if opts.DurationLimit > 0 {
+		if limit := limitSamples(opts) + 1; maxSamples < 0 || limit < maxSamples {
+			maxSamples = limit
+		}
+	}
+	return maxSamples
+}
+
+// decodeChannels reads the decoder and resamples, normalizes, changes the
+// tempo and suppresses the noise of every channel on its own
+func decodeChannels(decoder pcmDecoder, opts Options, sink channelSink) (int, error) {
+	chains := make([]stageChain, decoder.channels())
+	for ch := range chains {
+		chains[ch] = stageChain{
+			newResampler(decoder.sampleRate(), SampleRate),
+			newDynamicNormalizer(normalizes(opts)),
+			newTempoStretcher(opts.tempo()),
+			newNoiseSuppressor(opts.NoiseSuppression),
+		}
+	}
+	return readChannels(decoder, chains, channelLimit(opts), sink)
+}
+
+// readChannels deinterleaves the decoder block by block, runs every channel
+// through its chain and passes the output to the sink until the end of the
+// input or until maxSamples per channel were produced, -1 for no limit. It
+// returns the number of samples per channel.
+func readChannels(decoder pcmDecoder, chains []stageChain, maxSamples int, sink channelSink) (int, error) {
+	channels := len(chains)
+	// produced counts the samples passed on per channel, they all stay in step
+	produced := make([]int, channels)
+	emit := func(ch int, samples []float32) {
+		if maxSamples >= 0 {
+			samples = samples[:min(len(samples), maxSamples-produced[ch])]
+		}
+		if len(samples) > 0 {
+			sink(ch, samples)
+			produced[ch] += len(samples)
+		}
+	}
+
+	buf := make([]float32, DefaultFrameSize*channels)
+	for {
+		n, err := decoder.read(buf)
+		for ch, samples := range deinterleave(buf[:n-n%channels], channels) {
+			emit(ch, chains[ch].process(samples))
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if maxSamples >= 0 && produced[0] >= maxSamples {
+			break
+		}
+	}
+
+	for ch := range chains {
+		emit(ch, chains[ch].flush())
+	}
+	return produced[0], nil
+}
+
+// deinterleave splits interleaved samples into one slice per channel
+func deinterleave(samples []float32, channels int) [][]float32 {
+	result := make([][]float32, channels)
+	frames := len(samples) / channels
+	for ch := range result {
+		result[ch] = make([]float32, frames)
+		for i := 0; i < frames; i++ {
+			result[ch][i] = samples[i*channels+ch]
+		}
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChannels(t *testing.T) {
+	tests := []struct {
+		spec    string
+		mode    string
+		channel int
+	}{
+		{"", ChannelsMix, 0},
+		{"mix", ChannelsMix, 0},
+		{"split", ChannelsSplit, 0},
+		{"Loudest", ChannelsLoudest, 0},
+		{"left", ChannelsSingle, 1},
+		{"right", ChannelsSingle, 2},
+		{"3", ChannelsSingle, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			mode, channel, err := ParseChannels(test.spec)
+			require.NoError(t, err)
+			assert.Equal(t, test.mode, mode)
+			assert.Equal(t, test.channel, channel)
+		})
+	}
+
+	for _, spec := range []string{"0", "centre", "-1"} {
+		_, _, err := ParseChannels(spec)
+		assert.ErrorContains(t, err, "invalid channel selection", spec)
+	}
+}
+
+func TestLoadChannels(t *testing.T) {
+	// The left channel is quiet, the right one loud
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	require.NoError(t, os.WriteFile(path, testStereoWAV(0.1, -0.5, 100), 0644))
+
+	channels, err := LoadChannels(path, Options{Filter: FilterNone})
+	require.NoError(t, err)
+	require.Len(t, channels, 2)
+	assert.InDeltaSlice(t, repeat(0.1, 100), channels[0], 1e-4)
+	assert.InDeltaSlice(t, repeat(-0.5, 100), channels[1], 1e-4)
+
+	loudest, err := LoudestChannel(path, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, loudest)
+
+	t.Run("too long", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "long.wav")
+		require.NoError(t, os.WriteFile(path, testStereoWAV(0.1, -0.5, 2*SampleRate), 0644))
+
+		_, err := LoadChannels(path, Options{Filter: FilterNone, DurationLimit: time.Second})
+		assert.ErrorIs(t, err, ErrTooLong)
+		_, err = LoudestChannel(path, Options{DurationLimit: time.Second})
+		assert.ErrorIs(t, err, ErrTooLong)
+	})
+}
+
+func TestReadChannels(t *testing.T) {
+	wav := testStereoWAV(0.1, -0.5, 3*DefaultFrameSize)
+	decoder, err := newNativeDecoder(bufio.NewReader(bytes.NewReader(wav)))
+	require.NoError(t, err)
+
+	// Every block is passed on as it is decoded, up to the limit
+	var calls int
+	lengths := make([]int, 2)
+	chains := []stageChain{{}, {}}
+	samples, err := readChannels(decoder, chains, DefaultFrameSize+10, func(channel int, samples []float32) {
+		calls++
+		lengths[channel] += len(samples)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultFrameSize+10, samples)
+	assert.Equal(t, []int{DefaultFrameSize + 10, DefaultFrameSize + 10}, lengths)
+	assert.Equal(t, 4, calls)
+}
+
+func TestNewNativeStream_Channel(t *testing.T) {
+	wav := testStereoWAV(0.1, -0.5, 10)
+
+	stream, err := newNativeStream(bufio.NewReader(bytes.NewReader(wav)), 0, Options{Filter: FilterNone, Channel: 2})
+	require.NoError(t, err)
+	samples, err := stream.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, samples, 10)
+	assert.InDeltaSlice(t, repeat(-0.5, 10), samples, 1e-4)
+
+	_, err = newNativeStream(bufio.NewReader(bytes.NewReader(wav)), 0, Options{Channel: 3})
+	assert.ErrorContains(t, err, "there is no channel 3")
+}
+
+// testStereoWAV builds a 16kHz stereo WAV with a constant level per channel
+func testStereoWAV(left, right float64, frames int) []byte {
+	data := make([]byte, 0, frames*4)
+	for i := 0; i < frames; i++ {
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(left*(1<<15))))
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(right*(1<<15))))
+	}
+	return buildTestWAV(wavFormatPCM, 2, SampleRate, 16, data)
+}
+
+func repeat(value float32, n int) []float32 {
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = value
+	}
+	return samples
+}
@@ -0,0 +1,293 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxNoiseSuppression is the strongest accepted noise suppression in dB
+const MaxNoiseSuppression = 40
+
+// DefaultNoiseSuppression is a noise suppression which removes fan and
+// traffic noise without making speech sound hollow
+const DefaultNoiseSuppression = 20
+
+const (
+	// noiseFFTSize is the analysis frame length, 32ms at whisper's sample rate
+	noiseFFTSize = 512
+	// noiseHop is the distance of analysis frames, half a frame
+	noiseHop = noiseFFTSize / 2
+	// noiseProfileSamples is how much audio is held back at the start to
+	// estimate the noise from its non-speech frames
+	noiseProfileSamples = 2 * SampleRate
+	// noiseMinFrames is the fewest non-speech frames trusted for the profile,
+	// with fewer the quietest frames are used instead
+	noiseMinFrames = 8
+	// noiseUpdateMargin is how many dB above the noise a frame may be and
+	// still count as noise when the profile is updated
+	noiseUpdateMargin = 6
+	// noiseUpdateRate is the weight of a new noise frame in the profile
+	noiseUpdateRate = 0.05
+	// noiseSmoothing weighs the previous frame in the decision-directed
+	// estimate of the speech level, higher values avoid musical noise
+	noiseSmoothing = 0.98
+)
+
+// CheckNoiseSuppression validates a noise suppression in dB, zero disables it
+func CheckNoiseSuppression(db float64) error {
+	if db < 0 || db > MaxNoiseSuppression {
+		return fmt.Errorf("invalid noise suppression %g: use up to %d dB, e.g. %d, or 0 to disable it", db, MaxNoiseSuppression, DefaultNoiseSuppression)
+	}
+	return nil
+}
+
+// SuppressNoise reduces stationary background noise, e.g. fans or traffic,
+// by up to db decibels. The noise spectrum is estimated from the frames in
+// which voice activity detection finds no speech and every frame is
+// attenuated with a Wiener filter, the result is as long as the input.
+func SuppressNoise(samples []float32, db float64) []float32 {
+	suppressor := newNoiseSuppressor(db)
+	return append(suppressor.process(samples), suppressor.flush()...)
+}
+
+// noiseSuppressor is a streaming Wiener filter over short-time spectra. The
+// first noiseProfileSamples are held back to estimate the noise from their
+// non-speech frames, later frames close to the noise level keep updating it.
+type noiseSuppressor struct {
+	// gainFloor is the lowest gain, the strongest attenuation of a bin
+	gainFloor float64
+	window    []float64
+
+	profiled bool
+	held     []float32
+	noise    []float64
+	// speech is the estimated clean power of the previous frame per bin
+	speech []float64
+
+	in  []float32
+	acc []float64
+	// skip is the number of leading padding samples left to drop
+	skip     int
+	total    int
+	produced int
+	flushed  bool
+	spectrum []complex128
+}
+
+// newNoiseSuppressor creates a suppressor attenuating noise by up to db
+// decibels, zero passes the samples through unchanged
+func newNoiseSuppressor(db float64) *noiseSuppressor {
+	s := &noiseSuppressor{gainFloor: math.Pow(10, -db/20)}
+	if s.passthrough() {
+		return s
+	}
+
+	// A periodic Hann window sums to one at half a frame overlap
+	s.window = make([]float64, noiseFFTSize)
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/noiseFFTSize)
+	}
+	// Pad the start so the first samples are covered by two frames as well
+	s.in = make([]float32, noiseHop)
+	s.skip = noiseHop
+	s.acc = make([]float64, noiseFFTSize)
+	s.spectrum = make([]complex128, noiseFFTSize)
+	return s
+}
+
+// passthrough reports whether no suppression was requested
+func (s *noiseSuppressor) passthrough() bool {
+	return s.gainFloor >= 1
+}
+
+// process adds input samples and returns every output sample that can be
+// computed so far
+func (s *noiseSuppressor) process(in []float32) []float32 {
+	if s.passthrough() {
+		return in
+	}
+	s.total += len(in)
+
+	if !s.profiled {
+		s.held = append(s.held, in...)
+		if len(s.held) < noiseProfileSamples {
+			return nil
+		}
+		in = s.startFiltering()
+	}
+
+	s.in = append(s.in, in...)
+	return s.run()
+}
+
+// flush returns the remaining output, treating the input after the end as silence
+func (s *noiseSuppressor) flush() []float32 {
+	if s.passthrough() || s.flushed {
+		return nil
+	}
+	s.flushed = true
+
+	if !s.profiled {
+		if len(s.held) == 0 {
+			return nil
+		}
+		s.in = append(s.in, s.startFiltering()...)
+	}
+
+	s.in = append(s.in, make([]float32, noiseFFTSize)...)
+	out := s.run()
+	if excess := s.produced - s.total; excess > 0 {
+		out = out[:max(len(out)-excess, 0)]
+	}
+	return out
+}
+
+// startFiltering estimates the noise from the held back audio and returns it
+// for filtering
+func (s *noiseSuppressor) startFiltering() []float32 {
+	held := s.held
+	s.held = nil
+	s.profiled = true
+	// The profile does not depend on how the input was split into blocks
+	s.noise = noiseProfile(held[:min(len(held), noiseProfileSamples)], s.window)
+	s.speech = make([]float64, len(s.noise))
+	return held
+}
+
+// run filters every complete frame and returns the finished output
+func (s *noiseSuppressor) run() []float32 {
+	var out []float32
+	start := 0
+	for ; start+noiseFFTSize <= len(s.in); start += noiseHop {
+		s.filterFrame(s.in[start : start+noiseFFTSize])
+
+		// The first half of the accumulator has all its overlapping frames
+		done := make([]float32, noiseHop)
+		for i := range done {
+			done[i] = float32(s.acc[i])
+		}
+		copy(s.acc, s.acc[noiseHop:])
+		clear(s.acc[noiseHop:])
+
+		if s.skip > 0 {
+			n := min(s.skip, len(done))
+			done, s.skip = done[n:], s.skip-n
+		}
+		out = append(out, done...)
+	}
+	s.in = append([]float32(nil), s.in[start:]...)
+	s.produced += len(out)
+	return out
+}
+
+// filterFrame applies the Wiener gain to a frame and adds it to the accumulator
+func (s *noiseSuppressor) filterFrame(frame []float32) {
+	for i, v := range frame {
+		s.spectrum[i] = complex(float64(v)*s.window[i], 0)
+	}
+	fft(s.spectrum, false)
+
+	bins := len(s.noise)
+	power := make([]float64, bins)
+	var framePower, noisePower float64
+	for k := range power {
+		re, im := real(s.spectrum[k]), imag(s.spectrum[k])
+		power[k] = re*re + im*im
+		framePower += power[k]
+		noisePower += s.noise[k]
+	}
+
+	// Frames close to the noise level keep the profile up to date
+	if framePower <= noisePower*math.Pow(10, noiseUpdateMargin/10.0) {
+		for k := range s.noise {
+			s.noise[k] += noiseUpdateRate * (power[k] - s.noise[k])
+		}
+	}
+
+	for k := 0; k < bins; k++ {
+		noise := s.noise[k] + 1e-12
+		posterior := power[k] / noise
+		// Decision-directed a priori SNR from the previous clean estimate
+		prior := noiseSmoothing*s.speech[k]/noise + (1-noiseSmoothing)*math.Max(posterior-1, 0)
+		gain := math.Max(prior/(1+prior), s.gainFloor)
+		s.speech[k] = gain * gain * power[k]
+
+		s.spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < noiseFFTSize/2 {
+			// Keep the spectrum of a real signal symmetric
+			s.spectrum[noiseFFTSize-k] *= complex(gain, 0)
+		}
+	}
+
+	fft(s.spectrum, true)
+	for i := range frame {
+		s.acc[i] += real(s.spectrum[i]) / noiseFFTSize
+	}
+}
+
+// noiseProfile returns the mean power spectrum of the frames in which voice
+// activity detection finds no speech, or of the quietest frames when there
+// are too few of those
+func noiseProfile(samples []float32, window []float64) []float64 {
+	if len(samples) < noiseFFTSize {
+		samples = append(append([]float32(nil), samples...), make([]float32, noiseFFTSize-len(samples))...)
+	}
+
+	opts := DefaultVADOptions()
+	opts.Padding = 0
+	regions := DetectSpeech(samples, opts)
+	isSpeech := func(start int) bool {
+		for _, region := range regions {
+			if start < region.End && start+noiseFFTSize > region.Start {
+				return true
+			}
+		}
+		return false
+	}
+
+	var spectra, noiseSpectra [][]float64
+	spectrum := make([]complex128, noiseFFTSize)
+	for start := 0; start+noiseFFTSize <= len(samples); start += noiseHop {
+		for i := range spectrum {
+			spectrum[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		fft(spectrum, false)
+
+		power := make([]float64, noiseFFTSize/2+1)
+		for k := range power {
+			re, im := real(spectrum[k]), imag(spectrum[k])
+			power[k] = re*re + im*im
+		}
+		spectra = append(spectra, power)
+		if !isSpeech(start) {
+			noiseSpectra = append(noiseSpectra, power)
+		}
+	}
+
+	if len(noiseSpectra) < noiseMinFrames {
+		// Mostly speech, the quietest frames are the best guess of the noise
+		sort.Slice(spectra, func(i, j int) bool {
+			return sum(spectra[i]) < sum(spectra[j])
+		})
+		count := max(len(spectra)/10, min(noiseMinFrames, len(spectra)))
+		noiseSpectra = spectra[:count]
+	}
+
+	profile := make([]float64, noiseFFTSize/2+1)
+	for _, power := range noiseSpectra {
+		for k, p := range power {
+			profile[k] += p / float64(len(noiseSpectra))
+		}
+	}
+	return profile
+}
+
+// sum adds up the values
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,134 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFFT(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	x := make([]complex128, 64)
+	for i := range x {
+		x[i] = complex(rng.Float64()-0.5, rng.Float64()-0.5)
+	}
+
+	spectrum := append([]complex128(nil), x...)
+	fft(spectrum, false)
+	for k := range spectrum {
+		var expected complex128
+		for n, v := range x {
+			expected += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/float64(len(x))))
+		}
+		assert.InDelta(t, 0, cmplx.Abs(expected-spectrum[k]), 1e-9, "bin %d", k)
+	}
+
+	fft(spectrum, true)
+	for n := range x {
+		assert.InDelta(t, 0, cmplx.Abs(spectrum[n]/complex(float64(len(x)), 0)-x[n]), 1e-9, "sample %d", n)
+	}
+}
+
+// noisySpeech returns 6s of constant noise with a voice-like harmonic tone
+// from 3s to 5s, and the tone alone
+func noisySpeech() (noisy, clean []float32) {
+	rng := rand.New(rand.NewSource(1))
+	noisy = make([]float32, 6*SampleRate)
+	clean = make([]float32, len(noisy))
+	for i := range noisy {
+		if i >= 3*SampleRate && i < 5*SampleRate {
+			for harmonic := 1; harmonic <= 4; harmonic++ {
+				clean[i] += float32(0.3 / float64(harmonic) * math.Sin(2*math.Pi*200*float64(harmonic*i)/SampleRate))
+			}
+		}
+		noisy[i] = clean[i] + float32(0.03*rng.NormFloat64())
+	}
+	return noisy, clean
+}
+
+func TestSuppressNoise(t *testing.T) {
+	noisy, clean := noisySpeech()
+	out := SuppressNoise(noisy, DefaultNoiseSuppression)
+	require.Len(t, out, len(noisy))
+
+	level := func(samples []float32) float64 {
+		return 20 * math.Log10(rms(samples))
+	}
+	noiseOnly := func(samples []float32) []float32 { return samples[SampleRate/2 : 5*SampleRate/2] }
+	speech := func(samples []float32) []float32 {
+		return samples[3*SampleRate+SampleRate/4 : 5*SampleRate-SampleRate/4]
+	}
+
+	// The noise is attenuated by most of the requested 20dB
+	assert.Less(t, level(noiseOnly(out)), level(noiseOnly(noisy))-15)
+
+	// The speech is kept and closer to the clean tone than before
+	assert.InDelta(t, level(speech(clean)), level(speech(out)), 1.5)
+	difference := func(a, b []float32) float64 {
+		diff := make([]float32, len(a))
+		for i := range a {
+			diff[i] = a[i] - b[i]
+		}
+		return rms(diff)
+	}
+	assert.Less(t, difference(speech(out), speech(clean)), difference(speech(noisy), speech(clean)))
+
+	t.Run("blocks", func(t *testing.T) {
+		suppressor := newNoiseSuppressor(DefaultNoiseSuppression)
+		var streamed []float32
+		for start := 0; start < len(noisy); start += 777 {
+			streamed = append(streamed, suppressor.process(noisy[start:min(start+777, len(noisy))])...)
+		}
+		streamed = append(streamed, suppressor.flush()...)
+		assert.Equal(t, out, streamed)
+	})
+
+	t.Run("shorter than the profile", func(t *testing.T) {
+		short := SuppressNoise(noisy[:1000], DefaultNoiseSuppression)
+		assert.Len(t, short, 1000)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, noisy, SuppressNoise(noisy, 0))
+	})
+}
+
+func TestCheckNoiseSuppression(t *testing.T) {
+	assert.NoError(t, CheckNoiseSuppression(0))
+	assert.NoError(t, CheckNoiseSuppression(DefaultNoiseSuppression))
+	assert.ErrorContains(t, CheckNoiseSuppression(-3), "invalid noise suppression -3")
+	assert.ErrorContains(t, CheckNoiseSuppression(60), "invalid noise suppression 60")
+}
+
+func TestStreamNoiseSuppression(t *testing.T) {
+	noisy, _ := noisySpeech()
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, noisy)
+
+	opts := Options{
+		Raw:              &RawFormat{Encoding: "f32le", SampleRate: SampleRate, Channels: 1},
+		NoiseSuppression: DefaultNoiseSuppression,
+	}
+	samples, err := LoadAudioFromReaderWithOptions(&raw, opts)
+	require.NoError(t, err)
+	assert.Equal(t, SuppressNoise(noisy, DefaultNoiseSuppression), samples)
+}
+
+// rms returns the root mean square level of the samples
+func rms(samples []float32) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,115 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of decoding failures, match them with errors.Is
+var (
+	// ErrUnsupportedFormat is returned for inputs which are not audio or whose
+	// format is not known
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	// ErrCorrupt is returned for audio whose format is known but whose data is
+	// damaged or truncated
+	ErrCorrupt = errors.New("corrupt audio data")
+	// ErrEmpty is returned for audio without samples or with nothing but silence
+	ErrEmpty = errors.New("empty audio")
+	// ErrTooLong is returned for audio exceeding Options.DurationLimit
+	ErrTooLong = errors.New("audio too long")
+	// ErrDecoderMissing is returned when decoding needs FFmpeg or an FFmpeg
+	// codec which is not available
+	ErrDecoderMissing = errors.New("audio decoder missing")
+)
+
+// silenceLevel is the peak level at or below which audio counts as silent,
+// one step of 16-bit audio
+const silenceLevel = 1.0 / 32768
+
+// kindError classifies an error without changing its message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// errorf formats an error which errors.Is matches against kind
+func errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// FFmpegError is returned when the FFmpeg process fails, errors.As gives
+// access to FFmpeg's output
+type FFmpegError struct {
+	// Kind is what the failure was classified as, nil if it is not known
+	Kind error
+	// Output is FFmpeg's error output
+	Output string
+	// Err is the error of the FFmpeg process
+	Err error
+}
+
+func (e *FFmpegError) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("ffmpeg error: %v (output: %q)", e.Err, e.Output)
+	}
+	if line := firstLine(e.Output); line != "" {
+		return fmt.Sprintf("%v: %s", e.Kind, line)
+	}
+	return e.Kind.Error()
+}
+
+func (e *FFmpegError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// firstLine returns the first non-empty line of the text
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// CheckAudible returns ErrEmpty when there are no samples or all of them are silent
+func CheckAudible(samples []float32) error {
+	return audibleError(len(samples), peakLevel(samples))
+}
+
+// audibleError returns ErrEmpty for no samples or a peak level of silence
+func audibleError(samples int, peak float32) error {
+	if samples == 0 {
+		return errorf(ErrEmpty, "empty audio: no samples decoded")
+	}
+	if peak <= silenceLevel {
+		return errorf(ErrEmpty, "empty audio: the audio is silent")
+	}
+	return nil
+}
+
+// peakLevel returns the highest absolute sample value
+func peakLevel(samples []float32) float32 {
+	var peak float32
+	for _, s := range samples {
+		if s < 0 {
+			s = -s
+		}
+		if s > peak {
+			peak = s
+		}
+	}
+	return peak
+}
@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKinds(t *testing.T) {
+	decode := func(data []byte) error {
+		stream, err := newNativeStream(bufio.NewReader(bytes.NewReader(data)), 0, Options{})
+		if err != nil {
+			return err
+		}
+		_, err = stream.ReadAll()
+		return err
+	}
+
+	assert.ErrorIs(t, decode([]byte("not audio at all")), ErrUnsupportedFormat)
+	assert.ErrorIs(t, decode([]byte("fLaC\x00\x00")), ErrCorrupt)
+	assert.ErrorIs(t, decode(buildTestWAV(0x0011, 1, SampleRate, 4, make([]byte, 16))), ErrDecoderMissing)
+	assert.ErrorIs(t, decode(buildTestWAV(wavFormatPCM, 1, SampleRate, 16, nil)), ErrEmpty)
+
+	// Messages are kept, the kind is only attached
+	err := decode([]byte("fLaC\x00\x00"))
+	assert.ErrorContains(t, err, "invalid FLAC file")
+	assert.NotErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestCheckAudible(t *testing.T) {
+	assert.ErrorIs(t, CheckAudible(nil), ErrEmpty)
+	assert.ErrorIs(t, CheckAudible(make([]float32, 100)), ErrEmpty)
+	assert.ErrorContains(t, CheckAudible([]float32{0, 1e-6, -1e-6}), "silent")
+	assert.NoError(t, CheckAudible([]float32{0, 0.01, -0.2}))
+}
@@ -0,0 +1,293 @@
+//go:build !noffmpeg
+
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// newFFmpegStream decodes the input with an FFmpeg process, the container
+// found by SniffContainer tells FFmpeg the input format
+func newFFmpegStream(input io.Reader, container string, frameSize int, opts Options) (*Stream, error) {
+	var errBuf bytes.Buffer // Capture FFmpeg's stderr
+	cmd := ffmpegCommand(opts, container, 1).
+		WithInput(input).
+		WithErrorOutput(&errBuf).
+		Compile()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ffmpeg output pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errorf(ErrDecoderMissing, "ffmpeg error: %w", err)
+	}
+
+	s := newStream(opts, func() {
+		cmd.Process.Kill()
+	})
+
+	go func() {
+		defer s.finish()
+
+		readErr := s.readFrames(stdout, frameSize)
+		waitErr := cmd.Wait()
+
+		switch {
+		case s.stopped():
+			// Errors caused by stopping the decoder early are not interesting
+		case waitErr != nil:
+			s.err = ffmpegError(waitErr, errBuf.String())
+		case readErr != nil:
+			s.err = readErr
+		}
+	}()
+
+	return s, nil
+}
+
+// ffmpegAvailable reports whether the ffmpeg binary can be found
+func ffmpegAvailable() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+// ffmpegCommand builds the FFmpeg invocation converting any input to whisper's
+// sample rate with the given number of output channels
+func ffmpegCommand(opts Options, container string, channels int) *ffmpeg.Stream {
+	inputArgs := ffmpeg.KwArgs{}
+	if opts.Raw != nil {
+		// Raw PCM has no header to learn the format from
+		inputArgs["f"] = opts.Raw.Encoding
+		inputArgs["ar"] = opts.Raw.SampleRate
+		inputArgs["ac"] = opts.Raw.Channels
+	} else if demuxer := ffmpegDemuxer(container); demuxer != "" {
+		// Piped input cannot be probed by file name
+		inputArgs["f"] = demuxer
+	}
+
+	outputArgs := ffmpeg.KwArgs{
+		"f":           "f32le",
+		"ar":          SampleRate,
+		"ac":          channels,
+		"loglevel":    "error",
+		"hide_banner": "",
+	}
+
+	// Resample first, then apply the requested preprocessing
+	filters := "aresample=16000"
+	if opts.Channel > 0 {
+		// Keep only the selected channel instead of mixing
+		filters = fmt.Sprintf("pan=mono|c0=c%d,%s", opts.Channel-1, filters)
+	}
+	if preset, _ := ParseFilter(effectiveFilter(opts)); preset != "" {
+		filters += "," + preset
+	}
+	if tempo := opts.tempo(); tempo != 1 {
+		filters += "," + atempoFilter(tempo)
+	}
+	outputArgs["af"] = filters
+
+	if opts.Stream > 0 {
+		// Decode the selected audio stream instead of FFmpeg's pick
+		outputArgs["map"] = fmt.Sprintf("0:a:%d", opts.Stream-1)
+	}
+
+	if opts.MaxDuration > 0 {
+		// Stop decoding once enough audio has been produced
+		outputArgs["t"] = fmt.Sprintf("%.3f", opts.MaxDuration.Seconds())
+	}
+
+	return ffmpeg.Input("pipe:0", inputArgs).Output("pipe:1", outputArgs)
+}
+
+// atempoFilter returns FFmpeg's tempo filter, chained for factors beyond the
+// range of a single atempo instance in older FFmpeg versions
+func atempoFilter(tempo float64) string {
+	var filters []string
+	for tempo > 2 {
+		filters = append(filters, "atempo=2")
+		tempo /= 2
+	}
+	filters = append(filters, fmt.Sprintf("atempo=%g", tempo))
+	return strings.Join(filters, ",")
+}
+
+// loadChannelsFFmpeg decodes every channel of the file with FFmpeg, reading
+// its output as it is produced
+func loadChannelsFFmpeg(filePath string, input io.Reader, container string, opts Options, sink channelSink) (int, error) {
+	channels, err := inputChannels(filePath, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var errBuf bytes.Buffer
+	cmd := ffmpegCommand(opts, container, channels).
+		WithInput(input).
+		WithErrorOutput(&errBuf).
+		Compile()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create ffmpeg output pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, errorf(ErrDecoderMissing, "ffmpeg error: %w", err)
+	}
+
+	// FFmpeg already resampled, filtered and changed the tempo
+	chains := make([]stageChain, channels)
+	for ch := range chains {
+		chains[ch] = stageChain{newNoiseSuppressor(opts.NoiseSuppression)}
+	}
+	output := newRawDecoder(stdout, RawFormat{Encoding: "f32le", SampleRate: SampleRate, Channels: channels})
+	maxSamples := channelLimit(opts)
+	samples, readErr := readChannels(output, chains, maxSamples, sink)
+
+	// FFmpeg is stopped once enough was read
+	stopped := readErr == nil && maxSamples >= 0 && samples >= maxSamples
+	if stopped {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case stopped:
+		// Errors caused by stopping FFmpeg early are not interesting
+	case waitErr != nil:
+		return 0, ffmpegError(waitErr, errBuf.String())
+	case readErr != nil:
+		return 0, readErr
+	}
+	return samples, nil
+}
+
+// inputChannels returns the channel count of raw PCM or probes the file for it
+func inputChannels(filePath string, opts Options) (int, error) {
+	if opts.Raw != nil {
+		return opts.Raw.Channels, nil
+	}
+	return probeChannels(filePath, opts.Stream)
+}
+
+// probeChannels asks ffprobe for the channel count of the selected audio
+// stream, or of the first one when none is selected
+func probeChannels(filePath string, stream int) (int, error) {
+	info, err := probeFFmpeg(filePath)
+	if err != nil {
+		return 0, err
+	}
+	if stream < 1 {
+		stream = 1
+	}
+	if stream > len(info.Tracks) {
+		return 0, fmt.Errorf("invalid audio stream %d: the file has %d audio streams, counting from 1", stream, len(info.Tracks))
+	}
+	if info.Tracks[stream-1].Channels < 1 {
+		return 0, errorf(ErrUnsupportedFormat, "unsupported audio format: audio stream %d has no channels", stream)
+	}
+	return info.Tracks[stream-1].Channels, nil
+}
+
+// probeFFmpeg asks ffprobe for the container and the audio streams of the file
+func probeFFmpeg(filePath string) (*ProbeInfo, error) {
+	output, err := ffmpeg.Probe(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %w", err)
+	}
+	return parseProbe(output)
+}
+
+// parseProbe reads the container and the audio streams from ffprobe's JSON output
+func parseProbe(output string) (*ProbeInfo, error) {
+	var probe struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+			BitRate    string `json:"bit_rate"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType   string `json:"codec_type"`
+			CodecName   string `json:"codec_name"`
+			Channels    int    `json:"channels"`
+			SampleRate  string `json:"sample_rate"`
+			BitRate     string `json:"bit_rate"`
+			Disposition struct {
+				Default int `json:"default"`
+			} `json:"disposition"`
+			Tags struct {
+				Language string `json:"language"`
+				Title    string `json:"title"`
+			} `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal([]byte(output), &probe); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+
+	// ffprobe reports numbers as strings, missing values stay zero
+	info := &ProbeInfo{Container: probe.Format.FormatName}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	info.BitRate, _ = strconv.Atoi(probe.Format.BitRate)
+
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "audio" {
+			continue
+		}
+		sampleRate, _ := strconv.Atoi(stream.SampleRate)
+		bitRate, _ := strconv.Atoi(stream.BitRate)
+		language := stream.Tags.Language
+		if baseLanguage(language) == "" {
+			language = ""
+		}
+		info.Tracks = append(info.Tracks, AudioTrack{
+			Number:     len(info.Tracks) + 1,
+			Codec:      stream.CodecName,
+			Language:   language,
+			Title:      stream.Tags.Title,
+			Channels:   stream.Channels,
+			SampleRate: sampleRate,
+			BitRate:    bitRate,
+			Default:    stream.Disposition.Default == 1,
+		})
+	}
+	if len(info.Tracks) == 0 {
+		return nil, errorf(ErrUnsupportedFormat, "unsupported audio format: no audio stream found")
+	}
+	return info, nil
+}
+
+// ffmpegFailures classifies FFmpeg's error output, the first match wins
+var ffmpegFailures = []struct {
+	kind     error
+	messages []string
+}{
+	{ErrDecoderMissing, []string{"decoder (codec", "unknown decoder", "no decoder"}},
+	{os.ErrPermission, []string{"operation not permitted", "permission denied"}},
+	{ErrCorrupt, []string{"moov atom not found", "error while decoding", "invalid frame", "corrupt", "truncat", "invalid packet"}},
+	{ErrUnsupportedFormat, []string{"invalid data found", "unknown input format", "matches no streams", "does not contain any stream"}},
+}
+
+// ffmpegError analyses FFmpeg's error output to explain a failed conversion
+func ffmpegError(err error, stderr string) error {
+	output := strings.TrimSpace(stderr)
+	errorMsg := strings.ToLower(output)
+	for _, failure := range ffmpegFailures {
+		for _, message := range failure.messages {
+			if strings.Contains(errorMsg, message) {
+				return &FFmpegError{Kind: failure.kind, Output: output, Err: err}
+			}
+		}
+	}
+	return &FFmpegError{Output: output, Err: err}
+}
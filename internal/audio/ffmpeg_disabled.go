@@ -0,0 +1,27 @@
+//go:build noffmpeg
+
+package audio
+
+import (
+	"io"
+)
+
+// ffmpegAvailable is always false, this build decodes audio in-process only
+func ffmpegAvailable() bool {
+	return false
+}
+
+// newFFmpegStream is never reached since FFmpeg is never available
+func newFFmpegStream(input io.Reader, container string, frameSize int, opts Options) (*Stream, error) {
+	return nil, errorf(ErrDecoderMissing, "unsupported audio format: this build does not use ffmpeg")
+}
+
+// loadChannelsFFmpeg is never reached since FFmpeg is never available
+func loadChannelsFFmpeg(filePath string, input io.Reader, container string, opts Options, sink channelSink) (int, error) {
+	return 0, errorf(ErrDecoderMissing, "unsupported audio format: this build does not use ffmpeg")
+}
+
+// probeFFmpeg is never reached since FFmpeg is never available
+func probeFFmpeg(filePath string) (*ProbeInfo, error) {
+	return nil, errorf(ErrDecoderMissing, "unsupported audio format: this build does not use ffmpeg")
+}
@@ -0,0 +1,111 @@
+//go:build !noffmpeg
+
+package audio
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFFmpegCommand(t *testing.T) {
+	t.Run("default normalizes", func(t *testing.T) {
+		args := ffmpegCommand(Options{}, "", 1).GetArgs()
+		assert.Contains(t, args, "aresample=16000,dynaudnorm")
+	})
+
+	t.Run("presets follow resampling", func(t *testing.T) {
+		args := ffmpegCommand(Options{Filter: "highpass,denoise"}, "", 1).GetArgs()
+		assert.Contains(t, args, "aresample=16000,highpass=f=100,afftdn")
+	})
+
+	t.Run("none only resamples", func(t *testing.T) {
+		args := ffmpegCommand(Options{Filter: FilterNone, MaxDuration: 30 * time.Second}, "", 1).GetArgs()
+		assert.Contains(t, args, "aresample=16000")
+		assert.Contains(t, args, "30.000")
+	})
+
+	t.Run("single channel", func(t *testing.T) {
+		args := ffmpegCommand(Options{Channel: 2}, "", 1).GetArgs()
+		assert.Contains(t, args, "pan=mono|c0=c1,aresample=16000,dynaudnorm")
+	})
+
+	t.Run("audio stream", func(t *testing.T) {
+		args := ffmpegCommand(Options{Stream: 2}, "", 1).GetArgs()
+		assert.Contains(t, args, "0:a:1")
+	})
+
+	t.Run("input format hint", func(t *testing.T) {
+		args := ffmpegCommand(Options{}, "matroska", 1).GetArgs()
+		assert.Equal(t, []string{"-f", "matroska", "-i", "pipe:0"}, args[:4])
+
+		args = ffmpegCommand(Options{}, "", 1).GetArgs()
+		assert.Equal(t, []string{"-i", "pipe:0"}, args[:2])
+	})
+
+	t.Run("tempo", func(t *testing.T) {
+		args := ffmpegCommand(Options{Filter: FilterNone, Tempo: 1.5}, "", 1).GetArgs()
+		assert.Contains(t, args, "aresample=16000,atempo=1.5")
+
+		// Older FFmpeg versions accept at most 2 per atempo instance
+		args = ffmpegCommand(Options{Filter: FilterNone, Tempo: 3}, "", 1).GetArgs()
+		assert.Contains(t, args, "aresample=16000,atempo=2,atempo=1.5")
+	})
+
+	t.Run("raw input", func(t *testing.T) {
+		opts := Options{Raw: &RawFormat{Encoding: "s16le", SampleRate: 8000, Channels: 1}}
+		args := ffmpegCommand(opts, "wav", 1).GetArgs()
+		assert.Equal(t, []string{"-f", "s16le", "-ac", "1", "-ar", "8000", "-i", "pipe:0"}, args[:8])
+	})
+}
+
+func TestParseProbe(t *testing.T) {
+	output := `{"format": {"format_name": "matroska,webm", "duration": "62.500000", "bit_rate": "1200000"},
+	"streams": [
+		{"codec_type": "video", "codec_name": "h264"},
+		{"codec_type": "audio", "codec_name": "aac", "channels": 6, "sample_rate": "48000", "bit_rate": "384000",
+		 "disposition": {"default": 1}, "tags": {"language": "eng"}},
+		{"codec_type": "audio", "codec_name": "ac3", "channels": 2, "sample_rate": "44100",
+		 "disposition": {"default": 0}, "tags": {"language": "und", "title": "Commentary"}}
+	]}`
+
+	info, err := parseProbe(output)
+	require.NoError(t, err)
+	assert.Equal(t, "matroska,webm", info.Container)
+	assert.Equal(t, 62500*time.Millisecond, info.Duration)
+	assert.Equal(t, 1200000, info.BitRate)
+	assert.Equal(t, []AudioTrack{
+		{Number: 1, Codec: "aac", Language: "eng", Channels: 6, SampleRate: 48000, BitRate: 384000, Default: true},
+		{Number: 2, Codec: "ac3", Title: "Commentary", Channels: 2, SampleRate: 44100},
+	}, info.Tracks)
+
+	_, err = parseProbe(`{"streams": [{"codec_type": "video"}]}`)
+	assert.ErrorContains(t, err, "no audio stream")
+}
+
+func TestFFmpegError(t *testing.T) {
+	tests := []struct {
+		output string
+		kind   error
+	}{
+		{"pipe:0: Invalid data found when processing input", ErrUnsupportedFormat},
+		{"[mov,mp4,m4a] moov atom not found\npipe:0: Invalid data found when processing input", ErrCorrupt},
+		{"Decoder (codec ac4) not found for input stream #0:1", ErrDecoderMissing},
+		{"pipe:0: Operation not permitted", os.ErrPermission},
+	}
+	for _, test := range tests {
+		err := ffmpegError(errors.New("exit status 1"), test.output)
+		assert.ErrorIs(t, err, test.kind, test.output)
+
+		var ffmpegErr *FFmpegError
+		require.ErrorAs(t, err, &ffmpegErr)
+		assert.Equal(t, test.output, ffmpegErr.Output)
+	}
+
+	err := ffmpegError(errors.New("exit status 1"), "something else")
+	assert.EqualError(t, err, `ffmpeg error: exit status 1 (output: "something else")`)
+}
@@ -0,0 +1,36 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fft transforms x in place with the iterative radix-2 algorithm, the length
+// must be a power of two. The inverse transform is not scaled.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	shift := bits.UintSize - bits.Len(uint(n-1))
+	for i := range x {
+		if j := int(bits.Reverse(uint(i)) >> shift); i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		angle := sign * 2 * math.Pi / float64(size)
+		step := complex(math.Cos(angle), math.Sin(angle))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Audio filter presets, combined as a comma separated list
+const (
+	// FilterNone only resamples
+	FilterNone = "none"
+	// FilterNormalize evens out the volume over time, in-process when the
+	// audio is not decoded by FFmpeg
+	FilterNormalize = "normalize"
+	// FilterHighpass removes rumble and hum below the speech band
+	FilterHighpass = "highpass"
+	// FilterDenoise reduces stationary background noise
+	FilterDenoise = "denoise"
+	// FilterLoudnorm normalizes the loudness to EBU R128
+	FilterLoudnorm = "loudnorm"
+)
+
+// DefaultFilter is the preprocessing used when none is requested
+const DefaultFilter = FilterNormalize
+
+// customFilterPrefix marks a raw FFmpeg filter graph
+const customFilterPrefix = "custom:"
+
+// filterPresets maps the presets to FFmpeg filters
+var filterPresets = map[string]string{
+	FilterNormalize: "dynaudnorm",
+	FilterHighpass:  "highpass=f=100",
+	FilterDenoise:   "afftdn",
+	FilterLoudnorm:  "loudnorm=I=-16:TP=-1.5:LRA=11",
+}
+
+// FilterPresets lists the preset names in a stable order, e.g. for help texts
+var FilterPresets = []string{FilterNone, FilterNormalize, FilterHighpass, FilterDenoise, FilterLoudnorm}
+
+// ParseFilter validates an audio filter specification and returns the FFmpeg
+// filters it applies after resampling. The specification is a comma separated
+// list of presets applied in order, e.g. "highpass,denoise,loudnorm", or a raw
+// FFmpeg filter graph prefixed with "custom:". An empty specification selects
+// DefaultFilter.
+func ParseFilter(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = DefaultFilter
+	}
+
+	if strings.HasPrefix(spec, customFilterPrefix) {
+		custom := strings.TrimSpace(strings.TrimPrefix(spec, customFilterPrefix))
+		if custom == "" {
+			return "", fmt.Errorf("invalid audio filter: empty custom filter")
+		}
+		return custom, nil
+	}
+
+	presets := strings.Split(spec, ",")
+	var filters []string
+	for _, preset := range presets {
+		preset = strings.ToLower(strings.TrimSpace(preset))
+		if preset == FilterNone {
+			if len(presets) > 1 {
+				return "", fmt.Errorf("invalid audio filter: %q cannot be combined with other presets", FilterNone)
+			}
+			return "", nil
+		}
+
+		filter, ok := filterPresets[preset]
+		if !ok {
+			return "", fmt.Errorf("invalid audio filter: unknown preset %q (available: %s, or %s<ffmpeg filters>)",
+				preset, strings.Join(FilterPresets, ", "), customFilterPrefix)
+		}
+		filters = append(filters, filter)
+	}
+
+	return strings.Join(filters, ","), nil
+}
+
+// ParsePresetFilter is ParseFilter for untrusted specifications, it only
+// accepts presets as a custom filter graph can make FFmpeg read or write
+// arbitrary files
+func ParsePresetFilter(spec string) (string, error) {
+	if strings.HasPrefix(strings.TrimSpace(spec), customFilterPrefix) {
+		return "", fmt.Errorf("invalid audio filter: custom filters are not allowed, use presets (available: %s)",
+			strings.Join(FilterPresets, ", "))
+	}
+	return ParseFilter(spec)
+}
+
+// effectiveFilter returns the preprocessing specification of the options,
+// noise suppression replaces the default normalization as it would amplify
+// the noise between words
+func effectiveFilter(opts Options) string {
+	if strings.TrimSpace(opts.Filter) == "" && opts.NoiseSuppression > 0 {
+		return FilterNone
+	}
+	return opts.Filter
+}
+
+// normalizes reports whether the preprocessing of the options is the
+// normalization alone, which decoders other than FFmpeg apply in-process
+func normalizes(opts Options) bool {
+	filter, err := ParseFilter(effectiveFilter(opts))
+	return err == nil && filter == filterPresets[FilterNormalize]
+}
+
+// requestsFilter reports whether the specification asks for preprocessing
+// only FFmpeg can apply, anything but none or the normalization alone
+func requestsFilter(spec string) bool {
+	filter, err := ParseFilter(spec)
+	return err == nil && filter != "" && filter != filterPresets[FilterNormalize]
+}
@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"", "dynaudnorm"},
+		{"none", ""},
+		{"normalize", "dynaudnorm"},
+		{"highpass, denoise,Loudnorm", "highpass=f=100,afftdn,loudnorm=I=-16:TP=-1.5:LRA=11"},
+		{"custom:highpass=f=300,afftdn=nf=-25", "highpass=f=300,afftdn=nf=-25"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			filters, err := ParseFilter(test.spec)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, filters)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, spec := range []string{"echo", "none,denoise", "custom:"} {
+			_, err := ParseFilter(spec)
+			assert.ErrorContains(t, err, "invalid audio filter", spec)
+		}
+	})
+}
+
+func TestParsePresetFilter(t *testing.T) {
+	filters, err := ParsePresetFilter("highpass,denoise")
+	require.NoError(t, err)
+	assert.Equal(t, "highpass=f=100,afftdn", filters)
+
+	for _, spec := range []string{"custom:amovie=/etc/passwd", " custom:anull", "echo"} {
+		_, err := ParsePresetFilter(spec)
+		assert.ErrorContains(t, err, "invalid audio filter", spec)
+	}
+}
+
+func TestRequestsFilter(t *testing.T) {
+	for _, spec := range []string{"", "none", "normalize", " Normalize "} {
+		assert.False(t, requestsFilter(spec), spec)
+	}
+	for _, spec := range []string{"highpass", "normalize,denoise", "custom:anull"} {
+		assert.True(t, requestsFilter(spec), spec)
+	}
+}
+
+func TestNewStream_FilterWithoutFFmpeg(t *testing.T) {
+	if ffmpegAvailable() {
+		t.Skip("ffmpeg is installed")
+	}
+
+	wav := buildTestWAV(wavFormatPCM, 1, SampleRate, 16, make([]byte, 64))
+	_, err := NewStream(bytes.NewReader(wav), 0, Options{Filter: FilterDenoise})
+	assert.ErrorContains(t, err, `unsupported audio filter: "denoise" needs ffmpeg`)
+
+	// Without a filter the audio is decoded in-process
+	stream, err := NewStream(bytes.NewReader(wav), 0, Options{Filter: FilterNone})
+	require.NoError(t, err)
+	samples, err := stream.ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, samples, 32)
+
+	// The normalization is applied in-process
+	stream, err = NewStream(bytes.NewReader(wav), 0, Options{Filter: FilterNormalize})
+	require.NoError(t, err)
+	samples, err = stream.ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, samples, 32)
+}
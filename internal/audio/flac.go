@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"io"
+
+	"github.com/mewkiz/flac"
+)
+
+// flacDecoder decodes FLAC frames one at a time
+type flacDecoder struct {
+	stream  *flac.Stream
+	scale   float32
+	pending []float32
+}
+
+// newFLACDecoder parses the FLAC metadata and prepares to decode frames
+func newFLACDecoder(input io.Reader) (*flacDecoder, error) {
+	stream, err := flac.New(input)
+	if err != nil {
+		return nil, errorf(ErrCorrupt, "invalid FLAC file: %w", err)
+	}
+	if stream.Info.NChannels == 0 || stream.Info.SampleRate == 0 {
+		return nil, errorf(ErrCorrupt, "invalid FLAC file: %d channels at %d Hz", stream.Info.NChannels, stream.Info.SampleRate)
+	}
+
+	return &flacDecoder{
+		stream: stream,
+		scale:  float32(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+func (d *flacDecoder) sampleRate() int { return int(d.stream.Info.SampleRate) }
+
+func (d *flacDecoder) channels() int { return int(d.stream.Info.NChannels) }
+
+func (d *flacDecoder) length() int64 { return int64(d.stream.Info.NSamples) }
+
+func (d *flacDecoder) read(p []float32) (int, error) {
+	for len(d.pending) == 0 {
+		frame, err := d.stream.ParseNext()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, errorf(ErrCorrupt, "failed to decode FLAC: %w", err)
+		}
+
+		// Interleave the subframes, one per channel
+		channels := len(frame.Subframes)
+		for i := 0; i < int(frame.BlockSize); i++ {
+			for ch := 0; ch < channels; ch++ {
+				d.pending = append(d.pending, float32(frame.Subframes[ch].Samples[i])/d.scale)
+			}
+		}
+	}
+
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
@@ -1,107 +1,83 @@
 package audio
 
 import (
-	"bytes"
-	"fmt"
 	"io"
-	"os"
-	"path/filepath"
-	"strings"
-	"unsafe"
-
-	ffmpeg "github.com/u2takey/ffmpeg-go"
+	"time"
 )
 
 // SampleRate is the sample rate expected by Whisper
 const SampleRate = 16000
 
+// Options controls how audio is decoded
+type Options struct {
+	// MaxDuration limits decoding to the beginning of the input, zero decodes everything
+	MaxDuration time.Duration
+	// Filter is the FFmpeg preprocessing, see ParseFilter, empty uses DefaultFilter
+	Filter string
+	// Channel selects a single channel counting from 1, zero mixes all channels
+	Channel int
+	// DurationLimit fails decoding with ErrTooLong once the audio gets longer,
+	// zero allows any length. Unlike MaxDuration the audio is not truncated.
+	DurationLimit time.Duration
+	// Stream selects an audio stream of a file with several, counting from 1 as
+	// listed by ProbeTracks, zero leaves the choice to FFmpeg
+	Stream int
+	// Raw describes headerless PCM input, nil detects the format from the
+	// content
+	Raw *RawFormat
+	// Tempo speeds the audio up by this factor without changing the pitch,
+	// e.g. 1.25, zero or one leaves it unchanged. Timestamps of the decoded
+	// audio map back to the input with OriginalTime, MaxDuration refers to
+	// the decoded audio and DurationLimit to the input.
+	Tempo float64
+	// NoiseSuppression attenuates stationary background noise in-process by
+	// up to this many dB after the tempo change, zero disables it. The default
+	// normalization is skipped as it amplifies the noise between words.
+	NoiseSuppression float64
+}
+
 // LoadAudioFile loads an audio file and returns the samples as float32 values
 func LoadAudioFile(filePath string) ([]float32, error) {
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("failed to open audio file: file does not exist")
-	}
+	return LoadAudioFileWithOptions(filePath, Options{})
+}
 
-	file, err := os.Open(filePath)
+// LoadAudioFileWithOptions loads an audio file using the given decoding
+// options, StdinPath reads standard input
+func LoadAudioFileWithOptions(filePath string, opts Options) ([]float32, error) {
+	file, err := openInput(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open audio file: %w", err)
+		return nil, err
 	}
 	defer file.Close()
-	
-	return convertAudioWithFFmpeg(file)
+
+	return convertAudioWithFFmpeg(file, opts)
 }
 
 // LoadAudioFromReader loads audio from an io.Reader
 func LoadAudioFromReader(reader io.Reader) ([]float32, error) {
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read audio data: %w", err)
-	}
-	return convertAudioWithFFmpeg(bytes.NewReader(data))
+	return LoadAudioFromReaderWithOptions(reader, Options{})
+}
+
+// LoadAudioFromReaderWithOptions loads audio from an io.Reader using the given
+// decoding options, e.g. Options.Raw for input without a container header
+func LoadAudioFromReaderWithOptions(reader io.Reader, opts Options) ([]float32, error) {
+	return convertAudioWithFFmpeg(reader, opts)
 }
 
 // convertAudioWithFFmpeg converts audio from any format to float32 samples
 // using FFmpeg for maximum compatibility with different audio formats
-func convertAudioWithFFmpeg(input io.Reader) ([]float32, error) {
-	var buf bytes.Buffer
-	var errBuf bytes.Buffer // Add buffer to capture stderr
-	
-	cmd := ffmpeg.Input("pipe:0").
-		Output("pipe:1", ffmpeg.KwArgs{
-			"f":           "f32le",
-			"ar":          SampleRate,
-			"ac":          1,
-			"loglevel":    "error",
-			"hide_banner": "",
-			"af":          "aresample=16000,dynaudnorm", // Add resampling and normalization
-		}).
-		WithInput(input).
-		WithOutput(&buf).
-		WithErrorOutput(&errBuf) // Capture FFmpeg's stderr
-
-	err := cmd.Run()
-	
+func convertAudioWithFFmpeg(input io.Reader, opts Options) ([]float32, error) {
+	stream, err := NewStream(input, DefaultFrameSize, opts)
 	if err != nil {
-		// Analyze FFmpeg's error output
-		errorMsg := strings.ToLower(errBuf.String())
-		switch {
-		case strings.Contains(errorMsg, "invalid data found"):
-			return nil, fmt.Errorf("unsupported audio format")
-		case strings.Contains(errorMsg, "operation not permitted"):
-			return nil, fmt.Errorf("permission denied")
-		default:
-			return nil, fmt.Errorf("ffmpeg error: %w (output: %q)", err, strings.TrimSpace(errorMsg))
-		}
+		return nil, err
 	}
 
-	// Convert byte buffer to float32 samples
-	raw := buf.Bytes()
-	if len(raw)%4 != 0 {
-		return nil, fmt.Errorf("invalid f32le byte length: %d", len(raw))
-	}
-
-	samples := make([]float32, len(raw)/4)
-	for i := 0; i < len(samples); i++ {
-		// Convert little-endian bytes to float32
-		bytes := raw[i*4 : i*4+4]
-		bits := uint32(bytes[0]) | uint32(bytes[1])<<8 | uint32(bytes[2])<<16 | uint32(bytes[3])<<24
-		samples[i] = *(*float32)(unsafe.Pointer(&bits))
-	}
-
-	return samples, nil
+	return stream.ReadAll()
 }
 
-// IsSupportedAudioFormat checks if the file extension is a commonly supported audio format
+// IsSupportedAudioFormat checks if the file content is a known audio or video
+// container, the file extension is ignored
 func IsSupportedAudioFormat(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	supportedFormats := []string{
-		".wav", ".mp3", ".ogg", ".flac", ".m4a", ".aac", ".wma", ".aiff", ".opus",
-	}
-
-	for _, format := range supportedFormats {
-		if ext == format {
-			return true
-		}
-	}
-	return false
+	container, err := SniffFile(filePath)
+	return err == nil && container != ""
 }
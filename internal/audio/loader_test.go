@@ -27,7 +27,7 @@ func TestLoadAudioFile(t *testing.T) {
 		os.WriteFile(testFile, []byte("invalid data"), 0644)
 
 		_, err := LoadAudioFile(testFile)
-		assert.ErrorContains(t, err, "unsupported audio format", 
+		assert.ErrorContains(t, err, "unsupported audio format",
 			"Should detect invalid format from FFmpeg error")
 	})
 
@@ -38,28 +38,29 @@ func TestLoadAudioFile(t *testing.T) {
 }
 
 func TestIsSupportedAudioFormat(t *testing.T) {
+	dir := t.TempDir()
 	tests := []struct {
-		filePath string
+		name     string
+		content  []byte
 		expected bool
 	}{
-		{"test.wav", true},
-		{"test.mp3", true},
-		{"test.ogg", true},
-		{"test.flac", true},
-		{"test.m4a", true},
-		{"test.txt", false},
-		{"test.pdf", false},
-		{"test", false},
-		{"/path/to/audio.wav", true},
-		{"/path/to/document.docx", false},
+		{"audio.wav", buildTestWAV(wavFormatPCM, 1, SampleRate, 16, make([]byte, 64)), true},
+		// The extension does not matter, the content does
+		{"upload.bin", []byte("fLaC\x00\x00\x00\x22"), true},
+		{"video.txt", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), true},
+		{"test.wav", []byte("invalid data"), false},
+		{"empty.mp3", nil, false},
 	}
 
 	for _, test := range tests {
-		t.Run(test.filePath, func(t *testing.T) {
-			result := IsSupportedAudioFormat(test.filePath)
-			assert.Equal(t, test.expected, result)
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name)
+			require.NoError(t, os.WriteFile(path, test.content, 0644))
+			assert.Equal(t, test.expected, IsSupportedAudioFormat(path))
 		})
 	}
+
+	assert.False(t, IsSupportedAudioFormat(filepath.Join(dir, "missing.wav")))
 }
 
 func createValidTestWAV(t *testing.T, path string) {
@@ -76,7 +77,7 @@ func createValidTestWAV(t *testing.T, path string) {
 	copy(header[0:4], "RIFF")
 	binary.LittleEndian.PutUint32(header[4:8], uint32(36+len(data)*2)) // Chunk size
 	copy(header[8:12], "WAVE")
-	
+
 	// fmt chunk
 	copy(header[12:16], "fmt ")
 	binary.LittleEndian.PutUint32(header[16:20], 16)    // Subchunk size
@@ -86,7 +87,7 @@ func createValidTestWAV(t *testing.T, path string) {
 	binary.LittleEndian.PutUint32(header[28:32], 32000) // Byte rate
 	binary.LittleEndian.PutUint16(header[32:34], 2)     // Block align
 	binary.LittleEndian.PutUint16(header[34:36], 16)    // Bits per sample
-	
+
 	// data chunk
 	copy(header[36:40], "data")
 	binary.LittleEndian.PutUint32(header[40:44], uint32(len(data)*2)) // Data size
@@ -0,0 +1,50 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// mp3Decoder decodes MPEG audio layer III, the decoder always produces
+// 16-bit stereo
+type mp3Decoder struct {
+	decoder *mp3.Decoder
+	buf     []byte
+}
+
+// newMP3Decoder skips any ID3 tag and decodes the first frame
+func newMP3Decoder(input io.Reader) (*mp3Decoder, error) {
+	decoder, err := mp3.NewDecoder(input)
+	if err != nil {
+		return nil, errorf(ErrCorrupt, "invalid MP3 file: %w", err)
+	}
+	return &mp3Decoder{decoder: decoder}, nil
+}
+
+func (d *mp3Decoder) sampleRate() int { return d.decoder.SampleRate() }
+
+func (d *mp3Decoder) channels() int { return 2 }
+
+func (d *mp3Decoder) read(p []float32) (int, error) {
+	size := len(p) * 2
+	if cap(d.buf) < size {
+		d.buf = make([]byte, size)
+	}
+
+	n, err := io.ReadFull(d.decoder, d.buf[:size])
+	samples := n / 2
+	for i := 0; i < samples; i++ {
+		p[i] = float32(int16(binary.LittleEndian.Uint16(d.buf[i*2:]))) / (1 << 15)
+	}
+
+	switch err {
+	case nil:
+		return samples, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		return samples, io.EOF
+	default:
+		return samples, errorf(ErrCorrupt, "failed to decode MP3: %w", err)
+	}
+}
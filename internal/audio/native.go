@@ -0,0 +1,232 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// nativeFormats lists the formats which can be decoded without FFmpeg
+const nativeFormats = "WAV, FLAC, MP3, Ogg Vorbis and Ogg Opus"
+
+// pcmDecoder produces interleaved samples in the range [-1, 1]
+type pcmDecoder interface {
+	sampleRate() int
+	channels() int
+	// read fills p with whole sample frames and returns the number of samples
+	// written, it returns io.EOF at the end of the input
+	read(p []float32) (int, error)
+}
+
+// lengthDecoder is a pcmDecoder whose header tells the number of sample
+// frames, zero when it does not
+type lengthDecoder interface {
+	length() int64
+}
+
+// sniffNativeFormat identifies the in-process decoder for the start of the
+// input, it returns an empty name for formats which need FFmpeg
+func sniffNativeFormat(header []byte) (string, error) {
+	switch SniffContainer(header) {
+	case "wav":
+		return "wav", nil
+	case "flac":
+		// FLAC behind an ID3 tag is left to FFmpeg
+		if bytes.HasPrefix(header, []byte("fLaC")) {
+			return "flac", nil
+		}
+	case "mp3":
+		if bytes.HasPrefix(header, []byte("ID3")) || isMP3Frame(header) {
+			return "mp3", nil
+		}
+	case "ogg":
+		payload := oggFirstPacket(header)
+		switch {
+		case bytes.HasPrefix(payload, []byte("\x01vorbis")):
+			return "vorbis", nil
+		case bytes.HasPrefix(payload, []byte("OpusHead")):
+			return "opus", nil
+		}
+	}
+	return "", nil
+}
+
+// isMP3Frame reports whether the header starts with an MPEG audio layer III frame
+func isMP3Frame(header []byte) bool {
+	return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && (header[1]>>1)&0x3 == 0x1
+}
+
+// oggFirstPacket returns the start of the payload of the first Ogg page
+func oggFirstPacket(header []byte) []byte {
+	if len(header) < 27 {
+		return nil
+	}
+	start := 27 + int(header[26])
+	if start > len(header) {
+		return nil
+	}
+	return header[start:]
+}
+
+// newNativeDecoder creates the in-process decoder for the input format
+func newNativeDecoder(input *bufio.Reader) (pcmDecoder, error) {
+	header, _ := input.Peek(wavHeaderPeek)
+	name, err := sniffNativeFormat(header)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "wav":
+		return newWAVDecoder(input)
+	case "flac":
+		return newFLACDecoder(input)
+	case "mp3":
+		return newMP3Decoder(input)
+	case "vorbis":
+		return newVorbisDecoder(input)
+	case "opus":
+		return newOpusDecoder(input)
+	default:
+		if container := SniffContainer(header); container != "" {
+			return nil, errorf(ErrDecoderMissing, "unsupported audio format: %s needs ffmpeg, only %s can be decoded without it", container, nativeFormats)
+		}
+		return nil, errorf(ErrUnsupportedFormat, "unsupported audio format: only %s can be decoded without ffmpeg", nativeFormats)
+	}
+}
+
+// newNativeStream decodes the input in-process, raw PCM when Options.Raw is
+// set, downmixing to mono or picking the selected channel, and resampling to
+// whisper's sample rate
+func newNativeStream(input *bufio.Reader, frameSize int, opts Options) (*Stream, error) {
+	if frameSize <= 0 {
+		frameSize = DefaultFrameSize
+	}
+	decoder, err := openDecoder(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Channel > decoder.channels() {
+		return nil, fmt.Errorf("audio has %d channels, there is no channel %d", decoder.channels(), opts.Channel)
+	}
+	if err := checkNativeStream(opts); err != nil {
+		return nil, err
+	}
+
+	s := newStream(opts, nil)
+	go func() {
+		defer s.finish()
+		s.err = s.decodePCM(decoder, frameSize, opts)
+	}()
+
+	return s, nil
+}
+
+// checkNativeStream rejects selecting other than the first audio stream, the
+// formats decoded in-process only carry one
+func checkNativeStream(opts Options) error {
+	if opts.Stream > 1 {
+		return fmt.Errorf("invalid audio stream %d: the file has 1 audio stream, counting from 1", opts.Stream)
+	}
+	return nil
+}
+
+// sampleLimit is the number of samples allowed by MaxDuration, or -1 without limit
+func sampleLimit(opts Options) int {
+	if opts.MaxDuration > 0 {
+		return int(opts.MaxDuration.Seconds() * SampleRate)
+	}
+	return -1
+}
+
+// decodePCM reads the decoder and sends frames until the end of the input
+// or until the MaxDuration of the options was produced. The selected channel
+// is picked or all channels are mixed, and after resampling the default
+// normalization and the tempo change are applied.
+func (s *Stream) decodePCM(decoder pcmDecoder, frameSize int, opts Options) error {
+	channels, channel, maxSamples := decoder.channels(), opts.Channel, sampleLimit(opts)
+	chain := stageChain{
+		newResampler(decoder.sampleRate(), SampleRate),
+		newDynamicNormalizer(normalizes(opts)),
+		newTempoStretcher(opts.tempo()),
+		s.post,
+	}
+
+	// Read roughly one output frame worth of input at a time
+	inputFrames := frameSize * decoder.sampleRate() / SampleRate
+	if inputFrames < 1 {
+		inputFrames = 1
+	}
+	buf := make([]float32, inputFrames*channels)
+
+	var pending []float32
+	produced := 0
+	emit := func(final bool) bool {
+		for len(pending) >= frameSize || final && len(pending) > 0 {
+			n := frameSize
+			if n > len(pending) {
+				n = len(pending)
+			}
+			if maxSamples >= 0 && produced+n > maxSamples {
+				n = maxSamples - produced
+			}
+			if n <= 0 {
+				return false
+			}
+
+			frame := make([]float32, n)
+			copy(frame, pending[:n])
+			pending = pending[n:]
+			produced += n
+			if !s.send(frame) {
+				return false
+			}
+		}
+		return maxSamples < 0 || produced < maxSamples
+	}
+
+	for {
+		n, err := decoder.read(buf)
+		n -= n % channels
+		if n > 0 {
+			pending = append(pending, chain.process(selectChannel(buf[:n], channels, channel))...)
+			if !emit(false) {
+				return nil
+			}
+		}
+
+		switch err {
+		case nil:
+		case io.EOF:
+			pending = append(pending, chain.flush()...)
+			emit(true)
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// selectChannel reduces interleaved samples to mono in place, picking the
+// channel counting from 1 or averaging all channels when channel is zero
+func selectChannel(samples []float32, channels, channel int) []float32 {
+	if channels == 1 {
+		return samples
+	}
+
+	mono := samples[:len(samples)/channels]
+	for i := range mono {
+		if channel > 0 {
+			mono[i] = samples[i*channels+channel-1]
+			continue
+		}
+
+		var sum float32
+		for ch := 0; ch < channels; ch++ {
+			sum += samples[i*channels+ch]
+		}
+		mono[i] = sum / float32(channels)
+	}
+	return mono
+}
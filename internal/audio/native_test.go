@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNativeStream(t *testing.T) {
+	t.Run("FLAC", func(t *testing.T) {
+		// 0.2s of a 440Hz tone, 22.05kHz stereo
+		samples := decodeTestFile(t, "tone.flac")
+		require.Len(t, samples, SampleRate/5)
+
+		for i := 500; i < 2700; i += 97 {
+			expected := 0.5 * math.Sin(2*math.Pi*440*float64(i)/SampleRate)
+			assert.InDelta(t, expected, samples[i], 0.01, "sample %d", i)
+		}
+	})
+
+	t.Run("MP3", func(t *testing.T) {
+		// 60 MPEG-2 layer III frames of speech at 22.05kHz
+		samples := decodeTestFile(t, "speech.mp3")
+		assert.InDelta(t, 60*576*SampleRate/22050, len(samples), 1)
+		assert.Greater(t, peak(samples), float32(0.1))
+	})
+
+	t.Run("Ogg Vorbis", func(t *testing.T) {
+		// 1s at 44.1kHz mono
+		samples := decodeTestFile(t, "sample.ogg")
+		assert.Len(t, samples, SampleRate)
+		assert.Greater(t, peak(samples), float32(0.1))
+	})
+
+	t.Run("Ogg Opus", func(t *testing.T) {
+		// 0.5s of a 440Hz tone, CELT at 48kHz mono with a 312 sample pre-skip
+		samples := decodeTestFile(t, "tone.opus")
+		assert.Len(t, samples, SampleRate/2)
+		assert.InDelta(t, 0.5, peak(samples), 0.1)
+
+		// The tone crosses zero twice per period
+		crossings := 0
+		for i := 1; i < len(samples); i++ {
+			if samples[i-1] < 0 && samples[i] >= 0 || samples[i-1] >= 0 && samples[i] < 0 {
+				crossings++
+			}
+		}
+		assert.InDelta(t, 440, crossings, 10)
+	})
+
+	t.Run("multichannel Ogg Opus needs ffmpeg", func(t *testing.T) {
+		page := []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x15\x3c\x1c\x71\x01\x1b")
+		page = append(page, []byte("OpusHead\x01\x06\x38\x01\x80\xbb\x00\x00\x00\x00\x01\x04\x02\x00\x04\x01\x02\x03\x05")...)
+
+		_, err := newNativeStream(bufio.NewReader(bytes.NewReader(page)), 0, Options{})
+		assert.ErrorIs(t, err, ErrDecoderMissing)
+	})
+
+	t.Run("other formats need ffmpeg", func(t *testing.T) {
+		m4a := []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00M4A mp42isom")
+
+		_, err := newNativeStream(bufio.NewReader(bytes.NewReader(m4a)), 0, Options{})
+		assert.ErrorContains(t, err, "unsupported audio format: mp4 needs ffmpeg, only WAV, FLAC, MP3, Ogg Vorbis and Ogg Opus")
+		assert.ErrorIs(t, err, ErrDecoderMissing)
+	})
+
+	t.Run("corrupt FLAC", func(t *testing.T) {
+		_, err := newNativeStream(bufio.NewReader(bytes.NewReader([]byte("fLaC\x00\x00"))), 0, Options{})
+		assert.ErrorContains(t, err, "invalid FLAC file")
+	})
+}
+
+func decodeTestFile(t *testing.T, name string) []float32 {
+	file, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer file.Close()
+
+	stream, err := newNativeStream(bufio.NewReaderSize(file, wavHeaderPeek), DefaultFrameSize, Options{Filter: FilterNone})
+	require.NoError(t, err)
+
+	samples, err := stream.ReadAll()
+	require.NoError(t, err)
+	return samples
+}
+
+func peak(samples []float32) float32 {
+	var max float32
+	for _, s := range samples {
+		if s > max {
+			max = s
+		} else if -s > max {
+			max = -s
+		}
+	}
+	return max
+}
@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// normalizeFrameSize is the length of the frames whose gain is computed,
+	// 500ms as in FFmpeg's dynaudnorm
+	normalizeFrameSize = SampleRate / 2
+	// normalizeRadius is the number of frames on either side the gains are
+	// smoothed over
+	normalizeRadius = 15
+	// normalizePeak is the level the peak of every frame is raised to
+	normalizePeak = 0.95
+	// normalizeMaxGain limits the amplification of quiet frames
+	normalizeMaxGain = 10
+)
+
+// dynamicNormalizer evens out the volume over time like FFmpeg's dynaudnorm,
+// the normalization preset of decoders other than FFmpeg. Every frame gets
+// the gain raising its peak to normalizePeak, the gains are passed through a
+// minimum filter so no frame is amplified more than its loudest neighbour
+// allows and smoothed with a Gaussian window. A frame is held back until the
+// gains of the frames it depends on are known.
+type dynamicNormalizer struct {
+	enabled bool
+	weights []float64
+
+	// frame collects samples until a frame is complete
+	frame []float32
+	// frames are the complete frames waiting for their gain, the first one
+	// has the index next
+	frames [][]float32
+	next   int
+	// gains are the gains of frames from index base on
+	gains []float64
+	base  int
+	// previous is the smoothed gain of the last frame returned
+	previous float64
+	started  bool
+}
+
+// newDynamicNormalizer creates a normalizer, a disabled one passes the samples
+// through unchanged
+func newDynamicNormalizer(enabled bool) *dynamicNormalizer {
+	sigma := float64(normalizeRadius) / 3
+	weights := make([]float64, 2*normalizeRadius+1)
+	for i := range weights {
+		d := float64(i - normalizeRadius)
+		weights[i] = math.Exp(-d * d / (2 * sigma * sigma))
+	}
+	return &dynamicNormalizer{enabled: enabled, weights: weights}
+}
+
+func (n *dynamicNormalizer) process(in []float32) []float32 {
+	if !n.enabled {
+		return in
+	}
+
+	var out []float32
+	for len(in) > 0 {
+		count := min(normalizeFrameSize-len(n.frame), len(in))
+		n.frame = append(n.frame, in[:count]...)
+		in = in[count:]
+		if len(n.frame) == normalizeFrameSize {
+			n.addFrame()
+		}
+	}
+
+	// A frame depends on the gains of the frames up to twice the radius
+	// ahead, through the minimum filter and the smoothing
+	for len(n.frames) > 0 && n.base+len(n.gains)-1 >= n.next+2*normalizeRadius {
+		out = append(out, n.emit()...)
+	}
+	return out
+}
+
+func (n *dynamicNormalizer) flush() []float32 {
+	if !n.enabled {
+		return nil
+	}
+	if len(n.frame) > 0 {
+		n.addFrame()
+	}
+
+	var out []float32
+	for len(n.frames) > 0 {
+		out = append(out, n.emit()...)
+	}
+	return out
+}
+
+// addFrame queues the collected frame with the gain of its peak
+func (n *dynamicNormalizer) addFrame() {
+	var peak float64
+	for _, s := range n.frame {
+		peak = math.Max(peak, math.Abs(float64(s)))
+	}
+	gain := float64(normalizeMaxGain)
+	if peak > 0 {
+		gain = math.Min(normalizePeak/peak, normalizeMaxGain)
+	}
+
+	n.frames = append(n.frames, n.frame)
+	n.gains = append(n.gains, gain)
+	n.frame = nil
+}
+
+// gain returns the gain of a frame, frames past either end of the known ones
+// have the gain of the nearest one
+func (n *dynamicNormalizer) gain(index int) float64 {
+	index = min(max(index, n.base), n.base+len(n.gains)-1)
+	return n.gains[index-n.base]
+}
+
+// minimumGain returns the lowest gain within the radius of a frame
+func (n *dynamicNormalizer) minimumGain(index int) float64 {
+	gain := n.gain(index)
+	for d := -normalizeRadius; d <= normalizeRadius; d++ {
+		gain = math.Min(gain, n.gain(index+d))
+	}
+	return gain
+}
+
+// emit applies the smoothed gain to the first waiting frame, ramping from the
+// gain of the frame before so the volume does not jump
+func (n *dynamicNormalizer) emit() []float32 {
+	var sum, weights float64
+	for d := -normalizeRadius; d <= normalizeRadius; d++ {
+		w := n.weights[d+normalizeRadius]
+		sum += w * n.minimumGain(n.next+d)
+		weights += w
+	}
+	gain := sum / weights
+	if !n.started {
+		n.previous, n.started = gain, true
+	}
+
+	frame := n.frames[0]
+	n.frames = n.frames[1:]
+	for i, s := range frame {
+		g := n.previous + (gain-n.previous)*float64(i+1)/float64(len(frame))
+		frame[i] = float32(math.Max(-1, math.Min(1, float64(s)*g)))
+	}
+	n.previous = gain
+	n.next++
+
+	// Older gains are no longer needed by any waiting frame
+	if drop := n.next - 2*normalizeRadius - n.base; drop > 0 {
+		drop = min(drop, len(n.gains)-1)
+		n.gains = n.gains[drop:]
+		n.base += drop
+	}
+	return frame
+}
@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// normalize runs the samples through a normalizer in blocks of the given size
+func normalize(samples []float32, block int) []float32 {
+	normalizer := newDynamicNormalizer(true)
+	var out []float32
+	for start := 0; start < len(samples); start += block {
+		out = append(out, normalizer.process(samples[start:min(start+block, len(samples))])...)
+	}
+	return append(out, normalizer.flush()...)
+}
+
+func TestDynamicNormalizer(t *testing.T) {
+	// A quiet minute with a loud passage in the middle
+	samples := make([]float32, durationToSamples(60*time.Second))
+	addTone(samples, 0, 60*time.Second, 0.05, 300)
+	addTone(samples, 28*time.Second, 32*time.Second, 0.75, 300)
+
+	out := normalize(samples, 4000)
+	require.Len(t, out, len(samples))
+
+	peak := func(samples []float32, start, end time.Duration) float64 {
+		var peak float64
+		for _, s := range samples[durationToSamples(start):durationToSamples(end)] {
+			peak = math.Max(peak, math.Abs(float64(s)))
+		}
+		return peak
+	}
+
+	// Quiet audio far from the loud passage is amplified up to the maximum gain
+	assert.InDelta(t, 0.5, peak(out, 0, 5*time.Second), 0.02)
+	assert.InDelta(t, 0.5, peak(out, 55*time.Second, 60*time.Second), 0.02)
+	// The loud passage is not amplified beyond the target peak, nor is the
+	// audio leading into it
+	assert.LessOrEqual(t, peak(out, 28*time.Second, 32*time.Second), normalizePeak+0.01)
+	assert.Less(t, peak(out, 26*time.Second, 28*time.Second), 0.5)
+
+	t.Run("blocks give the same result", func(t *testing.T) {
+		assert.Equal(t, out, normalize(samples, len(samples)))
+		assert.Equal(t, out, normalize(samples, 333))
+	})
+
+	t.Run("disabled passes samples through", func(t *testing.T) {
+		normalizer := newDynamicNormalizer(false)
+		assert.Equal(t, samples, normalizer.process(samples))
+		assert.Empty(t, normalizer.flush())
+	})
+}
+
+func TestNormalizes(t *testing.T) {
+	assert.True(t, normalizes(Options{}))
+	assert.True(t, normalizes(Options{Filter: FilterNormalize}))
+	assert.False(t, normalizes(Options{Filter: FilterNone}))
+	assert.False(t, normalizes(Options{Filter: "highpass,normalize"}))
+	// Noise suppression replaces the default normalization
+	assert.False(t, normalizes(Options{NoiseSuppression: 20}))
+	assert.True(t, normalizes(Options{Filter: FilterNormalize, NoiseSuppression: 20}))
+}
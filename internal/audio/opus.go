@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/pion/opus"
+	"github.com/pion/opus/pkg/oggreader"
+)
+
+const (
+	// opusGranuleRate is the rate of Ogg Opus granule positions and pre-skip
+	opusGranuleRate = 48000
+	// opusMaxPacketSamples is the longest packet, 120ms at whisper's sample rate
+	opusMaxPacketSamples = 120 * SampleRate / 1000
+)
+
+// opusDecoder decodes Opus audio in an Ogg container. Opus is decoded right
+// at whisper's sample rate, the encoder's pre-skip is dropped from the start
+// and the end is trimmed to the granule position of the last page.
+type opusDecoder struct {
+	ogg          *oggreader.OggReader
+	decoder      opus.Decoder
+	channelCount int
+	gain         float32
+
+	// skip is the number of leading samples per channel still to drop
+	skip int
+	// position is the number of samples per channel decoded so far,
+	// including the pre-skip, and end where the stream ends, -1 if unknown
+	position int
+	end      int
+
+	// pcm holds interleaved samples decoded but not read yet
+	pcm []float32
+	buf []float32
+}
+
+// newOpusDecoder reads the Opus identification header
+func newOpusDecoder(input io.Reader) (*opusDecoder, error) {
+	ogg, header, err := oggreader.NewWith(input)
+	if err != nil {
+		return nil, errorf(ErrCorrupt, "invalid Ogg Opus file: %w", err)
+	}
+	if header.ChannelMap != 0 || header.Channels < 1 || header.Channels > 2 {
+		return nil, errorf(ErrDecoderMissing, "unsupported audio format: Ogg Opus with %d channels needs ffmpeg", header.Channels)
+	}
+
+	channels := int(header.Channels)
+	decoder, err := opus.NewDecoderWithOutput(SampleRate, channels)
+	if err != nil {
+		return nil, errorf(ErrCorrupt, "invalid Ogg Opus file: %w", err)
+	}
+
+	// The output gain is a Q7.8 number of dB
+	gain := math.Pow(10, float64(int16(header.OutputGain))/(20*256))
+	return &opusDecoder{
+		ogg:          ogg,
+		decoder:      decoder,
+		channelCount: channels,
+		gain:         float32(gain),
+		skip:         toOpusRate(int(header.PreSkip)),
+		end:          -1,
+		buf:          make([]float32, opusMaxPacketSamples*channels),
+	}, nil
+}
+
+// toOpusRate converts a number of samples at the granule rate to whisper's rate
+func toOpusRate(n int) int {
+	return n * SampleRate / opusGranuleRate
+}
+
+func (d *opusDecoder) sampleRate() int { return SampleRate }
+
+func (d *opusDecoder) channels() int { return d.channelCount }
+
+func (d *opusDecoder) read(p []float32) (int, error) {
+	for len(d.pcm) == 0 {
+		if err := d.decodePacket(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.pcm)
+	n -= n % d.channelCount
+	d.pcm = d.pcm[n:]
+	return n, nil
+}
+
+// decodePacket decodes the next audio packet into pcm
+func (d *opusDecoder) decodePacket() error {
+	packet, page, err := d.ogg.ParseNextPacket()
+	switch {
+	case errors.Is(err, io.EOF):
+		return io.EOF
+	case err != nil:
+		return errorf(ErrCorrupt, "failed to decode Ogg Opus: %w", err)
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		return nil
+	}
+
+	// The granule position of a page is where its last packet ends
+	if page.GranulePosition != math.MaxUint64 {
+		d.end = toOpusRate(int(page.GranulePosition))
+	}
+
+	samples, err := d.decoder.DecodeToFloat32(packet, d.buf)
+	if err != nil {
+		return errorf(ErrCorrupt, "failed to decode Ogg Opus: %w", err)
+	}
+	if d.end >= 0 {
+		samples = max(min(samples, d.end-d.position), 0)
+	}
+	d.position += samples
+
+	skip := min(d.skip, samples)
+	d.skip -= skip
+	d.pcm = d.buf[skip*d.channelCount : samples*d.channelCount]
+	for i := range d.pcm {
+		d.pcm[i] *= d.gain
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// nativeCodecs names the codecs of the formats decoded in-process
+var nativeCodecs = map[string]string{
+	"wav":    "pcm",
+	"flac":   "flac",
+	"mp3":    "mp3",
+	"vorbis": "vorbis",
+	"opus":   "opus",
+}
+
+// ProbeInfo describes an audio file, e.g. to estimate the cost of
+// transcribing it or to reject it up front
+type ProbeInfo struct {
+	// Container is the file format found by SniffContainer, or FFmpeg's name
+	// for formats which are not sniffed
+	Container string
+	// Codec, SampleRate and Channels describe the selected audio stream
+	Codec      string
+	SampleRate int
+	Channels   int
+	Duration   time.Duration
+	// BitRate is in bits per second, of the audio stream where known
+	BitRate int
+	// SpeechRatio is the share of the audio in which voice activity detection
+	// found speech, between 0 and 1
+	SpeechRatio float64
+	// Tracks lists all audio streams of the file
+	Tracks []AudioTrack
+}
+
+// probeInfoJSON is the wire format of a probe with the duration in seconds
+type probeInfoJSON struct {
+	Container   string       `json:"container"`
+	Codec       string       `json:"codec"`
+	SampleRate  int          `json:"sample_rate"`
+	Channels    int          `json:"channels"`
+	Duration    float64      `json:"duration"`
+	BitRate     int          `json:"bit_rate"`
+	SpeechRatio float64      `json:"speech_ratio"`
+	Tracks      []AudioTrack `json:"tracks"`
+}
+
+// MarshalJSON encodes the probe with the duration in seconds
+func (p ProbeInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(probeInfoJSON{
+		Container:   p.Container,
+		Codec:       p.Codec,
+		SampleRate:  p.SampleRate,
+		Channels:    p.Channels,
+		Duration:    p.Duration.Seconds(),
+		BitRate:     p.BitRate,
+		SpeechRatio: p.SpeechRatio,
+		Tracks:      p.Tracks,
+	})
+}
+
+// Probe describes the audio file and the audio stream selected by
+// Options.Stream, FFmpeg's default stream when none is. The audio is streamed
+// through the decoder with the options to measure how much of it is speech
+// without holding it in memory, audio longer than Options.DurationLimit is
+// rejected with ErrTooLong.
+func Probe(filePath string, opts Options) (*ProbeInfo, error) {
+	info, err := probeContainer(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if container, err := SniffFile(filePath); err == nil && container != "" {
+		info.Container = container
+	}
+
+	track, err := probedTrack(info.Tracks, opts.Stream)
+	if err != nil {
+		return nil, err
+	}
+	info.Codec, info.SampleRate, info.Channels = track.Codec, track.SampleRate, track.Channels
+	if track.BitRate > 0 {
+		// The container's bit rate includes video and other streams
+		info.BitRate = track.BitRate
+	}
+
+	// Reject long audio before decoding it when the container knows its length
+	if err := checkDuration(info, opts); err != nil {
+		return nil, err
+	}
+
+	samples, speech, err := measureSpeech(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if info.Duration == 0 {
+		info.Duration = samplesToDuration(samples)
+	}
+	if info.BitRate == 0 && info.Duration > 0 {
+		if stat, err := os.Stat(filePath); err == nil {
+			info.BitRate = int(float64(stat.Size()*8) / info.Duration.Seconds())
+		}
+	}
+	info.SpeechRatio = speech.Seconds() / samplesToDuration(samples).Seconds()
+
+	return info, nil
+}
+
+// measureSpeech decodes the audio as a stream and returns the number of
+// samples and how long the speech in them is, without padding
+func measureSpeech(filePath string, opts Options) (int, time.Duration, error) {
+	stream, err := OpenStream(filePath, DefaultFrameSize, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stream.Close()
+
+	vad := DefaultVADOptions()
+	vad.Padding = 0
+	detector := NewSpeechDetector(vad)
+	samples := 0
+	for frame := range stream.Frames() {
+		detector.Process(frame)
+		samples += len(frame)
+	}
+	if err := stream.Err(); err != nil {
+		return 0, 0, err
+	}
+	if samples == 0 {
+		return 0, 0, audibleError(0, 0)
+	}
+	return samples, detector.SpeechDuration(), nil
+}
+
+// CheckDuration rejects audio longer than Options.DurationLimit with
+// ErrTooLong before it is decoded, as far as the container tells its length.
+// Audio of unknown length passes, the decoder stops it at the limit.
+func CheckDuration(filePath string, opts Options) error {
+	if opts.DurationLimit <= 0 {
+		return nil
+	}
+	info, err := probeContainer(filePath)
+	if err != nil {
+		return err
+	}
+	return checkDuration(info, opts)
+}
+
+// checkDuration rejects a container longer than the duration limit
+func checkDuration(info *ProbeInfo, opts Options) error {
+	if opts.DurationLimit > 0 && info.Duration > opts.DurationLimit {
+		return errorf(ErrTooLong, "audio too long: longer than the limit of %s", opts.DurationLimit)
+	}
+	return nil
+}
+
+// probedTrack returns the selected track, or the default one for zero
+func probedTrack(tracks []AudioTrack, stream int) (AudioTrack, error) {
+	if stream > len(tracks) {
+		return AudioTrack{}, fmt.Errorf("invalid audio stream %d: the file has %d audio streams, counting from 1", stream, len(tracks))
+	}
+	if stream > 0 {
+		return tracks[stream-1], nil
+	}
+	for _, track := range tracks {
+		if track.Default {
+			return track, nil
+		}
+	}
+	return tracks[0], nil
+}
+
+// probeContainer describes the container and lists the audio streams, using
+// ffprobe when FFmpeg is available and the in-process decoders otherwise
+func probeContainer(filePath string) (*ProbeInfo, error) {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audio file: file does not exist")
+	}
+	if ffmpegAvailable() {
+		return probeFFmpeg(filePath)
+	}
+	return probeNative(filePath)
+}
+
+// probeNative reads the header of a format decoded in-process, the duration
+// is left for decoding to find out unless the header tells it
+func probeNative(filePath string) (*ProbeInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, wavHeaderPeek)
+	header, _ := reader.Peek(wavHeaderPeek)
+	name, err := sniffNativeFormat(header)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := newNativeDecoder(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleRate := decoder.sampleRate()
+	if name == "opus" {
+		// Opus is decoded right at whisper's rate, it is coded at 48 kHz
+		sampleRate = opusGranuleRate
+	}
+
+	var duration time.Duration
+	if known, ok := decoder.(lengthDecoder); ok {
+		duration = time.Duration(known.length()) * time.Second / time.Duration(decoder.sampleRate())
+	}
+
+	return &ProbeInfo{
+		Container: SniffContainer(header),
+		Duration:  duration,
+		Tracks: []AudioTrack{{
+			Number:     1,
+			Codec:      nativeCodecs[name],
+			Channels:   decoder.channels(),
+			SampleRate: sampleRate,
+			Default:    true,
+		}},
+	}, nil
+}
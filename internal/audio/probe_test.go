@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	// 1.5s of tone bursts in 5.5s of noise
+	samples := testNoise(5500 * time.Millisecond)
+	addTone(samples, time.Second, 2*time.Second, 0.3, 300)
+	addTone(samples, 4*time.Second, 4500*time.Millisecond, 0.3, 300)
+
+	data := make([]byte, 0, len(samples)*2)
+	for _, s := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(s*(1<<15))))
+	}
+	path := filepath.Join(t.TempDir(), "speech.wav")
+	require.NoError(t, os.WriteFile(path, buildTestWAV(wavFormatPCM, 1, SampleRate, 16, data), 0644))
+
+	info, err := Probe(path, Options{Filter: FilterNone})
+	require.NoError(t, err)
+	assert.Equal(t, "wav", info.Container)
+	assert.Equal(t, SampleRate, info.SampleRate)
+	assert.Equal(t, 1, info.Channels)
+	assert.InDelta(t, 5500*time.Millisecond, info.Duration, float64(time.Millisecond))
+	assert.InDelta(t, SampleRate*16, info.BitRate, 1000)
+	assert.InDelta(t, 1.5/5.5, info.SpeechRatio, 0.03)
+	require.Len(t, info.Tracks, 1)
+
+	encoded, err := json.Marshal(info)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"duration":5.5`)
+
+	_, err = Probe(path, Options{Filter: FilterNone, DurationLimit: 5 * time.Second})
+	assert.ErrorIs(t, err, ErrTooLong)
+
+	// The length is known without decoding
+	assert.ErrorIs(t, CheckDuration(path, Options{DurationLimit: 5 * time.Second}), ErrTooLong)
+	assert.NoError(t, CheckDuration(path, Options{DurationLimit: 6 * time.Second}))
+	assert.NoError(t, CheckDuration(path, Options{}))
+}
@@ -0,0 +1,353 @@
+package audio
+
+import (
+	"math"
+)
+
+// Problems flagged in a QualityReport
+const (
+	// IssueQuiet marks audio so quiet that speech may be lost in it
+	IssueQuiet = "quiet"
+	// IssueClipping marks audio recorded too loud, whose peaks were cut off
+	IssueClipping = "clipping"
+	// IssueNoisy marks speech barely louder than the background noise
+	IssueNoisy = "noisy"
+	// IssueSilent marks audio in which almost no speech was found, it may
+	// still be loud with noise or music
+	IssueSilent = "silent"
+)
+
+// Thresholds of the flagged problems
+const (
+	// QuietLoudness is the integrated loudness in LUFS below which audio is quiet
+	QuietLoudness = -40
+	// ClippingLimit is the share of clipped samples above which audio clips
+	ClippingLimit = 0.001
+	// NoisySNR is the signal-to-noise ratio in dB below which speech is noisy
+	NoisySNR = 10
+	// SilentRatio is the share of silence above which audio is silent
+	SilentRatio = 0.9
+)
+
+const (
+	// loudnessBlock is the length of the sub-blocks the gating blocks of
+	// ITU-R BS.1770 are built from, 100ms, four make a 400ms block
+	loudnessBlock     = SampleRate / 10
+	loudnessSubBlocks = 4
+	// absoluteGate is the loudness in LUFS below which blocks are ignored,
+	// also reported for audio with no louder block
+	absoluteGate = -70
+	// relativeGate is how many LU below the loudness of the blocks above the
+	// absolute gate a block must be to be ignored
+	relativeGate = 10
+	// clipLevel is the sample magnitude counted as clipped, resampling turns
+	// clipped full scale samples into values just around it
+	clipLevel = 0.99
+	// signalPercentile is the share of frames quieter than the level taken as
+	// the signal when no speech was found
+	signalPercentile = 0.95
+	// minLevel is the level in dB reported for digital silence, as in analyseFrame
+	minLevel = -100
+)
+
+// QualityReport describes the technical quality of audio, e.g. to tell a
+// poor recording from a poor transcription
+type QualityReport struct {
+	// Loudness is the integrated loudness in LUFS as defined by ITU-R BS.1770
+	Loudness float64 `json:"loudness_lufs"`
+	// Peak is the highest sample level in dBFS
+	Peak float64 `json:"peak_dbfs"`
+	// ClippingRatio is the share of samples at full scale, between 0 and 1
+	ClippingRatio float64 `json:"clipping_ratio"`
+	// SNR estimates the signal-to-noise ratio in dB from the level of the
+	// speech frames over the noise floor
+	SNR float64 `json:"snr_db"`
+	// SilenceRatio is the share of the audio in which voice activity
+	// detection found no speech, between 0 and 1
+	SilenceRatio float64 `json:"silence_ratio"`
+	// Issues lists the problems found, see IssueQuiet and the others
+	Issues []string `json:"issues"`
+}
+
+// Level is the level of a short block of samples, e.g. for a level meter
+type Level struct {
+	// RMS is the root mean square level in dBFS
+	RMS float64
+	// Peak is the highest sample level in dBFS
+	Peak float64
+	// Clipped is the number of samples at full scale
+	Clipped int
+}
+
+// MeasureLevel measures the level of a block of samples
+func MeasureLevel(samples []float32) Level {
+	var sum float64
+	var peak float32
+	clipped := 0
+	for _, s := range samples {
+		abs := float32(math.Abs(float64(s)))
+		peak = max(peak, abs)
+		if abs >= clipLevel {
+			clipped++
+		}
+		sum += float64(s) * float64(s)
+	}
+	level := Level{RMS: minLevel, Peak: math.Max(20*math.Log10(float64(peak)), minLevel), Clipped: clipped}
+	if len(samples) > 0 {
+		level.RMS = math.Max(10*math.Log10(sum/float64(len(samples))), minLevel)
+	}
+	return level
+}
+
+// AnalyzeQuality measures the quality of the audio file as it was recorded:
+// it is decoded with the options but without audio filters, tempo change or
+// noise suppression, which would hide the problems. The audio is measured as
+// it is decoded, memory use does not grow with its length.
+func AnalyzeQuality(filePath string, opts Options) (*QualityReport, error) {
+	opts.Filter = FilterNone
+	opts.Tempo = 0
+	opts.NoiseSuppression = 0
+
+	stream, err := OpenStream(filePath, DefaultFrameSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	meter := newQualityMeter()
+	for frame := range stream.Frames() {
+		meter.add(frame)
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	report := meter.report()
+	return &report, nil
+}
+
+// MeasureQuality measures the quality of decoded samples
+func MeasureQuality(samples []float32) QualityReport {
+	meter := newQualityMeter()
+	meter.add(samples)
+	return meter.report()
+}
+
+// qualityMeter accumulates the measurements of a QualityReport over blocks of
+// samples, keeping only a few numbers per frame
+type qualityMeter struct {
+	// shelf and highpass are the K-weighting filters of BS.1770
+	shelf    biquad
+	highpass biquad
+	// blockEnergy and blockSamples accumulate the current loudness sub-block
+	blockEnergy  float64
+	blockSamples int
+	// subBlocks is the mean square of every complete loudness sub-block
+	subBlocks []float64
+
+	frameSize int
+	frame     []float32
+	// energies and rates describe every voice activity detection frame
+	energies []float64
+	rates    []float64
+
+	samples int
+	clipped int
+	peak    float32
+}
+
+// newQualityMeter creates a meter for audio at whisper's sample rate
+func newQualityMeter() *qualityMeter {
+	return &qualityMeter{
+		shelf:     highShelf(SampleRate),
+		highpass:  kHighpass(SampleRate),
+		frameSize: durationToSamples(DefaultVADOptions().FrameDuration),
+	}
+}
+
+// add measures the next samples
+func (m *qualityMeter) add(samples []float32) {
+	for _, s := range samples {
+		abs := float32(math.Abs(float64(s)))
+		if abs > m.peak {
+			m.peak = abs
+		}
+		if abs >= clipLevel {
+			m.clipped++
+		}
+
+		weighted := m.highpass.filter(m.shelf.filter(float64(s)))
+		m.blockEnergy += weighted * weighted
+		if m.blockSamples++; m.blockSamples == loudnessBlock {
+			m.subBlocks = append(m.subBlocks, m.blockEnergy/loudnessBlock)
+			m.blockEnergy, m.blockSamples = 0, 0
+		}
+	}
+	m.samples += len(samples)
+
+	m.frame = append(m.frame, samples...)
+	start := 0
+	for ; start+m.frameSize <= len(m.frame); start += m.frameSize {
+		m.addFrame(m.frame[start : start+m.frameSize])
+	}
+	m.frame = append(m.frame[:0], m.frame[start:]...)
+}
+
+// addFrame records the energy and zero-crossing rate of a frame
+func (m *qualityMeter) addFrame(frame []float32) {
+	energy, rate := analyseFrame(frame)
+	m.energies = append(m.energies, energy)
+	m.rates = append(m.rates, rate)
+}
+
+// report returns the measurements of everything added, an incomplete
+// loudness sub-block is ignored as BS.1770 does
+func (m *qualityMeter) report() QualityReport {
+	if len(m.frame) > 0 {
+		m.addFrame(m.frame)
+		m.frame = nil
+	}
+
+	report := QualityReport{
+		Loudness: integratedLoudness(m.subBlocks),
+		Peak:     math.Max(20*math.Log10(float64(m.peak)), minLevel),
+		Issues:   []string{},
+	}
+	if m.samples > 0 {
+		report.ClippingRatio = float64(m.clipped) / float64(m.samples)
+	}
+
+	report.SilenceRatio = 1
+	signal := float64(minLevel)
+	if len(m.energies) > 0 {
+		report.SNR, signal, report.SilenceRatio = speechToNoise(m.energies, m.rates)
+	}
+
+	if report.Loudness < QuietLoudness {
+		report.Issues = append(report.Issues, IssueQuiet)
+	}
+	if report.ClippingRatio > ClippingLimit {
+		report.Issues = append(report.Issues, IssueClipping)
+	}
+	// Near digital silence there is no noise worth reporting
+	if report.SNR < NoisySNR && signal >= DefaultVADOptions().MinEnergy {
+		report.Issues = append(report.Issues, IssueNoisy)
+	}
+	if report.SilenceRatio > SilentRatio {
+		report.Issues = append(report.Issues, IssueSilent)
+	}
+	return report
+}
+
+// speechToNoise estimates the signal-to-noise ratio in dB as the mean level
+// of the speech frames, or of the loudest frames when no speech was found,
+// over the noise floor. It returns the ratio, the level of the signal and the
+// share of frames without speech.
+func speechToNoise(energies, rates []float64) (snr, signal, silence float64) {
+	var speechPower float64
+	speechCount := 0
+	for i, speech := range speechFrames(energies, rates, DefaultVADOptions()) {
+		if speech {
+			speechPower += math.Pow(10, energies[i]/10)
+			speechCount++
+		}
+	}
+
+	signal = percentile(energies, signalPercentile)
+	if speechCount > 0 {
+		signal = 10 * math.Log10(speechPower/float64(speechCount))
+	}
+	silence = float64(len(energies)-speechCount) / float64(len(energies))
+	return signal - noiseFloor(energies), signal, silence
+}
+
+// integratedLoudness gates the 400ms blocks made of the sub-blocks as
+// BS.1770 defines and returns the loudness of the rest in LUFS
+func integratedLoudness(subBlocks []float64) float64 {
+	var blocks []float64
+	for i := 0; i+loudnessSubBlocks <= len(subBlocks); i++ {
+		blocks = append(blocks, sum(subBlocks[i:i+loudnessSubBlocks])/loudnessSubBlocks)
+	}
+
+	// gated returns the mean energy of the blocks louder than the threshold
+	gated := func(threshold float64) (float64, bool) {
+		var total float64
+		count := 0
+		for _, block := range blocks {
+			if blockLoudness(block) > threshold {
+				total += block
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, false
+		}
+		return total / float64(count), true
+	}
+
+	energy, ok := gated(absoluteGate)
+	if !ok {
+		return absoluteGate
+	}
+	if energy, ok = gated(blockLoudness(energy) - relativeGate); !ok {
+		return absoluteGate
+	}
+	return blockLoudness(energy)
+}
+
+// blockLoudness converts the mean square of K-weighted mono samples to LUFS
+func blockLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy+1e-20)
+}
+
+// biquad is a second order IIR filter in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// filter processes the next sample
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x1, f.x2 = x, f.x1
+	f.y1, f.y2 = y, f.y1
+	return y
+}
+
+// highShelf is the first K-weighting stage of BS.1770 modelling the head,
+// derived for the sample rate from the analog prototype of the 48kHz filter
+func highShelf(rate int) biquad {
+	const (
+		gain = 3.999843853973347
+		f0   = 1681.974450955533
+		q    = 0.7071752369554196
+	)
+	k := math.Tan(math.Pi * f0 / float64(rate))
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
+
+// kHighpass is the second K-weighting stage of BS.1770, the RLB high-pass
+func kHighpass(rate int) biquad {
+	const (
+		f0 = 38.13547087602444
+		q  = 0.5003270373238773
+	)
+	k := math.Tan(math.Pi * f0 / float64(rate))
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
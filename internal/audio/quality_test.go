@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureQuality(t *testing.T) {
+	t.Run("tone", func(t *testing.T) {
+		samples := make([]float32, 3*SampleRate)
+		addTone(samples, 0, 3*time.Second, 0.1, 997)
+
+		report := MeasureQuality(samples)
+		// A full scale 997Hz sine is -3.01 LUFS by definition
+		assert.InDelta(t, -23.01, report.Loudness, 0.1)
+		assert.InDelta(t, -20, report.Peak, 0.01)
+		assert.Zero(t, report.ClippingRatio)
+	})
+
+	t.Run("speech in noise", func(t *testing.T) {
+		samples := testNoise(4 * time.Second)
+		addTone(samples, time.Second, 2*time.Second, 0.1, 300)
+
+		report := MeasureQuality(samples)
+		// The tone is at -23dBFS, the noise at -60dBFS
+		assert.InDelta(t, 37, report.SNR, 1)
+		assert.InDelta(t, 0.75, report.SilenceRatio, 0.02)
+		assert.Empty(t, report.Issues)
+	})
+
+	t.Run("noisy", func(t *testing.T) {
+		samples := testNoise(4 * time.Second)
+		for i := range samples {
+			samples[i] *= 30
+		}
+		addTone(samples, time.Second, 2*time.Second, 0.05, 300)
+
+		report := MeasureQuality(samples)
+		assert.Less(t, report.SNR, float64(NoisySNR))
+		// The tone drowns in the noise, no speech is found either
+		assert.Equal(t, []string{IssueNoisy, IssueSilent}, report.Issues)
+	})
+
+	t.Run("clipping", func(t *testing.T) {
+		samples := testNoise(4 * time.Second)
+		addTone(samples, time.Second, 3*time.Second, 2, 300)
+		for i, s := range samples {
+			samples[i] = float32(math.Max(-1, math.Min(1, float64(s))))
+		}
+
+		report := MeasureQuality(samples)
+		assert.InDelta(t, 0, report.Peak, 0.01)
+		assert.Greater(t, report.ClippingRatio, 0.2)
+		assert.Equal(t, []string{IssueClipping}, report.Issues)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		report := MeasureQuality(make([]float32, SampleRate))
+		assert.Equal(t, float64(absoluteGate), report.Loudness)
+		assert.Equal(t, float64(minLevel), report.Peak)
+		assert.Equal(t, 1.0, report.SilenceRatio)
+		assert.Equal(t, []string{IssueQuiet, IssueSilent}, report.Issues)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		report := MeasureQuality(nil)
+		assert.Equal(t, float64(absoluteGate), report.Loudness)
+		assert.Equal(t, []string{IssueQuiet, IssueSilent}, report.Issues)
+	})
+}
+
+func TestMeasureLevel(t *testing.T) {
+	samples := make([]float32, SampleRate/10)
+	addTone(samples, 0, 100*time.Millisecond, 0.5, 1000)
+	level := MeasureLevel(samples)
+	// A sine's RMS is 3dB below its peak
+	assert.InDelta(t, -9.03, level.RMS, 0.05)
+	assert.InDelta(t, -6.02, level.Peak, 0.05)
+	assert.Zero(t, level.Clipped)
+
+	level = MeasureLevel([]float32{1, -1, 0.5, 0})
+	assert.InDelta(t, 0, level.Peak, 0.01)
+	assert.Equal(t, 2, level.Clipped)
+
+	level = MeasureLevel(nil)
+	assert.Equal(t, Level{RMS: minLevel, Peak: minLevel}, level)
+}
+
+func TestAnalyzeQuality(t *testing.T) {
+	samples := testNoise(4 * time.Second)
+	addTone(samples, time.Second, 2*time.Second, 0.1, 300)
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(math.Round(float64(s)*32767))))
+	}
+	path := filepath.Join(t.TempDir(), "speech.wav")
+	require.NoError(t, os.WriteFile(path, buildTestWAV(1, 1, SampleRate, 16, data), 0644))
+
+	// The processing is skipped, the audio is measured as recorded
+	report, err := AnalyzeQuality(path, Options{Tempo: 2, NoiseSuppression: DefaultNoiseSuppression})
+	require.NoError(t, err)
+	expected := MeasureQuality(samples)
+	assert.InDelta(t, expected.Loudness, report.Loudness, 0.01)
+	assert.InDelta(t, expected.SNR, report.SNR, 0.1)
+	assert.InDelta(t, expected.SilenceRatio, report.SilenceRatio, 0.01)
+
+	_, err = AnalyzeQuality(path, Options{DurationLimit: time.Second})
+	assert.ErrorIs(t, err, ErrTooLong)
+}
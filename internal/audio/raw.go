@@ -0,0 +1,182 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// StdinPath is the file path which reads the audio from standard input
+const StdinPath = "-"
+
+// RawFormat describes headerless PCM audio, e.g. piped from telephony
+// software, which has no header to learn the format from
+type RawFormat struct {
+	// Encoding is the sample format in FFmpeg's naming, e.g. s16le, see RawEncodings
+	Encoding   string
+	SampleRate int
+	Channels   int
+}
+
+// rawEncoding describes the layout of a raw sample format
+type rawEncoding struct {
+	size int
+	// decode converts a single sample to the range [-1, 1]
+	decode func(b []byte) float64
+}
+
+// rawEncodings lists the supported raw sample formats by FFmpeg's names
+var rawEncodings = map[string]rawEncoding{
+	"u8":    {1, func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }},
+	"s8":    {1, func(b []byte) float64 { return float64(int8(b[0])) / 128 }},
+	"s16le": {2, func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }},
+	"s16be": {2, func(b []byte) float64 { return float64(int16(binary.BigEndian.Uint16(b))) / (1 << 15) }},
+	"s24le": {3, func(b []byte) float64 {
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	}},
+	"s24be": {3, func(b []byte) float64 {
+		return float64(int32(uint32(b[2])<<8|uint32(b[1])<<16|uint32(b[0])<<24)>>8) / (1 << 23)
+	}},
+	"s32le": {4, func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }},
+	"s32be": {4, func(b []byte) float64 { return float64(int32(binary.BigEndian.Uint32(b))) / (1 << 31) }},
+	"f32le": {4, func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }},
+	"f32be": {4, func(b []byte) float64 { return float64(math.Float32frombits(binary.BigEndian.Uint32(b))) }},
+	"f64le": {8, func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }},
+	"f64be": {8, func(b []byte) float64 { return math.Float64frombits(binary.BigEndian.Uint64(b)) }},
+	"mulaw": {1, func(b []byte) float64 { return decodeMulaw(b[0]) }},
+	"alaw":  {1, func(b []byte) float64 { return decodeAlaw(b[0]) }},
+}
+
+// RawEncodings returns the names of the supported raw sample formats
+func RawEncodings() []string {
+	names := make([]string, 0, len(rawEncodings))
+	for name := range rawEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the encoding is known and the rate and channels are set
+func (f RawFormat) Validate() error {
+	if _, ok := rawEncodings[f.Encoding]; !ok {
+		return fmt.Errorf("invalid raw audio format %q: use one of %s", f.Encoding, strings.Join(RawEncodings(), ", "))
+	}
+	if f.SampleRate < 1 {
+		return fmt.Errorf("invalid raw audio sample rate %d: it must be positive", f.SampleRate)
+	}
+	if f.Channels < 1 {
+		return fmt.Errorf("invalid raw audio channel count %d: it must be positive", f.Channels)
+	}
+	return nil
+}
+
+// checkRaw validates the raw format of the options, if there is one
+func checkRaw(opts Options) error {
+	if opts.Raw == nil {
+		return nil
+	}
+	return opts.Raw.Validate()
+}
+
+// openInput opens the audio file, or standard input for StdinPath
+func openInput(filePath string) (io.ReadCloser, error) {
+	if filePath == StdinPath {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audio file: file does not exist")
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	return file, nil
+}
+
+// openDecoder creates the in-process decoder, for raw PCM when the options
+// describe it and for the format found by sniffing otherwise
+func openDecoder(input *bufio.Reader, opts Options) (pcmDecoder, error) {
+	if opts.Raw != nil {
+		return newRawDecoder(input, *opts.Raw), nil
+	}
+	return newNativeDecoder(input)
+}
+
+// rawDecoder reads headerless PCM samples
+type rawDecoder struct {
+	data     io.Reader
+	format   RawFormat
+	encoding rawEncoding
+	buf      []byte
+}
+
+// newRawDecoder reads the input as samples of the validated raw format
+func newRawDecoder(input io.Reader, format RawFormat) *rawDecoder {
+	return &rawDecoder{data: input, format: format, encoding: rawEncodings[format.Encoding]}
+}
+
+func (d *rawDecoder) sampleRate() int { return d.format.SampleRate }
+
+func (d *rawDecoder) channels() int { return d.format.Channels }
+
+func (d *rawDecoder) read(p []float32) (int, error) {
+	frameBytes := d.format.Channels * d.encoding.size
+	size := len(p) / d.format.Channels * frameBytes
+	if cap(d.buf) < size {
+		d.buf = make([]byte, size)
+	}
+
+	n, err := io.ReadFull(d.data, d.buf[:size])
+	// A trailing partial sample frame is dropped
+	n -= n % frameBytes
+	samples := n / d.encoding.size
+	for i := 0; i < samples; i++ {
+		p[i] = float32(d.encoding.decode(d.buf[i*d.encoding.size : (i+1)*d.encoding.size]))
+	}
+
+	switch err {
+	case nil:
+		return samples, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		return samples, io.EOF
+	default:
+		return samples, fmt.Errorf("failed to read audio data: %w", err)
+	}
+}
+
+// decodeMulaw expands a G.711 µ-law sample
+func decodeMulaw(b byte) float64 {
+	u := ^b
+	t := (int(u&0x0F)<<3 + 0x84) << (u & 0x70 >> 4)
+	if u&0x80 != 0 {
+		return float64(0x84-t) / (1 << 15)
+	}
+	return float64(t-0x84) / (1 << 15)
+}
+
+// decodeAlaw expands a G.711 A-law sample
+func decodeAlaw(b byte) float64 {
+	a := b ^ 0x55
+	t := int(a&0x0F) << 4
+	switch segment := a & 0x70 >> 4; segment {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (segment - 1)
+	}
+	if a&0x80 == 0 {
+		t = -t
+	}
+	return float64(t) / (1 << 15)
+}
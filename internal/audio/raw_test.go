@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawFormatValidate(t *testing.T) {
+	assert.NoError(t, RawFormat{Encoding: "s16le", SampleRate: 8000, Channels: 1}.Validate())
+	assert.ErrorContains(t, RawFormat{Encoding: "pcm", SampleRate: 8000, Channels: 1}.Validate(), "invalid raw audio format")
+	assert.ErrorContains(t, RawFormat{Encoding: "mulaw", Channels: 1}.Validate(), "invalid raw audio sample rate")
+	assert.ErrorContains(t, RawFormat{Encoding: "alaw", SampleRate: 8000}.Validate(), "invalid raw audio channel count")
+}
+
+func TestLoadRawAudio(t *testing.T) {
+	t.Run("s16le resampled to 16kHz", func(t *testing.T) {
+		// 0.5s of a 440Hz tone at 8kHz
+		var raw bytes.Buffer
+		for i := 0; i < 4000; i++ {
+			v := int16(0.5 * 32767 * math.Sin(2*math.Pi*440*float64(i)/8000))
+			binary.Write(&raw, binary.LittleEndian, v)
+		}
+
+		opts := Options{Filter: FilterNone, Raw: &RawFormat{Encoding: "s16le", SampleRate: 8000, Channels: 1}}
+		samples, err := LoadAudioFromReaderWithOptions(&raw, opts)
+		require.NoError(t, err)
+		require.Len(t, samples, SampleRate/2)
+		for i := 500; i < 7500; i += 97 {
+			expected := 0.5 * math.Sin(2*math.Pi*440*float64(i)/SampleRate)
+			assert.InDelta(t, expected, samples[i], 0.02, "sample %d", i)
+		}
+	})
+
+	t.Run("channel selection", func(t *testing.T) {
+		// Stereo f32le at 16kHz with silence on the left
+		var raw bytes.Buffer
+		for i := 0; i < 1600; i++ {
+			binary.Write(&raw, binary.LittleEndian, []float32{0, 0.25})
+		}
+
+		opts := Options{Filter: FilterNone, Channel: 2, Raw: &RawFormat{Encoding: "f32le", SampleRate: SampleRate, Channels: 2}}
+		samples, err := LoadAudioFromReaderWithOptions(&raw, opts)
+		require.NoError(t, err)
+		require.Len(t, samples, 1600)
+		assert.InDelta(t, 0.25, samples[800], 1e-6)
+	})
+
+	t.Run("a header is not sniffed", func(t *testing.T) {
+		// Raw input which happens to look like a WAV header is still raw
+		raw := append([]byte("RIFF\x00\x00\x00\x00WAVE"), make([]byte, 1000)...)
+		opts := Options{Raw: &RawFormat{Encoding: "u8", SampleRate: SampleRate, Channels: 1}}
+		samples, err := LoadAudioFromReaderWithOptions(bytes.NewReader(raw), opts)
+		require.NoError(t, err)
+		assert.Len(t, samples, len(raw))
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		opts := Options{Raw: &RawFormat{Encoding: "s16le", Channels: 1}}
+		_, err := LoadAudioFromReaderWithOptions(bytes.NewReader(make([]byte, 100)), opts)
+		assert.ErrorContains(t, err, "invalid raw audio sample rate")
+	})
+}
+
+func TestDecodeG711(t *testing.T) {
+	// Reference values of the G.711 tables scaled to 16 bits
+	assert.Equal(t, 0.0, decodeMulaw(0xFF))
+	assert.Equal(t, -32124.0/32768, decodeMulaw(0x00))
+	assert.Equal(t, 32124.0/32768, decodeMulaw(0x80))
+	assert.Equal(t, 8.0/32768, decodeAlaw(0xD5))
+	assert.Equal(t, -8.0/32768, decodeAlaw(0x55))
+	assert.Equal(t, 32256.0/32768, decodeAlaw(0xAA))
+}
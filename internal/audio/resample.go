@@ -0,0 +1,134 @@
+package audio
+
+import "math"
+
+// resamplerZeroCrossings is the number of sinc zero crossings on each side
+// of the filter kernel, more gives a steeper anti-aliasing filter
+const resamplerZeroCrossings = 16
+
+// resampler converts a stream of samples between sample rates using a
+// windowed sinc filter, it keeps just enough history between calls to
+// process the input in arbitrary blocks
+type resampler struct {
+	// step is the distance between output samples in input samples
+	step float64
+	// cutoff is the low-pass cutoff relative to the input Nyquist frequency
+	cutoff float64
+	// halfWidth is the kernel reach on each side in input samples
+	halfWidth int
+
+	buf      []float32
+	bufStart int64
+	next     int64
+	flushed  bool
+}
+
+// newResampler creates a resampler from inRate to outRate
+func newResampler(inRate, outRate int) *resampler {
+	r := &resampler{
+		step:   float64(inRate) / float64(outRate),
+		cutoff: 1,
+	}
+	if outRate < inRate {
+		// Filter out frequencies the output rate cannot represent
+		r.cutoff = float64(outRate) / float64(inRate)
+	}
+	r.halfWidth = int(math.Ceil(resamplerZeroCrossings / r.cutoff))
+	return r
+}
+
+// passthrough reports whether the rates are equal and nothing needs to be done
+func (r *resampler) passthrough() bool {
+	return r.step == 1
+}
+
+// process adds input samples and returns every output sample that can be
+// computed so far
+func (r *resampler) process(in []float32) []float32 {
+	if r.passthrough() {
+		return in
+	}
+	r.buf = append(r.buf, in...)
+	return r.drain(false)
+}
+
+// flush returns the remaining output, treating the input after the end as silence
+func (r *resampler) flush() []float32 {
+	if r.passthrough() || r.flushed {
+		return nil
+	}
+	r.flushed = true
+	return r.drain(true)
+}
+
+// drain computes output samples whose kernel is covered by the buffered input
+func (r *resampler) drain(final bool) []float32 {
+	end := r.bufStart + int64(len(r.buf))
+
+	var out []float32
+	for {
+		center := float64(r.next) * r.step
+		if final {
+			if center >= float64(end) {
+				break
+			}
+		} else if int64(center)+int64(r.halfWidth) >= end {
+			break
+		}
+
+		out = append(out, r.sample(center))
+		r.next++
+	}
+
+	// Drop history which no future output sample can reach
+	keepFrom := int64(float64(r.next)*r.step) - int64(r.halfWidth)
+	if drop := keepFrom - r.bufStart; drop > 0 {
+		if drop > int64(len(r.buf)) {
+			drop = int64(len(r.buf))
+		}
+		r.buf = append(r.buf[:0], r.buf[drop:]...)
+		r.bufStart += drop
+	}
+
+	return out
+}
+
+// sample evaluates the filtered input at a fractional input position
+func (r *resampler) sample(center float64) float32 {
+	first := int64(math.Floor(center)) - int64(r.halfWidth) + 1
+	last := int64(math.Floor(center)) + int64(r.halfWidth)
+
+	var sum, weights float64
+	for i := first; i <= last; i++ {
+		x := center - float64(i)
+		w := r.cutoff * sinc(r.cutoff*x) * blackman(x/float64(r.halfWidth))
+		weights += w
+
+		idx := i - r.bufStart
+		if idx >= 0 && idx < int64(len(r.buf)) {
+			sum += w * float64(r.buf[idx])
+		}
+	}
+
+	// Normalise so the filter has unity gain for constant signals
+	if weights != 0 {
+		sum /= weights
+	}
+	return float32(sum)
+}
+
+// sinc is the normalised sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window over [-1, 1]
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
@@ -0,0 +1,295 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SniffSize is the number of leading bytes SniffContainer looks at
+const SniffSize = wavHeaderPeek
+
+// container describes a file format recognised by its leading bytes
+type container struct {
+	// demuxer is the FFmpeg input format, empty if FFmpeg should guess
+	demuxer   string
+	extension string
+}
+
+// containers maps the names returned by SniffContainer to their details
+var containers = map[string]container{
+	"wav":      {"wav", ".wav"},
+	"rf64":     {"wav", ".wav"},
+	"w64":      {"w64", ".w64"},
+	"aiff":     {"aiff", ".aiff"},
+	"caf":      {"caf", ".caf"},
+	"au":       {"au", ".au"},
+	"voc":      {"voc", ".voc"},
+	"flac":     {"flac", ".flac"},
+	"mp3":      {"mp3", ".mp3"},
+	"aac":      {"aac", ".aac"},
+	"ac3":      {"ac3", ".ac3"},
+	"amr":      {"amr", ".amr"},
+	"ogg":      {"ogg", ".ogg"},
+	"wavpack":  {"wv", ".wv"},
+	"ape":      {"ape", ".ape"},
+	"musepack": {"", ".mpc"},
+	"tta":      {"tta", ".tta"},
+	"mp4":      {"mov", ".mp4"},
+	"matroska": {"matroska", ".mkv"},
+	"webm":     {"matroska", ".webm"},
+	"asf":      {"asf", ".wma"},
+	"avi":      {"avi", ".avi"},
+	"flv":      {"flv", ".flv"},
+	"mpegts":   {"mpegts", ".ts"},
+	"mpeg":     {"mpeg", ".mpg"},
+	"rm":       {"rm", ".rm"},
+}
+
+// SniffContainer identifies the container format from the leading bytes of a
+// file, e.g. "wav", "mp3", "ogg", "mp4" or "matroska". Empty means the format
+// is not known, SniffSize bytes are enough to recognise every format.
+func SniffContainer(header []byte) string {
+	name, _ := sniffContainer(header)
+	return name
+}
+
+// sniffContainer identifies the container format, confirmed is false for a
+// guess such as MP3 behind an ID3 tag larger than the header
+func sniffContainer(header []byte) (name string, confirmed bool) {
+	has := func(offset int, magic string) bool {
+		return len(header) >= offset+len(magic) && string(header[offset:offset+len(magic)]) == magic
+	}
+
+	switch {
+	case has(0, "RIFF") && has(8, "WAVE"):
+		return "wav", true
+	case has(0, "RIFF") && has(8, "AVI "):
+		return "avi", true
+	case has(0, "RF64") && has(8, "WAVE"):
+		return "rf64", true
+	case has(0, "riff\x2e\x91\xcf\x11"):
+		return "w64", true
+	case has(0, "FORM") && (has(8, "AIFF") || has(8, "AIFC")):
+		return "aiff", true
+	case has(0, "caff"):
+		return "caf", true
+	case has(0, ".snd"):
+		return "au", true
+	case has(0, "Creative Voice File\x1a"):
+		return "voc", true
+	case has(0, "fLaC"):
+		return "flac", true
+	case has(0, "ID3"):
+		// Tags may precede other formats than MP3
+		if rest, ok := skipID3(header); ok {
+			if name, confirmed := sniffContainer(rest); name != "" {
+				return name, confirmed
+			}
+		}
+		return "mp3", false
+	case has(0, "#!AMR"):
+		return "amr", true
+	case has(0, "OggS"):
+		return "ogg", true
+	case has(0, "wvpk"):
+		return "wavpack", true
+	case has(0, "MAC "):
+		return "ape", true
+	case has(0, "MPCK") || has(0, "MP+"):
+		return "musepack", true
+	case has(0, "TTA1"):
+		return "tta", true
+	case has(4, "ftyp"):
+		return "mp4", true
+	case has(0, "\x1a\x45\xdf\xa3"):
+		// The EBML header names the document type
+		if bytes.Contains(header[:min(len(header), 64)], []byte("webm")) {
+			return "webm", true
+		}
+		return "matroska", true
+	case has(0, "\x30\x26\xb2\x75\x8e\x66\xcf\x11"):
+		return "asf", true
+	case has(0, "FLV\x01"):
+		return "flv", true
+	case has(0, ".RMF"):
+		return "rm", true
+	case has(0, "\x00\x00\x01\xba"):
+		return "mpeg", true
+	case isMPEGTS(header):
+		return "mpegts", true
+	case isMPEGAudioFrame(header):
+		return "mp3", true
+	case isADTS(header):
+		return "aac", true
+	case isAC3(header):
+		return "ac3", true
+	}
+	return "", false
+}
+
+// SniffFile identifies the container format of a file, see SniffContainer
+func SniffFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, SniffSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read audio file: %w", err)
+	}
+	return SniffContainer(header[:n]), nil
+}
+
+// peekContainer identifies the container format without consuming the input,
+// empty unless the format is confirmed so FFmpeg is never told a guess
+func peekContainer(r *bufio.Reader) string {
+	header, _ := r.Peek(SniffSize)
+	if name, confirmed := sniffContainer(header); confirmed {
+		return name
+	}
+	return ""
+}
+
+// ContainerExtension returns the usual file extension of a container
+// returned by SniffContainer, with the leading dot
+func ContainerExtension(name string) string {
+	return containers[name].extension
+}
+
+// ffmpegDemuxer returns FFmpeg's input format for the container, empty to let
+// FFmpeg guess
+func ffmpegDemuxer(name string) string {
+	return containers[name].demuxer
+}
+
+// skipID3 returns what follows an ID3v2 tag if the whole tag is in the header
+func skipID3(header []byte) ([]byte, bool) {
+	if len(header) < 10 {
+		return nil, false
+	}
+	// The size is syncsafe, 7 bits per byte, and excludes the tag header and footer
+	size := 10 + (int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9]))
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	if size >= len(header) {
+		return nil, false
+	}
+	return header[size:], true
+}
+
+// isMPEGTS reports whether the header holds consecutive transport stream packets
+func isMPEGTS(header []byte) bool {
+	const packetSize = 188
+	if len(header) < 2*packetSize+1 {
+		return false
+	}
+	for offset := 0; offset < len(header); offset += packetSize {
+		if header[offset] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
+
+// mpegBitRates holds the bit rates in kbit/s by MPEG-1 layer and, last, of
+// MPEG-2 and 2.5 layer I and layers II and III, indexed by the header field
+var mpegBitRates = [5][15]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// mpegSampleRates holds the sample rates of MPEG-1, indexed by the header
+// field, MPEG-2 halves and MPEG-2.5 quarters them
+var mpegSampleRates = [3]int{44100, 48000, 32000}
+
+// isMPEGAudioFrame reports whether the header starts with an MPEG audio frame
+// of any layer, followed by another frame when the header reaches it
+func isMPEGAudioFrame(header []byte) bool {
+	length := mpegFrameLength(header)
+	if length == 0 {
+		return false
+	}
+	if len(header) < length+4 {
+		// A UTF-16LE byte order mark looks like a layer I frame with a checksum
+		return header[1] != 0xFE
+	}
+	return mpegFrameLength(header[length:]) > 0
+}
+
+// mpegFrameLength returns the length in bytes of the MPEG audio frame at the
+// start of the header, zero if there is no valid frame header. Free format
+// frames, without a bit rate, are not recognised.
+func mpegFrameLength(header []byte) int {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return 0
+	}
+	version, layer := (header[1]>>3)&0x3, (header[1]>>1)&0x3
+	rateIndex, sampleIndex := header[2]>>4, (header[2]>>2)&0x3
+	if version == 0x1 || layer == 0 || rateIndex == 0 || rateIndex == 0xF || sampleIndex == 0x3 {
+		return 0
+	}
+
+	// The layer field counts down, 3 is layer I
+	table := 3 - int(layer)
+	sampleRate := mpegSampleRates[sampleIndex]
+	if version != 0x3 {
+		table = 4
+		if layer == 0x3 {
+			table = 3
+		}
+		sampleRate /= 2
+		if version == 0 {
+			sampleRate /= 2
+		}
+	}
+	bitRate := mpegBitRates[table][rateIndex] * 1000
+	padding := int(header[2]>>1) & 0x1
+
+	switch {
+	case layer == 0x3:
+		return (12*bitRate/sampleRate + padding) * 4
+	case layer == 0x1 && version != 0x3:
+		// MPEG-2 and 2.5 layer III frames hold half the samples
+		return 72*bitRate/sampleRate + padding
+	default:
+		return 144*bitRate/sampleRate + padding
+	}
+}
+
+// isAC3 reports whether the header starts with an AC-3 or E-AC-3 frame
+func isAC3(header []byte) bool {
+	if len(header) < 6 || header[0] != 0x0B || header[1] != 0x77 {
+		return false
+	}
+	// The bitstream id is 8 for AC-3 and up to 16 for E-AC-3
+	bsid := header[5] >> 3
+	if bsid > 16 {
+		return false
+	}
+	if bsid > 10 {
+		return true
+	}
+	// AC-3 codes the sample rate and frame size after the checksum
+	return header[4]>>6 != 0x3 && header[4]&0x3F < 38
+}
+
+// isADTS reports whether the header starts with an AAC ADTS frame
+func isADTS(header []byte) bool {
+	if len(header) < 7 || header[0] != 0xFF || header[1]&0xF6 != 0xF0 {
+		return false
+	}
+	// The frame length spans 13 bits of bytes 3 to 5
+	length := int(binary.BigEndian.Uint32(header[2:6])>>5) & 0x1FFF
+	return length >= 7
+}
@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContainer(t *testing.T) {
+	id3 := func(body string) []byte {
+		// A tag of 16 bytes, the size is syncsafe
+		return append([]byte("ID3\x04\x00\x00\x00\x00\x00\x10"), append(make([]byte, 16), body...)...)
+	}
+	tests := []struct {
+		name     string
+		header   []byte
+		expected string
+	}{
+		{"wav", buildTestWAV(wavFormatPCM, 1, SampleRate, 16, nil), "wav"},
+		{"avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), "avi"},
+		{"aiff", []byte("FORM\x00\x00\x00\x00AIFFCOMM"), "aiff"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "flac"},
+		{"mp3 frame", []byte("\xff\xfb\x90\x64\x00"), "mp3"},
+		{"mp2 frame", []byte("\xff\xfd\x90\x64\x00"), "mp3"},
+		{"mp3 after id3", id3("\xff\xfb\x90\x64"), "mp3"},
+		{"flac after id3", id3("fLaC"), "flac"},
+		{"id3 larger than the header", []byte("ID3\x04\x00\x00\x00\x01\x00\x00"), "mp3"},
+		{"mp3 frames", append(append([]byte("\xff\xfb\x90\x64"), make([]byte, 413)...), "\xff\xfb\x90\x64"...), "mp3"},
+		{"mp3 sync without a next frame", append([]byte("\xff\xfb\x90\x64"), make([]byte, 600)...), ""},
+		{"mp3 sync with an invalid bit rate", []byte("\xff\xfb\xf0\x64"), ""},
+		{"utf-16 text", []byte("\xff\xfeh\x00e\x00l\x00l\x00o\x00"), ""},
+		{"ac3", []byte("\x0b\x77\x00\x00\x1c\x40"), "ac3"},
+		{"eac3", []byte("\x0b\x77\x01\xff\x3f\x86"), "ac3"},
+		{"ac3 sync with an invalid frame size", []byte("\x0b\x77\x00\x00\x3f\x40"), ""},
+		{"adts", []byte("\xff\xf1\x50\x80\x02\x1f\xfc"), "aac"},
+		{"ogg", []byte("OggS\x00\x02"), "ogg"},
+		{"mp4", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"), "mp4"},
+		{"matroska", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska"), "matroska"},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "webm"},
+		{"asf", []byte("\x30\x26\xb2\x75\x8e\x66\xcf\x11\xa6\xd9"), "asf"},
+		{"amr", []byte("#!AMR\n"), "amr"},
+		{"mpegts", bytes.Repeat(append([]byte{0x47}, make([]byte, 187)...), 3), "mpegts"},
+		{"text", []byte("invalid data"), ""},
+		{"empty", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, SniffContainer(test.header))
+		})
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	container, err := SniffFile(filepath.Join("testdata", "sample.ogg"))
+	require.NoError(t, err)
+	assert.Equal(t, "ogg", container)
+	assert.Equal(t, ".ogg", ContainerExtension(container))
+
+	path := filepath.Join(t.TempDir(), "short")
+	require.NoError(t, os.WriteFile(path, []byte("fLaC"), 0644))
+	container, err = SniffFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "flac", container)
+
+	_, err = SniffFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestPeekContainer(t *testing.T) {
+	// The format behind an ID3 tag larger than the header is only a guess
+	tag := append([]byte("ID3\x04\x00\x00\x00\x01\x00\x00"), make([]byte, SniffSize)...)
+	assert.Equal(t, "mp3", SniffContainer(tag))
+	assert.Empty(t, peekContainer(bufio.NewReader(bytes.NewReader(tag))))
+
+	assert.Equal(t, "flac", peekContainer(bufio.NewReader(bytes.NewReader([]byte("fLaC\x00\x00\x00\x22")))))
+}
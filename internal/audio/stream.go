@@ -0,0 +1,335 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultFrameSize is the number of samples per streamed frame, one second of audio
+const DefaultFrameSize = SampleRate
+
+// streamBuffer is the number of decoded frames held before the decoder blocks
+const streamBuffer = 4
+
+// Stream decodes audio incrementally and yields fixed-size frames of samples,
+// so memory use is bounded no matter how large the input is
+type Stream struct {
+	frames    chan []float32
+	done      chan struct{}
+	finished  chan struct{}
+	err       error
+	stop      func()
+	closeOnce sync.Once
+
+	// limit is the number of samples allowed by Options.DurationLimit, zero
+	// allows any number
+	limit         int
+	limitDuration time.Duration
+
+	// post is the in-process processing of the decoded samples, applied
+	// after FFmpeg or the in-process decoders
+	post     sampleStage
+	tooLong  bool
+	produced int
+	peak     float32
+}
+
+// OpenStream starts decoding the audio file at the given path, StdinPath
+// reads standard input
+func OpenStream(filePath string, frameSize int, opts Options) (*Stream, error) {
+	file, err := openInput(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := NewStream(file, frameSize, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Release the file once decoding has finished
+	go func() {
+		<-stream.finished
+		file.Close()
+	}()
+
+	return stream, nil
+}
+
+// NewStream starts decoding the input. WAV files already in whisper's format
+// are decoded in-process unless an audio filter was requested, as are WAV,
+// FLAC, MP3, Ogg Vorbis and Ogg Opus when FFmpeg is not available, the
+// default normalization is then applied in-process. Everything else is
+// piped to FFmpeg as it is read, told the format found by content sniffing,
+// the input is never buffered as a whole. Raw PCM described by Options.Raw is
+// decoded in-process unless an audio filter was requested.
+func NewStream(input io.Reader, frameSize int, opts Options) (*Stream, error) {
+	if frameSize <= 0 {
+		frameSize = DefaultFrameSize
+	}
+
+	if _, err := ParseFilter(opts.Filter); err != nil {
+		return nil, err
+	}
+	if err := checkRaw(opts); err != nil {
+		return nil, err
+	}
+	if err := CheckTempo(opts.Tempo); err != nil {
+		return nil, err
+	}
+	if err := CheckNoiseSuppression(opts.NoiseSuppression); err != nil {
+		return nil, err
+	}
+
+	haveFFmpeg := ffmpegAvailable()
+	filtered := requestsFilter(opts.Filter)
+	if filtered && !haveFFmpeg {
+		return nil, errorf(ErrDecoderMissing, "unsupported audio filter: %q needs ffmpeg", opts.Filter)
+	}
+
+	reader := bufio.NewReaderSize(input, wavHeaderPeek)
+	if opts.Raw != nil {
+		if !filtered {
+			return newNativeStream(reader, frameSize, opts)
+		}
+		return newFFmpegStream(reader, "", frameSize, opts)
+	}
+	if format, ok := peekWAVFormat(reader); (ok && format.isWhisperFormat() && !filtered) || !haveFFmpeg {
+		return newNativeStream(reader, frameSize, opts)
+	}
+
+	return newFFmpegStream(reader, peekContainer(reader), frameSize, opts)
+}
+
+// newStream creates a stream, stop is called to abort the decoder early
+func newStream(opts Options, stop func()) *Stream {
+	return &Stream{
+		frames:        make(chan []float32, streamBuffer),
+		done:          make(chan struct{}),
+		finished:      make(chan struct{}),
+		stop:          stop,
+		limit:         limitSamples(opts),
+		limitDuration: opts.DurationLimit,
+		post:          newNoiseSuppressor(opts.NoiseSuppression),
+	}
+}
+
+// Frames returns the channel of decoded frames, it is closed at the end of
+// the input or on error. The last frame may be shorter than the frame size.
+func (s *Stream) Frames() <-chan []float32 {
+	return s.frames
+}
+
+// Err returns the decoding error, it is only valid after Frames is closed.
+// Audio exceeding Options.DurationLimit is stopped with ErrTooLong.
+func (s *Stream) Err() error {
+	<-s.finished
+	if s.tooLong {
+		return errorf(ErrTooLong, "audio too long: longer than the limit of %s", s.limitDuration)
+	}
+	return s.err
+}
+
+// CheckAudible returns ErrEmpty when nothing or only silence was decoded, it
+// waits until decoding has finished
+func (s *Stream) CheckAudible() error {
+	<-s.finished
+	return audibleError(s.produced, s.peak)
+}
+
+// AudibleFrames reads frames until one is audible and returns the frames from
+// the first one on, so empty or silent input and decoding errors are reported
+// before anything is transcribed. The leading silence is held in memory. The
+// returned channel takes the place of Frames, it is closed at the end of the
+// input or when the stream is closed.
+func (s *Stream) AudibleFrames() (<-chan []float32, error) {
+	var held [][]float32
+	for frame := range s.frames {
+		held = append(held, frame)
+		if peakLevel(frame) <= silenceLevel {
+			continue
+		}
+
+		frames := make(chan []float32, streamBuffer)
+		go func() {
+			defer close(frames)
+			forward := func(frame []float32) bool {
+				select {
+				case frames <- frame:
+					return true
+				case <-s.done:
+					return false
+				}
+			}
+			for _, frame := range held {
+				if !forward(frame) {
+					return
+				}
+			}
+			for frame := range s.frames {
+				if !forward(frame) {
+					return
+				}
+			}
+		}()
+		return frames, nil
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, s.CheckAudible()
+}
+
+// Close stops decoding and waits for the decoder to exit
+func (s *Stream) Close() error {
+	s.abort()
+	<-s.finished
+	return nil
+}
+
+// abort stops decoding without waiting for the decoder
+func (s *Stream) abort() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.stop != nil {
+			s.stop()
+		}
+	})
+}
+
+// ReadAll collects all remaining frames into a single slice, it returns
+// ErrEmpty when no samples were decoded
+func (s *Stream) ReadAll() ([]float32, error) {
+	var samples []float32
+	for frame := range s.frames {
+		samples = append(samples, frame...)
+	}
+	if err := s.Err(); err != nil {
+		return samples, err
+	}
+	if len(samples) == 0 {
+		return nil, audibleError(0, 0)
+	}
+	return samples, nil
+}
+
+// send delivers a frame unless the stream was closed, returning false if it
+// was. Exceeding the duration limit stops the stream.
+func (s *Stream) send(frame []float32) bool {
+	if s.limit > 0 && s.produced+len(frame) > s.limit {
+		s.tooLong = true
+		s.abort()
+		return false
+	}
+	s.produced += len(frame)
+	if peak := peakLevel(frame); peak > s.peak {
+		s.peak = peak
+	}
+
+	select {
+	case s.frames <- frame:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// stopped reports whether Close was called
+func (s *Stream) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish closes the frame channel and marks the stream as finished
+func (s *Stream) finish() {
+	close(s.frames)
+	close(s.finished)
+}
+
+// readFrames reads little-endian float32 samples and sends them as frames
+// after the in-process processing
+func (s *Stream) readFrames(r io.Reader, frameSize int) error {
+	buf := make([]byte, frameSize*4)
+	var pending []float32
+	emit := func(final bool) bool {
+		for len(pending) >= frameSize || final && len(pending) > 0 {
+			n := min(frameSize, len(pending))
+			frame := make([]float32, n)
+			copy(frame, pending[:n])
+			pending = pending[n:]
+			if !s.send(frame) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if n%4 != 0 {
+				return fmt.Errorf("invalid f32le byte length: %d", n)
+			}
+			pending = append(pending, s.post.process(decodeFloat32LE(buf[:n]))...)
+			if !emit(false) {
+				return nil
+			}
+		}
+
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			pending = append(pending, s.post.flush()...)
+			emit(true)
+			return nil
+		default:
+			return fmt.Errorf("failed to read decoded audio: %w", err)
+		}
+	}
+}
+
+// sampleStage is a step of in-process processing which accepts samples in
+// blocks of any size and holds back what it cannot compute yet
+type sampleStage interface {
+	process(in []float32) []float32
+	// flush returns the remaining output at the end of the input
+	flush() []float32
+}
+
+// stageChain runs samples through its stages in order
+type stageChain []sampleStage
+
+func (c stageChain) process(in []float32) []float32 {
+	for _, stage := range c {
+		in = stage.process(in)
+	}
+	return in
+}
+
+func (c stageChain) flush() []float32 {
+	var out []float32
+	for _, stage := range c {
+		out = append(stage.process(out), stage.flush()...)
+	}
+	return out
+}
+
+// decodeFloat32LE converts little-endian float32 bytes to samples
+func decodeFloat32LE(raw []byte) []float32 {
+	samples := make([]float32, len(raw)/4)
+	for i := range samples {
+		samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return samples
+}
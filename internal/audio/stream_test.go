@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_ReadFrames(t *testing.T) {
+	encode := func(samples []float32) []byte {
+		raw := make([]byte, len(samples)*4)
+		for i, s := range samples {
+			binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(s))
+		}
+		return raw
+	}
+
+	t.Run("fixed size frames with a short last frame", func(t *testing.T) {
+		stream := newStream(Options{}, nil)
+		go func() {
+			defer stream.finish()
+			stream.err = stream.readFrames(bytes.NewReader(encode([]float32{0.1, 0.2, 0.3, 0.4, 0.5})), 2)
+		}()
+
+		var frames [][]float32
+		for frame := range stream.Frames() {
+			frames = append(frames, frame)
+		}
+		require.NoError(t, stream.Err())
+		assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}, {0.5}}, frames)
+	})
+
+	t.Run("truncated sample", func(t *testing.T) {
+		stream := newStream(Options{}, nil)
+		go func() {
+			defer stream.finish()
+			stream.err = stream.readFrames(bytes.NewReader([]byte{0, 0, 0, 0, 1}), 4)
+		}()
+
+		_, err := stream.ReadAll()
+		assert.ErrorContains(t, err, "invalid f32le byte length")
+	})
+
+	t.Run("close stops a blocked decoder", func(t *testing.T) {
+		stopped := false
+		stream := newStream(Options{}, func() { stopped = true })
+		go func() {
+			defer stream.finish()
+			stream.err = stream.readFrames(bytes.NewReader(make([]byte, 4*100)), 1)
+		}()
+
+		<-stream.Frames()
+		require.NoError(t, stream.Close())
+		assert.True(t, stopped)
+	})
+	t.Run("duration limit", func(t *testing.T) {
+		stopped := false
+		stream := newStream(Options{DurationLimit: time.Millisecond}, func() { stopped = true })
+		go func() {
+			defer stream.finish()
+			stream.err = stream.readFrames(bytes.NewReader(make([]byte, 4*100)), 10)
+		}()
+
+		_, err := stream.ReadAll()
+		assert.ErrorIs(t, err, ErrTooLong)
+		assert.True(t, stopped)
+	})
+}
+
+func TestStream_AudibleFrames(t *testing.T) {
+	start := func(samples []float32) *Stream {
+		raw := make([]byte, len(samples)*4)
+		for i, s := range samples {
+			binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(s))
+		}
+		stream := newStream(Options{}, nil)
+		go func() {
+			defer stream.finish()
+			stream.err = stream.readFrames(bytes.NewReader(raw), 2)
+		}()
+		return stream
+	}
+
+	t.Run("leading silence is kept", func(t *testing.T) {
+		stream := start([]float32{0, 0, 0, 0.5, 0.1})
+		frames, err := stream.AudibleFrames()
+		require.NoError(t, err)
+
+		var received [][]float32
+		for frame := range frames {
+			received = append(received, frame)
+		}
+		require.NoError(t, stream.Err())
+		assert.Equal(t, [][]float32{{0, 0}, {0, 0.5}, {0.1}}, received)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		_, err := start(make([]float32, 10)).AudibleFrames()
+		assert.ErrorIs(t, err, ErrEmpty)
+	})
+
+	t.Run("nothing decoded", func(t *testing.T) {
+		_, err := start(nil).AudibleFrames()
+		assert.ErrorIs(t, err, ErrEmpty)
+	})
+
+	t.Run("close stops forwarding", func(t *testing.T) {
+		stream := start(append([]float32{0.5}, make([]float32, 100)...))
+		frames, err := stream.AudibleFrames()
+		require.NoError(t, err)
+
+		<-frames
+		require.NoError(t, stream.Close())
+		for range frames {
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Accepted tempo factors, slowing down is allowed but rarely useful
+const (
+	MinTempo = 0.5
+	MaxTempo = 4.0
+)
+
+const (
+	// tempoWindow is the WSOLA frame length, 40ms at whisper's sample rate
+	tempoWindow = 640
+	// tempoHop is the distance of output frames, half a window
+	tempoHop = tempoWindow / 2
+	// tempoSearch is how far frames may shift to line up with the previous
+	// one, 10ms in each direction
+	tempoSearch = 160
+)
+
+// CheckTempo validates a tempo factor, zero and one leave the tempo unchanged
+func CheckTempo(tempo float64) error {
+	if tempo == 0 || tempo >= MinTempo && tempo <= MaxTempo {
+		return nil
+	}
+	return fmt.Errorf("invalid tempo %g: use a factor between %g and %g, e.g. 1.25 to speed up by a quarter", tempo, MinTempo, MaxTempo)
+}
+
+// tempo returns the tempo factor of the options, one when unset
+func (o Options) tempo() float64 {
+	if o.Tempo <= 0 {
+		return 1
+	}
+	return o.Tempo
+}
+
+// OriginalTime maps a time in the audio decoded with the options back onto
+// the timeline of the input, undoing Options.Tempo
+func (o Options) OriginalTime(d time.Duration) time.Duration {
+	return time.Duration(math.Round(float64(d) * o.tempo()))
+}
+
+// limitSamples is the number of decoded samples allowed by
+// Options.DurationLimit, which refers to the input before the tempo change
+func limitSamples(opts Options) int {
+	return int(float64(durationToSamples(opts.DurationLimit)) / opts.tempo())
+}
+
+// tempoStretcher changes the tempo of a stream of samples without changing
+// the pitch using WSOLA: frames are read from the input at the scaled
+// position, shifted to where they best continue the previous frame and
+// overlap-added, it keeps just enough input between calls to process it in
+// arbitrary blocks
+type tempoStretcher struct {
+	tempo  float64
+	window []float32
+
+	in []float32
+	// inStart is the input position of in[0]
+	inStart int
+	// total is the number of input samples so far
+	total int
+	// frame is the number of frames produced so far
+	frame int
+	// prev is the input position of the previous frame
+	prev int
+	// overlap is the windowed second half of the previous frame
+	overlap  []float32
+	produced int
+	flushed  bool
+}
+
+// newTempoStretcher creates a stretcher speeding up by the tempo factor
+func newTempoStretcher(tempo float64) *tempoStretcher {
+	t := &tempoStretcher{tempo: tempo, window: make([]float32, tempoWindow)}
+	// A periodic Hann window sums to one at half a window overlap
+	for i := range t.window {
+		t.window[i] = float32(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/tempoWindow))
+	}
+	return t
+}
+
+// passthrough reports whether the tempo is unchanged and nothing needs to be done
+func (t *tempoStretcher) passthrough() bool {
+	return t.tempo == 1
+}
+
+// process adds input samples and returns every output sample that can be
+// computed so far
+func (t *tempoStretcher) process(in []float32) []float32 {
+	if t.passthrough() {
+		return in
+	}
+	t.in = append(t.in, in...)
+	t.total += len(in)
+
+	var out []float32
+	for t.ready() {
+		out = append(out, t.step()...)
+	}
+	t.discard()
+	return out
+}
+
+// flush returns the remaining output, treating the input after the end as
+// silence, the output is as long as the input divided by the tempo
+func (t *tempoStretcher) flush() []float32 {
+	if t.passthrough() || t.flushed {
+		return nil
+	}
+	t.flushed = true
+
+	expected := int(math.Round(float64(t.total) / t.tempo))
+	var out []float32
+	for t.produced < expected {
+		for !t.ready() {
+			t.in = append(t.in, make([]float32, tempoWindow)...)
+		}
+		out = append(out, t.step()...)
+	}
+	out = append(out, t.overlap...)
+	t.produced += len(t.overlap)
+
+	if excess := t.produced - expected; excess > 0 {
+		out = out[:max(len(out)-excess, 0)]
+	}
+	return out
+}
+
+// position is the input position of the next frame before alignment
+func (t *tempoStretcher) position() int {
+	return int(math.Round(float64(t.frame) * tempoHop * t.tempo))
+}
+
+// ready reports whether the input holds everything the next frame needs
+func (t *tempoStretcher) ready() bool {
+	need := t.position() + tempoSearch
+	if t.frame > 0 {
+		need = max(need, t.prev+tempoHop)
+	}
+	return need+tempoWindow <= t.inStart+len(t.in)
+}
+
+// step produces the next frame and returns the output it completes
+func (t *tempoStretcher) step() []float32 {
+	pos := t.position()
+	if t.frame > 0 {
+		pos = t.align(pos)
+	}
+	frame := t.in[pos-t.inStart : pos-t.inStart+tempoWindow]
+
+	out := make([]float32, tempoHop)
+	if t.frame == 0 {
+		// Nothing to overlap with, the start is kept as it is
+		copy(out, frame[:tempoHop])
+	} else {
+		for i := range out {
+			out[i] = t.overlap[i] + frame[i]*t.window[i]
+		}
+	}
+
+	if t.overlap == nil {
+		t.overlap = make([]float32, tempoHop)
+	}
+	for i := range t.overlap {
+		t.overlap[i] = frame[tempoHop+i] * t.window[tempoHop+i]
+	}
+
+	t.prev = pos
+	t.frame++
+	t.produced += len(out)
+	return out
+}
+
+// align returns the input position near pos whose start correlates best with
+// the natural continuation of the previous frame
+func (t *tempoStretcher) align(pos int) int {
+	natural := t.in[t.prev+tempoHop-t.inStart:][:tempoHop]
+
+	best, bestScore := pos, math.Inf(-1)
+	for candidate := max(pos-tempoSearch, t.inStart); candidate <= pos+tempoSearch; candidate++ {
+		segment := t.in[candidate-t.inStart:][:tempoHop]
+		var correlation, energy float64
+		for i, s := range segment {
+			correlation += float64(s * natural[i])
+			energy += float64(s * s)
+		}
+		// Normalise so loud candidates are not preferred for their level
+		if score := correlation / math.Sqrt(energy+1e-9); score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// discard drops input which no later frame can use
+func (t *tempoStretcher) discard() {
+	keep := min(t.position()-tempoSearch, t.prev+tempoHop)
+	if drop := keep - t.inStart; drop > 0 {
+		t.in = append([]float32(nil), t.in[drop:]...)
+		t.inStart = keep
+	}
+}
@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempoStretcher(t *testing.T) {
+	tone := make([]float32, 2*SampleRate)
+	for i := range tone {
+		tone[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/SampleRate))
+	}
+
+	for _, tempo := range []float64{0.8, 1.25, 1.5, 2.5} {
+		stretcher := newTempoStretcher(tempo)
+		var out []float32
+		// Uneven blocks as delivered by decoders
+		for start := 0; start < len(tone); start += 1234 {
+			out = append(out, stretcher.process(tone[start:min(start+1234, len(tone))])...)
+		}
+		out = append(out, stretcher.flush()...)
+
+		require.Len(t, out, int(math.Round(float64(len(tone))/tempo)), "tempo %g", tempo)
+
+		// The pitch is kept, the tone still crosses zero 880 times per second
+		crossings := 0
+		middle := out[len(out)/4 : 3*len(out)/4]
+		for i := 1; i < len(middle); i++ {
+			if (middle[i-1] < 0) != (middle[i] < 0) {
+				crossings++
+			}
+		}
+		seconds := float64(len(middle)) / SampleRate
+		assert.InDelta(t, 880, float64(crossings)/seconds, 20, "tempo %g", tempo)
+		assert.InDelta(t, 0.5, peakLevel(middle), 0.05, "tempo %g", tempo)
+	}
+
+	t.Run("unchanged tempo", func(t *testing.T) {
+		stretcher := newTempoStretcher(1)
+		assert.Equal(t, tone, stretcher.process(tone))
+		assert.Empty(t, stretcher.flush())
+	})
+}
+
+func TestTempoOptions(t *testing.T) {
+	assert.NoError(t, CheckTempo(0))
+	assert.NoError(t, CheckTempo(1.5))
+	assert.ErrorContains(t, CheckTempo(5), "invalid tempo 5")
+	assert.ErrorContains(t, CheckTempo(-1), "invalid tempo -1")
+
+	assert.Equal(t, 3*time.Second, Options{Tempo: 1.5}.OriginalTime(2*time.Second))
+	assert.Equal(t, 2*time.Second, Options{}.OriginalTime(2*time.Second))
+}
+
+func TestStreamTempo(t *testing.T) {
+	// 3s of a 16kHz tone speeds up to 2s
+	var raw bytes.Buffer
+	for i := 0; i < 3*SampleRate; i++ {
+		binary.Write(&raw, binary.LittleEndian, float32(0.5*math.Sin(2*math.Pi*300*float64(i)/SampleRate)))
+	}
+	input := raw.Bytes()
+	format := &RawFormat{Encoding: "f32le", SampleRate: SampleRate, Channels: 1}
+
+	samples, err := LoadAudioFromReaderWithOptions(bytes.NewReader(input), Options{Raw: format, Tempo: 1.5})
+	require.NoError(t, err)
+	assert.Len(t, samples, 2*SampleRate)
+
+	t.Run("duration limit refers to the input", func(t *testing.T) {
+		opts := Options{Raw: format, Tempo: 1.5, DurationLimit: 2500 * time.Millisecond}
+		_, err := LoadAudioFromReaderWithOptions(bytes.NewReader(input), opts)
+		assert.ErrorIs(t, err, ErrTooLong)
+		assert.ErrorContains(t, err, "longer than the limit of 2.5s")
+	})
+}
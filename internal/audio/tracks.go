@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StreamsAll selects every audio stream of a file, each transcribed separately
+const StreamsAll = "all"
+
+// AudioTrack describes one audio stream of a media file
+type AudioTrack struct {
+	// Number counts the audio streams of the file from 1, video and subtitle
+	// streams are not counted
+	Number     int    `json:"number"`
+	Codec      string `json:"codec"`
+	Language   string `json:"language,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Channels   int    `json:"channels"`
+	SampleRate int    `json:"sample_rate"`
+	// BitRate is in bits per second, zero when not known
+	BitRate int `json:"bit_rate,omitempty"`
+	// Default is set for the stream players pick when nothing is selected
+	Default bool `json:"default"`
+}
+
+// String describes the track for listings, e.g. "#2 eng aac 6ch (Commentary)"
+func (t AudioTrack) String() string {
+	parts := []string{fmt.Sprintf("#%d", t.Number)}
+	if t.Language != "" {
+		parts = append(parts, t.Language)
+	}
+	parts = append(parts, t.Codec, fmt.Sprintf("%dch", t.Channels))
+	if t.Title != "" {
+		parts = append(parts, fmt.Sprintf("(%s)", t.Title))
+	}
+	if t.Default {
+		parts = append(parts, "[default]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// ProbeTracks lists the audio streams of a media file. Without FFmpeg only the
+// formats decoded in-process are recognised, they have a single stream.
+func ProbeTracks(filePath string) ([]AudioTrack, error) {
+	info, err := probeContainer(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return info.Tracks, nil
+}
+
+// SelectTrack picks a track by its number counting from 1 or by language tag.
+// Two and three letter ISO 639 codes match each other, e.g. "de" selects a
+// track tagged "ger" or "deu", and region suffixes such as "en-US" are ignored.
+func SelectTrack(tracks []AudioTrack, spec string) (AudioTrack, error) {
+	spec = strings.TrimSpace(spec)
+	if number, err := strconv.Atoi(spec); err == nil {
+		if number < 1 || number > len(tracks) {
+			return AudioTrack{}, fmt.Errorf("invalid audio stream %d: the file has %d audio streams, counting from 1", number, len(tracks))
+		}
+		return tracks[number-1], nil
+	}
+
+	var available []string
+	for _, track := range tracks {
+		if sameLanguage(track.Language, spec) {
+			return track, nil
+		}
+		if track.Language != "" {
+			available = append(available, track.Language)
+		}
+	}
+	if len(available) == 0 {
+		return AudioTrack{}, fmt.Errorf("invalid audio stream %q: the audio streams have no language tags, select one by number", spec)
+	}
+	return AudioTrack{}, fmt.Errorf("invalid audio stream %q: no audio stream in this language (available: %s)", spec, strings.Join(available, ", "))
+}
+
+// ResolveStream turns an audio stream selection into the number used by
+// Options.Stream, an empty selection leaves the choice to FFmpeg and gives zero
+func ResolveStream(filePath, spec string) (int, error) {
+	if strings.TrimSpace(spec) == "" {
+		return 0, nil
+	}
+	if strings.EqualFold(strings.TrimSpace(spec), StreamsAll) {
+		return 0, fmt.Errorf("invalid audio stream %q: all streams have to be transcribed one by one", spec)
+	}
+
+	tracks, err := ProbeTracks(filePath)
+	if err != nil {
+		return 0, err
+	}
+	track, err := SelectTrack(tracks, spec)
+	if err != nil {
+		return 0, err
+	}
+	return track.Number, nil
+}
+
+// iso639 maps two letter language codes to their three letter forms, both the
+// bibliographic and the terminology code where they differ
+var iso639 = map[string][]string{
+	"ar": {"ara"}, "cs": {"cze", "ces"}, "da": {"dan"}, "de": {"ger", "deu"},
+	"el": {"gre", "ell"}, "en": {"eng"}, "es": {"spa"}, "fa": {"per", "fas"},
+	"fi": {"fin"}, "fr": {"fre", "fra"}, "he": {"heb"}, "hi": {"hin"},
+	"hu": {"hun"}, "it": {"ita"}, "ja": {"jpn"}, "ko": {"kor"},
+	"nl": {"dut", "nld"}, "no": {"nor"}, "pl": {"pol"}, "pt": {"por"},
+	"ro": {"rum", "ron"}, "ru": {"rus"}, "sk": {"slo", "slk"}, "sv": {"swe"},
+	"tr": {"tur"}, "uk": {"ukr"}, "zh": {"chi", "zho"},
+}
+
+// sameLanguage reports whether two language tags name the same language
+func sameLanguage(a, b string) bool {
+	a, b = canonicalLanguage(a), canonicalLanguage(b)
+	return a != "" && a == b
+}
+
+// canonicalLanguage reduces a language tag to its two letter code when known
+func canonicalLanguage(tag string) string {
+	tag = baseLanguage(tag)
+	for short, codes := range iso639 {
+		for _, code := range codes {
+			if code == tag {
+				return short
+			}
+		}
+	}
+	return tag
+}
+
+// baseLanguage lowercases a language tag and drops its region or script
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "und" {
+		// ISO 639 for undetermined
+		return ""
+	}
+	return tag
+}
@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTrack(t *testing.T) {
+	tracks := []AudioTrack{
+		{Number: 1, Codec: "aac", Language: "eng", Channels: 2},
+		{Number: 2, Codec: "aac", Language: "ger", Channels: 2},
+		{Number: 3, Codec: "ac3", Language: "pt-BR", Channels: 6},
+	}
+
+	tests := []struct {
+		spec   string
+		number int
+	}{
+		{"2", 2},
+		{"eng", 1},
+		{"en", 1},
+		{"de", 2},
+		{"deu", 2},
+		{"PT", 3},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			track, err := SelectTrack(tracks, test.spec)
+			require.NoError(t, err)
+			assert.Equal(t, test.number, track.Number)
+		})
+	}
+
+	_, err := SelectTrack(tracks, "4")
+	assert.ErrorContains(t, err, "the file has 3 audio streams")
+	_, err = SelectTrack(tracks, "pl")
+	assert.ErrorContains(t, err, "available: eng, ger, pt-BR")
+	_, err = SelectTrack([]AudioTrack{{Number: 1}}, "en")
+	assert.ErrorContains(t, err, "select one by number")
+}
+
+func TestResolveStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	require.NoError(t, os.WriteFile(path, testStereoWAV(0.1, -0.5, 100), 0644))
+
+	stream, err := ResolveStream(path, "")
+	require.NoError(t, err)
+	assert.Zero(t, stream)
+
+	stream, err = ResolveStream(path, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stream)
+
+	_, err = ResolveStream(path, "2")
+	assert.ErrorContains(t, err, "invalid audio stream 2")
+	_, err = ResolveStream(path, StreamsAll)
+	assert.ErrorContains(t, err, "one by one")
+
+	tracks, err := ProbeTracks(path)
+	require.NoError(t, err)
+	require.Len(t, tracks, 1)
+	assert.Equal(t, 2, tracks[0].Channels)
+	assert.Equal(t, SampleRate, tracks[0].SampleRate)
+}
@@ -0,0 +1,270 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// noiseFloorPercentile is the share of frames assumed to contain no speech
+// when estimating the noise floor
+const noiseFloorPercentile = 0.1
+
+// VADOptions tunes voice activity detection
+type VADOptions struct {
+	// FrameDuration is the length of the analysed frames
+	FrameDuration time.Duration
+	// EnergyMargin is how many dB above the estimated noise floor a frame must
+	// be to count as speech
+	EnergyMargin float64
+	// MinEnergy is the lowest frame energy in dBFS ever counted as speech
+	MinEnergy float64
+	// ZeroCrossingRate is the rate above which quieter frames, down to half the
+	// energy margin, still count as speech, catching unvoiced consonants
+	ZeroCrossingRate float64
+	// MinSpeech drops speech regions shorter than this
+	MinSpeech time.Duration
+	// MinSilence merges speech regions separated by shorter pauses
+	MinSilence time.Duration
+	// Padding extends every region on both sides so word edges are kept
+	Padding time.Duration
+}
+
+// DefaultVADOptions returns settings suitable for speech recordings
+func DefaultVADOptions() VADOptions {
+	return VADOptions{
+		FrameDuration:    30 * time.Millisecond,
+		EnergyMargin:     10,
+		MinEnergy:        -55,
+		ZeroCrossingRate: 0.25,
+		MinSpeech:        250 * time.Millisecond,
+		MinSilence:       500 * time.Millisecond,
+		Padding:          200 * time.Millisecond,
+	}
+}
+
+// CheckVAD validates the tunable voice activity detection settings
+func CheckVAD(opts VADOptions) error {
+	switch {
+	case opts.EnergyMargin < 0:
+		return fmt.Errorf("invalid VAD margin %g: use 0 dB or more, e.g. 10", opts.EnergyMargin)
+	case opts.MinEnergy > 0:
+		return fmt.Errorf("invalid VAD minimum energy %g: use 0 dBFS or less, e.g. -55", opts.MinEnergy)
+	case opts.Padding < 0:
+		return fmt.Errorf("invalid VAD padding %s: use 0 or more", opts.Padding)
+	case opts.MinSilence < 0:
+		return fmt.Errorf("invalid VAD minimum silence %s: use 0 or more", opts.MinSilence)
+	}
+	return nil
+}
+
+// SpeechRegion is a span of samples containing speech, End is exclusive
+type SpeechRegion struct {
+	Start int
+	End   int
+}
+
+// DetectSpeech finds the regions of the samples which contain speech, using
+// the frame energy relative to the noise floor and the zero-crossing rate
+func DetectSpeech(samples []float32, opts VADOptions) []SpeechRegion {
+	frameSize := durationToSamples(opts.FrameDuration)
+	if frameSize <= 0 || len(samples) == 0 {
+		return nil
+	}
+
+	frames := (len(samples) + frameSize - 1) / frameSize
+	energies := make([]float64, frames)
+	rates := make([]float64, frames)
+	for i := range energies {
+		end := (i + 1) * frameSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		energies[i], rates[i] = analyseFrame(samples[i*frameSize : end])
+	}
+
+	// Collect runs of speech frames
+	var regions []SpeechRegion
+	for i, speech := range speechFrames(energies, rates, opts) {
+		if !speech {
+			continue
+		}
+
+		start, end := i*frameSize, (i+1)*frameSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		if n := len(regions); n > 0 && regions[n-1].End == start {
+			regions[n-1].End = end
+		} else {
+			regions = append(regions, SpeechRegion{Start: start, End: end})
+		}
+	}
+
+	regions = mergeRegions(regions, durationToSamples(opts.MinSilence))
+
+	// Drop short noises, then pad what is left
+	minSpeech := durationToSamples(opts.MinSpeech)
+	padding := durationToSamples(opts.Padding)
+	var result []SpeechRegion
+	for _, region := range regions {
+		if region.End-region.Start < minSpeech {
+			continue
+		}
+		region.Start -= padding
+		if region.Start < 0 {
+			region.Start = 0
+		}
+		region.End += padding
+		if region.End > len(samples) {
+			region.End = len(samples)
+		}
+		result = append(result, region)
+	}
+
+	return mergeRegions(result, 0)
+}
+
+// speechFrames classifies frames by their energy in dBFS and zero-crossing
+// rate, reporting which contain speech
+func speechFrames(energies, rates []float64, opts VADOptions) []bool {
+	threshold := speechThreshold(energies, opts)
+	speech := make([]bool, len(energies))
+	for i := range energies {
+		speech[i] = isSpeech(energies[i], rates[i], threshold, opts)
+	}
+	return speech
+}
+
+// speechThreshold returns the energy in dBFS above which frames count as
+// speech, relative to the noise floor of the frame energies
+func speechThreshold(energies []float64, opts VADOptions) float64 {
+	threshold := noiseFloor(energies) + opts.EnergyMargin
+	if threshold < opts.MinEnergy {
+		threshold = opts.MinEnergy
+	}
+	return threshold
+}
+
+// isSpeech classifies a frame by its energy and zero-crossing rate
+func isSpeech(energy, rate, threshold float64, opts VADOptions) bool {
+	return energy >= threshold ||
+		(energy >= threshold-opts.EnergyMargin/2 && energy >= opts.MinEnergy && rate >= opts.ZeroCrossingRate)
+}
+
+// analyseFrame returns the energy in dBFS and the zero-crossing rate of a frame
+func analyseFrame(frame []float32) (float64, float64) {
+	var sum float64
+	crossings := 0
+	for i, s := range frame {
+		sum += float64(s) * float64(s)
+		if i > 0 && (s >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+
+	energy := 10 * math.Log10(sum/float64(len(frame))+1e-10)
+	rate := 0.0
+	if len(frame) > 1 {
+		rate = float64(crossings) / float64(len(frame)-1)
+	}
+	return energy, rate
+}
+
+// noiseFloor estimates the background level as a low percentile of the frame energies
+func noiseFloor(energies []float64) float64 {
+	return percentile(energies, noiseFloorPercentile)
+}
+
+// percentile returns the value below which the share p of the values lies
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// mergeRegions joins sorted regions separated by at most gap samples
+func mergeRegions(regions []SpeechRegion, gap int) []SpeechRegion {
+	var merged []SpeechRegion
+	for _, region := range regions {
+		if n := len(merged); n > 0 && region.Start-merged[n-1].End <= gap {
+			if region.End > merged[n-1].End {
+				merged[n-1].End = region.End
+			}
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+// SpeechTimeline maps positions in audio packed from speech regions back to
+// the original audio
+type SpeechTimeline struct {
+	regions []SpeechRegion
+	// offsets holds where every region starts in the packed audio
+	offsets []int
+}
+
+// PackSpeech concatenates the speech regions of the samples, the timeline
+// translates timestamps of the packed audio to the original
+func PackSpeech(samples []float32, regions []SpeechRegion) ([]float32, *SpeechTimeline) {
+	timeline := &SpeechTimeline{regions: regions}
+	var packed []float32
+	for _, region := range regions {
+		timeline.offsets = append(timeline.offsets, len(packed))
+		packed = append(packed, samples[region.Start:region.End]...)
+	}
+	return packed, timeline
+}
+
+// Start maps the start of something at the given time in the packed audio to
+// the original audio. A start at a region boundary belongs to the later region.
+func (t *SpeechTimeline) Start(packed time.Duration) time.Duration {
+	return t.original(durationToSamples(packed), false)
+}
+
+// End maps the end of something at the given time in the packed audio to the
+// original audio. An end at a region boundary belongs to the earlier region.
+func (t *SpeechTimeline) End(packed time.Duration) time.Duration {
+	return t.original(durationToSamples(packed), true)
+}
+
+// original finds the region containing the packed position and translates it
+func (t *SpeechTimeline) original(pos int, end bool) time.Duration {
+	if len(t.regions) == 0 {
+		return 0
+	}
+
+	// Index of the first region starting after the position
+	i := sort.Search(len(t.offsets), func(i int) bool {
+		if end {
+			return t.offsets[i] >= pos
+		}
+		return t.offsets[i] > pos
+	})
+	if i > 0 {
+		i--
+	}
+
+	region := t.regions[i]
+	sample := region.Start + pos - t.offsets[i]
+	if sample > region.End {
+		sample = region.End
+	}
+	if sample < region.Start {
+		sample = region.Start
+	}
+	return samplesToDuration(sample)
+}
+
+// durationToSamples converts a duration to a number of samples
+func durationToSamples(d time.Duration) int {
+	return int(d * SampleRate / time.Second)
+}
+
+// samplesToDuration converts a number of samples to a duration
+func samplesToDuration(n int) time.Duration {
+	return time.Duration(n) * time.Second / SampleRate
+}
@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSpeech(t *testing.T) {
+	t.Run("tone bursts in noise", func(t *testing.T) {
+		// 1s noise, 1s tone, 2s noise, 0.5s tone, 1s noise
+		samples := testNoise(5500 * time.Millisecond)
+		addTone(samples, time.Second, 2*time.Second, 0.3, 300)
+		addTone(samples, 4*time.Second, 4500*time.Millisecond, 0.3, 300)
+
+		regions := DetectSpeech(samples, DefaultVADOptions())
+		require.Len(t, regions, 2)
+		assertRegion(t, regions[0], 800*time.Millisecond, 2200*time.Millisecond)
+		assertRegion(t, regions[1], 3800*time.Millisecond, 4700*time.Millisecond)
+	})
+
+	t.Run("short pauses are merged and clicks dropped", func(t *testing.T) {
+		samples := testNoise(4 * time.Second)
+		addTone(samples, 500*time.Millisecond, 1500*time.Millisecond, 0.3, 300)
+		addTone(samples, 1800*time.Millisecond, 2500*time.Millisecond, 0.3, 300)
+		addTone(samples, 3500*time.Millisecond, 3560*time.Millisecond, 0.5, 300)
+
+		regions := DetectSpeech(samples, DefaultVADOptions())
+		require.Len(t, regions, 1)
+		assertRegion(t, regions[0], 300*time.Millisecond, 2700*time.Millisecond)
+	})
+
+	t.Run("quiet fricatives count by zero crossings", func(t *testing.T) {
+		samples := testNoise(3 * time.Second)
+		addTone(samples, time.Second, 2*time.Second, 0.3, 300)
+		// A quiet high-pitched sound just above half the margin
+		addTone(samples, 2*time.Second, 2400*time.Millisecond, 0.0035, 6000)
+
+		opts := DefaultVADOptions()
+		opts.Padding = 0
+		regions := DetectSpeech(samples, opts)
+		require.Len(t, regions, 1)
+		assertRegion(t, regions[0], time.Second, 2400*time.Millisecond)
+	})
+
+	t.Run("silence has no speech", func(t *testing.T) {
+		assert.Empty(t, DetectSpeech(make([]float32, SampleRate), DefaultVADOptions()))
+		assert.Empty(t, DetectSpeech(nil, DefaultVADOptions()))
+	})
+}
+
+func TestSpeechTimeline(t *testing.T) {
+	samples := make([]float32, 10*SampleRate)
+	regions := []SpeechRegion{
+		{Start: 1 * SampleRate, End: 3 * SampleRate},
+		{Start: 6 * SampleRate, End: 7 * SampleRate},
+	}
+
+	packed, timeline := PackSpeech(samples, regions)
+	assert.Len(t, packed, 3*SampleRate)
+
+	assert.Equal(t, time.Second, timeline.Start(0))
+	assert.Equal(t, 2500*time.Millisecond, timeline.Start(1500*time.Millisecond))
+
+	// The boundary between the regions
+	assert.Equal(t, 6*time.Second, timeline.Start(2*time.Second))
+	assert.Equal(t, 3*time.Second, timeline.End(2*time.Second))
+
+	assert.Equal(t, 6500*time.Millisecond, timeline.End(2500*time.Millisecond))
+	// Past the end of the packed audio
+	assert.Equal(t, 7*time.Second, timeline.End(4*time.Second))
+}
+
+func TestCheckVAD(t *testing.T) {
+	assert.NoError(t, CheckVAD(DefaultVADOptions()))
+
+	for name, change := range map[string]func(*VADOptions){
+		"margin":      func(o *VADOptions) { o.EnergyMargin = -1 },
+		"min energy":  func(o *VADOptions) { o.MinEnergy = 3 },
+		"padding":     func(o *VADOptions) { o.Padding = -time.Second },
+		"min silence": func(o *VADOptions) { o.MinSilence = -time.Second },
+	} {
+		opts := DefaultVADOptions()
+		change(&opts)
+		assert.ErrorContains(t, CheckVAD(opts), "invalid VAD", name)
+	}
+}
+
+func testNoise(d time.Duration) []float32 {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float32, durationToSamples(d))
+	for i := range samples {
+		samples[i] = float32(rng.NormFloat64() * 0.001)
+	}
+	return samples
+}
+
+func addTone(samples []float32, start, end time.Duration, amplitude, frequency float64) {
+	for i := durationToSamples(start); i < durationToSamples(end); i++ {
+		samples[i] += float32(amplitude * math.Sin(2*math.Pi*frequency*float64(i)/SampleRate))
+	}
+}
+
+func assertRegion(t *testing.T, region SpeechRegion, start, end time.Duration) {
+	t.Helper()
+	// Regions are found with frame precision
+	tolerance := float64(durationToSamples(30 * time.Millisecond))
+	assert.InDelta(t, durationToSamples(start), region.Start, tolerance, "start")
+	assert.InDelta(t, durationToSamples(end), region.End, tolerance, "end")
+}
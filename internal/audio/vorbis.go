@@ -0,0 +1,33 @@
+package audio
+
+import (
+	"io"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// vorbisDecoder decodes Vorbis audio in an Ogg container
+type vorbisDecoder struct {
+	reader *oggvorbis.Reader
+}
+
+// newVorbisDecoder reads the Vorbis headers
+func newVorbisDecoder(input io.Reader) (*vorbisDecoder, error) {
+	reader, err := oggvorbis.NewReader(input)
+	if err != nil {
+		return nil, errorf(ErrCorrupt, "invalid Ogg Vorbis file: %w", err)
+	}
+	return &vorbisDecoder{reader: reader}, nil
+}
+
+func (d *vorbisDecoder) sampleRate() int { return d.reader.SampleRate() }
+
+func (d *vorbisDecoder) channels() int { return d.reader.Channels() }
+
+func (d *vorbisDecoder) read(p []float32) (int, error) {
+	n, err := d.reader.Read(p)
+	if err != nil && err != io.EOF {
+		return n, errorf(ErrCorrupt, "failed to decode Ogg Vorbis: %w", err)
+	}
+	return n, err
+}
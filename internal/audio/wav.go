@@ -0,0 +1,237 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAV format tags
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavHeaderPeek is how much of the input is inspected to find the fmt chunk
+const wavHeaderPeek = 4096
+
+// maxFmtChunkSize guards against corrupt headers, real fmt chunks are at most 40 bytes
+const maxFmtChunkSize = 1024
+
+// wavFormat describes the sample layout of a WAV file
+type wavFormat struct {
+	tag           uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+}
+
+// isWhisperFormat reports whether the audio needs neither downmixing nor resampling
+func (f wavFormat) isWhisperFormat() bool {
+	return f.channels == 1 && f.sampleRate == SampleRate
+}
+
+// bytesPerFrame is the size of one sample for every channel
+func (f wavFormat) bytesPerFrame() int {
+	return f.channels * f.bitsPerSample / 8
+}
+
+// isWAV reports whether the header starts a RIFF WAVE file
+func isWAV(header []byte) bool {
+	return len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE"
+}
+
+// peekWAVFormat parses the fmt chunk of a WAV file without consuming the input
+func peekWAVFormat(r *bufio.Reader) (wavFormat, bool) {
+	header, _ := r.Peek(wavHeaderPeek)
+	if !isWAV(header) {
+		return wavFormat{}, false
+	}
+
+	// The data chunk may lie beyond the peeked bytes, only the format matters here
+	format, _, _ := readWAVHeader(bytes.NewReader(header))
+	return format, format.channels > 0
+}
+
+// readWAVHeader consumes the RIFF header and every chunk up to the data chunk.
+// It returns the format and the size of the data, or -1 when the size is
+// unknown because the file was written as a stream. The format is returned
+// even when the data chunk is missing, as long as the fmt chunk was read.
+func readWAVHeader(r io.Reader) (wavFormat, int64, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil || !isWAV(header) {
+		return wavFormat{}, 0, errorf(ErrUnsupportedFormat, "unsupported audio format: not a WAV file")
+	}
+
+	var format wavFormat
+	var haveFormat bool
+	for {
+		id, size, err := readChunkHeader(r)
+		if err != nil {
+			return format, 0, errorf(ErrCorrupt, "invalid WAV file: missing data chunk")
+		}
+
+		switch id {
+		case "fmt ":
+			if size > maxFmtChunkSize {
+				return wavFormat{}, 0, errorf(ErrCorrupt, "invalid WAV file: fmt chunk of %d bytes", size)
+			}
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return wavFormat{}, 0, errorf(ErrCorrupt, "invalid WAV file: truncated fmt chunk")
+			}
+			if format, err = parseFmtChunk(chunk[:size]); err != nil {
+				return wavFormat{}, 0, err
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return wavFormat{}, 0, errorf(ErrCorrupt, "invalid WAV file: data chunk before fmt chunk")
+			}
+			// Streaming writers leave the size empty or at its maximum
+			if size == 0 || size == math.MaxUint32 {
+				return format, -1, nil
+			}
+			return format, int64(size), nil
+		default:
+			// Skip chunks like LIST, chunks are padded to an even size
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return format, 0, errorf(ErrCorrupt, "invalid WAV file: truncated %q chunk", id)
+			}
+		}
+	}
+}
+
+// readChunkHeader reads a chunk id and size
+func readChunkHeader(r io.Reader) (string, uint32, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", 0, err
+	}
+	return string(header[0:4]), binary.LittleEndian.Uint32(header[4:8]), nil
+}
+
+// parseFmtChunk validates the fmt chunk and returns the format it describes
+func parseFmtChunk(chunk []byte) (wavFormat, error) {
+	if len(chunk) < 16 {
+		return wavFormat{}, errorf(ErrCorrupt, "invalid WAV file: fmt chunk too short")
+	}
+
+	format := wavFormat{
+		tag:           binary.LittleEndian.Uint16(chunk[0:2]),
+		channels:      int(binary.LittleEndian.Uint16(chunk[2:4])),
+		sampleRate:    int(binary.LittleEndian.Uint32(chunk[4:8])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+
+	// The real format of extensible files is the start of the sub-format GUID
+	if format.tag == wavFormatExtensible {
+		if len(chunk) < 26 {
+			return wavFormat{}, errorf(ErrCorrupt, "invalid WAV file: extensible fmt chunk too short")
+		}
+		format.tag = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+
+	if format.channels < 1 || format.sampleRate < 1 {
+		return wavFormat{}, errorf(ErrCorrupt, "invalid WAV file: %d channels at %d Hz", format.channels, format.sampleRate)
+	}
+
+	switch {
+	case format.tag == wavFormatPCM && (format.bitsPerSample == 8 || format.bitsPerSample == 16 ||
+		format.bitsPerSample == 24 || format.bitsPerSample == 32):
+	case format.tag == wavFormatFloat && (format.bitsPerSample == 32 || format.bitsPerSample == 64):
+	default:
+		return wavFormat{}, errorf(ErrDecoderMissing, "unsupported audio format: WAV format 0x%04x with %d bits per sample",
+			format.tag, format.bitsPerSample)
+	}
+
+	return format, nil
+}
+
+// decodeWAVSamples converts WAV sample data to interleaved float32 samples
+func decodeWAVSamples(raw []byte, format wavFormat, out []float32) int {
+	sampleBytes := format.bitsPerSample / 8
+	n := len(raw) / sampleBytes
+	for i := 0; i < n; i++ {
+		out[i] = float32(decodeWAVSample(raw[i*sampleBytes:(i+1)*sampleBytes], format))
+	}
+	return n
+}
+
+// decodeWAVSample converts a single sample to the range [-1, 1]
+func decodeWAVSample(b []byte, format wavFormat) float64 {
+	if format.tag == wavFormatFloat {
+		if format.bitsPerSample == 64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+
+	switch format.bitsPerSample {
+	case 8:
+		// 8-bit WAV is unsigned
+		return (float64(b[0]) - 128) / 128
+	case 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+// wavDecoder reads the data chunk of a WAV file
+type wavDecoder struct {
+	data   io.Reader
+	format wavFormat
+	buf    []byte
+	// dataSize is the size of the data chunk, -1 when unknown
+	dataSize int64
+}
+
+// newWAVDecoder consumes the WAV header and prepares to read the samples
+func newWAVDecoder(input io.Reader) (*wavDecoder, error) {
+	format, dataSize, err := readWAVHeader(input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := input
+	if dataSize >= 0 {
+		data = io.LimitReader(input, dataSize)
+	}
+	return &wavDecoder{data: data, format: format, dataSize: dataSize}, nil
+}
+
+func (d *wavDecoder) sampleRate() int { return d.format.sampleRate }
+
+func (d *wavDecoder) length() int64 { return max(d.dataSize, 0) / int64(d.format.bytesPerFrame()) }
+
+func (d *wavDecoder) channels() int { return d.format.channels }
+
+func (d *wavDecoder) read(p []float32) (int, error) {
+	frameBytes := d.format.bytesPerFrame()
+	size := len(p) / d.format.channels * frameBytes
+	if cap(d.buf) < size {
+		d.buf = make([]byte, size)
+	}
+
+	n, err := io.ReadFull(d.data, d.buf[:size])
+	// A trailing partial sample frame is dropped
+	n -= n % frameBytes
+	samples := decodeWAVSamples(d.buf[:n], d.format, p)
+
+	switch err {
+	case nil:
+		return samples, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		return samples, io.EOF
+	default:
+		return samples, fmt.Errorf("failed to read audio data: %w", err)
+	}
+}
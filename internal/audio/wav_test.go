@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAVDecoder(t *testing.T) {
+	t.Run("16-bit stereo is downmixed", func(t *testing.T) {
+		data := make([]byte, 0, 8)
+		for _, v := range []int16{16384, 0, -16384, -16384} {
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		}
+
+		samples := decodeTestWAV(t, buildTestWAV(wavFormatPCM, 2, SampleRate, 16, data), Options{})
+		assert.InDeltaSlice(t, []float32{0.25, -0.5}, samples, 1e-6)
+	})
+
+	t.Run("8, 24 and 32-bit PCM", func(t *testing.T) {
+		samples := decodeTestWAV(t, buildTestWAV(wavFormatPCM, 1, SampleRate, 8, []byte{0, 128, 192}), Options{})
+		assert.InDeltaSlice(t, []float32{-1, 0, 0.5}, samples, 1e-6)
+
+		samples = decodeTestWAV(t, buildTestWAV(wavFormatPCM, 1, SampleRate, 24, []byte{0, 0, 0x40, 0, 0, 0xC0}), Options{})
+		assert.InDeltaSlice(t, []float32{0.5, -0.5}, samples, 1e-6)
+
+		data := binary.LittleEndian.AppendUint32(nil, uint32(1<<30))
+		samples = decodeTestWAV(t, buildTestWAV(wavFormatPCM, 1, SampleRate, 32, data), Options{})
+		assert.InDeltaSlice(t, []float32{0.5}, samples, 1e-6)
+	})
+
+	t.Run("32 and 64-bit float", func(t *testing.T) {
+		data := binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.75))
+		samples := decodeTestWAV(t, buildTestWAV(wavFormatFloat, 1, SampleRate, 32, data), Options{})
+		assert.InDeltaSlice(t, []float32{-0.75}, samples, 1e-6)
+
+		data = binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.125))
+		samples = decodeTestWAV(t, buildTestWAV(wavFormatFloat, 1, SampleRate, 64, data), Options{})
+		assert.InDeltaSlice(t, []float32{0.125}, samples, 1e-6)
+	})
+
+	t.Run("48kHz is resampled", func(t *testing.T) {
+		// One second of a 440Hz tone
+		data := make([]byte, 0, 48000*2)
+		for i := 0; i < 48000; i++ {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/48000)
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(v*32767)))
+		}
+
+		samples := decodeTestWAV(t, buildTestWAV(wavFormatPCM, 1, 48000, 16, data), Options{})
+		require.Len(t, samples, SampleRate)
+
+		// The tone keeps its frequency and amplitude away from the edges
+		for i := 1000; i < 15000; i += 997 {
+			expected := 0.5 * math.Sin(2*math.Pi*440*float64(i)/SampleRate)
+			assert.InDelta(t, expected, samples[i], 0.01, "sample %d", i)
+		}
+	})
+
+	t.Run("max duration", func(t *testing.T) {
+		data := make([]byte, SampleRate*2*2)
+		samples := decodeTestWAV(t, buildTestWAV(wavFormatPCM, 1, SampleRate, 16, data), Options{MaxDuration: 500 * time.Millisecond})
+		assert.Len(t, samples, SampleRate/2)
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		// IMA ADPCM needs a real codec
+		wav := buildTestWAV(0x0011, 1, SampleRate, 4, make([]byte, 16))
+		_, err := newNativeStream(bufio.NewReader(bytes.NewReader(wav)), 0, Options{})
+		assert.ErrorIs(t, err, ErrDecoderMissing)
+		assert.ErrorContains(t, err, "WAV format 0x0011 with 4 bits per sample")
+	})
+}
+
+func TestParseFmtChunk(t *testing.T) {
+	// The fmt chunk of the test files starts after the LIST chunk
+	fmtChunk := func(tag uint16, channels, sampleRate, bits int) []byte {
+		return buildTestWAV(tag, channels, sampleRate, bits, nil)[32:48]
+	}
+
+	format, err := parseFmtChunk(fmtChunk(wavFormatPCM, 2, 44100, 24))
+	require.NoError(t, err)
+	assert.Equal(t, wavFormat{tag: wavFormatPCM, channels: 2, sampleRate: 44100, bitsPerSample: 24}, format)
+
+	_, err = parseFmtChunk(fmtChunk(wavFormatPCM, 1, SampleRate, 12))
+	assert.ErrorIs(t, err, ErrDecoderMissing)
+	_, err = parseFmtChunk(fmtChunk(wavFormatFloat, 1, SampleRate, 16))
+	assert.ErrorIs(t, err, ErrDecoderMissing)
+	_, err = parseFmtChunk(fmtChunk(wavFormatPCM, 0, SampleRate, 16))
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, err = parseFmtChunk(fmtChunk(wavFormatPCM, 1, SampleRate, 16)[:12])
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	// Extensible files carry the format in the sub-format GUID
+	extensible := append(fmtChunk(wavFormatExtensible, 1, SampleRate, 32), make([]byte, 10)...)
+	extensible[24] = byte(wavFormatFloat)
+	format, err = parseFmtChunk(extensible)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(wavFormatFloat), format.tag)
+}
+
+func TestResampler_Blocks(t *testing.T) {
+	input := make([]float32, 4410)
+	for i := range input {
+		input[i] = float32(math.Sin(float64(i) / 10))
+	}
+
+	// Feeding the input in odd sized blocks gives the same result as one block
+	whole := newResampler(44100, SampleRate)
+	expected := append(whole.process(input), whole.flush()...)
+
+	blocks := newResampler(44100, SampleRate)
+	var actual []float32
+	for start := 0; start < len(input); start += 333 {
+		end := start + 333
+		if end > len(input) {
+			end = len(input)
+		}
+		actual = append(actual, blocks.process(input[start:end])...)
+	}
+	actual = append(actual, blocks.flush()...)
+
+	assert.Len(t, expected, 1600)
+	assert.InDeltaSlice(t, expected, actual, 1e-6)
+}
+
+func decodeTestWAV(t *testing.T, wav []byte, opts Options) []float32 {
+	// The samples are compared with the input, so nothing is normalized
+	opts.Filter = FilterNone
+	stream, err := newNativeStream(bufio.NewReader(bytes.NewReader(wav)), 4, opts)
+	require.NoError(t, err)
+
+	samples, err := stream.ReadAll()
+	require.NoError(t, err)
+	return samples
+}
+
+func buildTestWAV(tag uint16, channels, sampleRate, bits int, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+8+4+len(data)))
+	buf.WriteString("WAVE")
+
+	// A chunk before fmt which has to be skipped
+	buf.WriteString("LIST")
+	binary.Write(&buf, binary.LittleEndian, uint32(4))
+	buf.WriteString("INFO")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, tag)
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*bits/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bits/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bits))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
@@ -0,0 +1,217 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Device is an audio input device of a backend
+type Device struct {
+	// Name selects the device with Capture.Device
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Default marks the device recorded when none is selected, where the
+	// backend tells
+	Default bool `json:"default,omitempty"`
+}
+
+// ListDevices enumerates the capture devices of a backend, BackendAuto uses
+// the first installed backend of DetectOrder which can list them. It returns
+// the backend whose device names were listed.
+func ListDevices(backend string) (string, []Device, error) {
+	switch backend {
+	case "", BackendAuto:
+		detected, ok := detectBackend(true)
+		if !ok {
+			return "", nil, fmt.Errorf("no audio recording tool found which can list devices: install parec (PulseAudio), arecord (ALSA) or ffmpeg")
+		}
+		backend = detected
+	case BackendSox:
+		return "", nil, fmt.Errorf("sox cannot list devices: list them with --recorder-backend parec or arecord, sox takes the names of its audio driver")
+	case BackendArecord, BackendParec, BackendFFmpeg:
+	default:
+		if strings.HasPrefix(backend, BackendFile+":") {
+			return "", nil, fmt.Errorf("the file backend has no devices")
+		}
+		return "", nil, fmt.Errorf("invalid recorder backend %q: use %s, %s or file:<path>", backend, BackendAuto, strings.Join(DetectOrder, ", "))
+	}
+
+	var devices []Device
+	var err error
+	switch backend {
+	case BackendArecord:
+		var output string
+		if output, err = runListing("arecord", "-L"); err == nil {
+			devices = parseArecordDevices(output)
+		}
+	case BackendParec:
+		// parec itself cannot list, pactl comes with it
+		var output string
+		if output, err = runListing("pactl", "list", "short", "sources"); err == nil {
+			devices = parsePactlSources(output)
+		}
+	case BackendFFmpeg:
+		devices, err = listFFmpegDevices()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return backend, devices, nil
+}
+
+// runListing runs a listing command and returns its output
+func runListing(name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := execCommand(name, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return "", fmt.Errorf("failed to list devices with %s: %w: %s", name, err, output)
+		}
+		return "", fmt.Errorf("failed to list devices with %s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
+
+// listFFmpegDevices lists the devices of FFmpeg's input format of the platform
+func listFFmpegDevices() ([]Device, error) {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		// The devices are printed as log messages of a failing run
+		input, _ := ffmpegInputDevice()
+		var stderr bytes.Buffer
+		cmd := execCommand("ffmpeg", "-hide_banner", "-f", input, "-list_devices", "true", "-i", "")
+		cmd.Stderr = &stderr
+		cmd.Run()
+		if input == "avfoundation" {
+			return parseAVFoundationDevices(stderr.String()), nil
+		}
+		return parseDShowDevices(stderr.String()), nil
+	default:
+		output, err := runListing("ffmpeg", "-hide_banner", "-sources", "pulse")
+		if err != nil {
+			return nil, err
+		}
+		return parseFFmpegSources(output), nil
+	}
+}
+
+// parseArecordDevices reads the output of arecord -L: device names followed
+// by indented description lines
+func parseArecordDevices(output string) []Device {
+	var devices []Device
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			devices = append(devices, Device{Name: line, Default: line == "default"})
+			continue
+		}
+		if n := len(devices); n > 0 {
+			description := strings.TrimSpace(line)
+			if devices[n-1].Description != "" {
+				description = devices[n-1].Description + ", " + description
+			}
+			devices[n-1].Description = description
+		}
+	}
+	return devices
+}
+
+// parsePactlSources reads the output of pactl list short sources: tab
+// separated index, name, driver, sample format and state
+func parsePactlSources(output string) []Device {
+	var devices []Device
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		device := Device{Name: fields[1]}
+		if len(fields) >= 4 {
+			device.Description = fields[3]
+		}
+		if strings.HasSuffix(device.Name, ".monitor") {
+			device.Description = strings.TrimPrefix(device.Description+", monitor of an output", ", ")
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// parseFFmpegSources reads the output of ffmpeg -sources: one device per
+// line with its description in brackets, the default marked with a star
+func parseFFmpegSources(output string) []Device {
+	var devices []Device
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		device := Device{}
+		if rest, ok := strings.CutPrefix(line, "* "); ok {
+			device.Default, line = true, rest
+		}
+		device.Name, device.Description, _ = strings.Cut(line, " [")
+		device.Description = strings.TrimSuffix(device.Description, "]")
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// avfoundationDevice matches a device of AVFoundation's device list
+var avfoundationDevice = regexp.MustCompile(`\] \[(\d+)\] (.+)$`)
+
+// parseAVFoundationDevices reads the audio devices logged by the avfoundation
+// input, which are selected by their index after a colon
+func parseAVFoundationDevices(output string) []Device {
+	var devices []Device
+	audio := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.Contains(line, "AVFoundation audio devices") {
+			audio = true
+			continue
+		}
+		if strings.Contains(line, "AVFoundation video devices") {
+			audio = false
+			continue
+		}
+		if match := avfoundationDevice.FindStringSubmatch(line); audio && match != nil {
+			devices = append(devices, Device{Name: ":" + match[1], Description: match[2]})
+		}
+	}
+	return devices
+}
+
+// dshowDevice matches a device name of DirectShow's device list
+var dshowDevice = regexp.MustCompile(`\] +"([^"]+)"(?: \((audio|video)\))?$`)
+
+// parseDShowDevices reads the audio devices logged by the dshow input, in the
+// layout of recent FFmpeg versions which tag every device and of older ones
+// which list audio devices under a heading
+func parseDShowDevices(output string) []Device {
+	var devices []Device
+	audio := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, "DirectShow audio devices"):
+			audio = true
+			continue
+		case strings.Contains(line, "DirectShow video devices"):
+			audio = false
+			continue
+		}
+		match := dshowDevice.FindStringSubmatch(line)
+		if match == nil || match[2] == "video" || match[2] == "" && !audio {
+			continue
+		}
+		devices = append(devices, Device{Name: "audio=" + match[1], Description: match[1]})
+	}
+	return devices
+}
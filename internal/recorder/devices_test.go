@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDevices(t *testing.T) {
+	t.Run("arecord", func(t *testing.T) {
+		output := "default\n" +
+			"    Playback/recording through the PulseAudio sound server\n" +
+			"hw:CARD=PCH,DEV=0\n" +
+			"    HDA Intel PCH, ALC3246 Analog\n" +
+			"    Direct hardware device without any conversions\n"
+		assert.Equal(t, []Device{
+			{Name: "default", Description: "Playback/recording through the PulseAudio sound server", Default: true},
+			{Name: "hw:CARD=PCH,DEV=0", Description: "HDA Intel PCH, ALC3246 Analog, Direct hardware device without any conversions"},
+		}, parseArecordDevices(output))
+	})
+
+	t.Run("pactl", func(t *testing.T) {
+		output := "0\talsa_output.pci.analog-stereo.monitor\tPipeWire\ts32le 2ch 48000Hz\tSUSPENDED\n" +
+			"1\talsa_input.pci.analog-stereo\tPipeWire\ts32le 2ch 48000Hz\tRUNNING\n"
+		assert.Equal(t, []Device{
+			{Name: "alsa_output.pci.analog-stereo.monitor", Description: "s32le 2ch 48000Hz, monitor of an output"},
+			{Name: "alsa_input.pci.analog-stereo", Description: "s32le 2ch 48000Hz"},
+		}, parsePactlSources(output))
+	})
+
+	t.Run("ffmpeg pulse", func(t *testing.T) {
+		output := "Auto-detected sources for pulse:\n" +
+			"  alsa_output.pci.analog-stereo.monitor [Monitor of Built-in Audio]\n" +
+			"* alsa_input.pci.analog-stereo [Built-in Audio Analog Stereo]\n"
+		assert.Equal(t, []Device{
+			{Name: "alsa_output.pci.analog-stereo.monitor", Description: "Monitor of Built-in Audio"},
+			{Name: "alsa_input.pci.analog-stereo", Description: "Built-in Audio Analog Stereo", Default: true},
+		}, parseFFmpegSources(output))
+	})
+
+	t.Run("avfoundation", func(t *testing.T) {
+		output := "[AVFoundation indev @ 0x7f8] AVFoundation video devices:\n" +
+			"[AVFoundation indev @ 0x7f8] [0] FaceTime HD Camera\n" +
+			"[AVFoundation indev @ 0x7f8] AVFoundation audio devices:\n" +
+			"[AVFoundation indev @ 0x7f8] [0] MacBook Pro Microphone\n" +
+			"[AVFoundation indev @ 0x7f8] [1] USB Audio Device\n" +
+			": Input/output error\n"
+		assert.Equal(t, []Device{
+			{Name: ":0", Description: "MacBook Pro Microphone"},
+			{Name: ":1", Description: "USB Audio Device"},
+		}, parseAVFoundationDevices(output))
+	})
+
+	t.Run("dshow", func(t *testing.T) {
+		recent := "[dshow @ 000001] \"Integrated Camera\" (video)\n" +
+			"[dshow @ 000001]   Alternative name \"@device_pnp_camera\"\n" +
+			"[dshow @ 000001] \"Microphone (Realtek Audio)\" (audio)\n" +
+			"[dshow @ 000001]   Alternative name \"@device_cm_mic\"\n"
+		older := "[dshow @ 000001] DirectShow video devices\n" +
+			"[dshow @ 000001]  \"Integrated Camera\"\n" +
+			"[dshow @ 000001] DirectShow audio devices\n" +
+			"[dshow @ 000001]  \"Microphone (Realtek Audio)\"\n" +
+			"[dshow @ 000001]     Alternative name \"@device_cm_mic\"\n"
+		expected := []Device{{Name: "audio=Microphone (Realtek Audio)", Description: "Microphone (Realtek Audio)"}}
+		assert.Equal(t, expected, parseDShowDevices(recent))
+		assert.Equal(t, expected, parseDShowDevices(older))
+	})
+}
+
+func TestListDevices(t *testing.T) {
+	fakeBackend(t, "printf 'default\\n    Default ALSA device\\n'")
+	backend, devices, err := ListDevices(BackendArecord)
+	require.NoError(t, err)
+	assert.Equal(t, BackendArecord, backend)
+	assert.Equal(t, []Device{{Name: "default", Description: "Default ALSA device", Default: true}}, devices)
+
+	_, _, err = ListDevices(BackendSox)
+	assert.ErrorContains(t, err, "sox cannot list devices")
+}
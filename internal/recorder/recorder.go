@@ -5,7 +5,9 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"syscall"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-audio/audio"
 	wavgo "github.com/go-audio/wav"
@@ -14,7 +16,7 @@ import (
 
 // For testing purposes
 var (
-	// Allow tests to override the command execution
+	// Allow tests to override the command execution of the backends
 	execCommand = exec.Command
 	// Flag to indicate if we're in test mode
 	testMode bool
@@ -34,149 +36,535 @@ func DisableTestMode() {
 	testSamples = nil
 }
 
+// readSize is the number of samples read from the source at a time, 100ms
+const readSize = audioloader.SampleRate / 10
+
+// speechWaitKeep is how much audio is kept while waiting for speech, enough
+// for the start of the speech and its padding
+const speechWaitKeep = 3 * time.Second
+
+// Reasons why a recording stopped on its own, as returned by StopReason
+const (
+	// StopSilence stops after AutoStop.Silence of trailing silence
+	StopSilence = "silence"
+	// StopMaxDuration stops after AutoStop.MaxDuration
+	StopMaxDuration = "max duration"
+	// StopSourceEnded is a source ending on its own, e.g. at the end of a file
+	StopSourceEnded = "source ended"
+)
+
+// AutoStop makes a recording stop on its own, so no one has to stop it
+type AutoStop struct {
+	// Silence stops the recording once it has been quiet this long after
+	// speech, zero never stops on silence
+	Silence time.Duration
+	// WaitForSpeech discards the audio until speech starts, the recording
+	// then starts with the speech
+	WaitForSpeech bool
+	// MaxDuration stops the recording after this much audio, including any
+	// wait for speech, zero has no limit
+	MaxDuration time.Duration
+	// VAD tunes the detection of speech and silence
+	VAD audioloader.VADOptions
+}
+
+// Validate checks the durations
+func (a AutoStop) Validate() error {
+	if a.Silence < 0 {
+		return fmt.Errorf("invalid silence duration %s: use a positive duration, e.g. 2s", a.Silence)
+	}
+	if a.MaxDuration < 0 {
+		return fmt.Errorf("invalid maximum duration %s: use a positive duration, e.g. 5m", a.MaxDuration)
+	}
+	return nil
+}
+
+// Status is the state of a recording at one moment, e.g. for a level meter
+type Status struct {
+	// Level is the level of the latest audio read from the source, also
+	// while paused
+	audioloader.Level
+	// Elapsed is how much audio is in the recording, without the pauses and
+	// the wait for speech
+	Elapsed time.Duration
+	// Silence is how long it has been quiet, since the last speech or the start
+	Silence time.Duration
+	// Heard is set once speech was heard
+	Heard  bool
+	Paused bool
+}
+
 // Recorder handles audio recording functionality
 type Recorder struct {
 	outputFile string
 	samples    []float32
 	recording  bool
 	sampleRate int
-	cmd        *exec.Cmd
-	tempFile   string
+	source     Source
+	// active is the source of the current recording
+	active Source
+	// done is closed once the source has ended and err is set
+	done chan struct{}
+	err  error
+	// frames receives the samples of the next recording as they arrive
+	frames chan []float32
+	// autoStop holds the conditions on which recordings stop on their own,
+	// stopReason why the current one did
+	autoStop   AutoStop
+	stopReason string
+	// stopped is set when StopRecording stops the source
+	stopped atomic.Bool
+	// paused drops the audio read from the source until it is cleared
+	paused atomic.Bool
+	// status is updated after every read
+	statusMu sync.Mutex
+	status   Status
+	// continuous splits the recording into takes on the running source,
+	// takes receives every take as it starts and requests ends them
+	continuous bool
+	takes      chan *Take
+	requests   chan takeRequest
+}
+
+// takeRequest asks to end a take of a continuous session, nil is the
+// current one
+type takeRequest struct {
+	take    *Take
+	discard bool
+}
+
+// Take is one of the recordings of a continuous session, see SetContinuous
+type Take struct {
+	// Frames receives the samples of the take as they are recorded when
+	// Frames was called before StartRecording, it is closed when the take
+	// ends and the receiver has to keep up as the recording waits for it
+	Frames <-chan []float32
+	// Started is when the take started, including any wait for speech
+	Started time.Time
+
+	done       chan struct{}
+	samples    []float32
+	stopReason string
+}
+
+// newTake creates a take starting now
+func newTake(frames chan []float32) *Take {
+	return &Take{Frames: frames, Started: time.Now(), done: make(chan struct{})}
+}
+
+// Done is closed when the take has ended
+func (t *Take) Done() <-chan struct{} {
+	return t.done
+}
+
+// Samples returns the audio of the take once Done is closed, it is empty
+// for a discarded take and one in which no speech was heard while waiting
+func (t *Take) Samples() []float32 {
+	return t.samples
+}
+
+// StopReason returns why the take ended on its own, one of the Stop reasons,
+// once Done is closed; it is empty when EndTake, DiscardTake or
+// StopRecording ended it
+func (t *Take) StopReason() string {
+	return t.stopReason
 }
 
 // NewRecorder creates a new audio recorder
 func NewRecorder(outputFile string) *Recorder {
 	return &Recorder{
 		outputFile: outputFile,
-		sampleRate: 16000, // Whisper expects 16kHz
+		sampleRate: audioloader.SampleRate, // Whisper expects 16kHz
 		samples:    make([]float32, 0),
 	}
 }
 
-// StartRecording starts recording audio using the system's default recording device
-// This is a simplified implementation that uses sox for recording
+// SetSource selects where the audio is recorded from, without a source the
+// first installed backend of DetectOrder is used
+func (r *Recorder) SetSource(source Source) {
+	r.source = source
+}
+
+// SetAutoStop sets the conditions on which the next recordings stop on their
+// own, VAD defaults to audio.DefaultVADOptions
+func (r *Recorder) SetAutoStop(autoStop AutoStop) error {
+	if err := autoStop.Validate(); err != nil {
+		return err
+	}
+	r.autoStop = autoStop
+	return nil
+}
+
+// SetContinuous makes the next recording a continuous session: a stop
+// condition, EndTake or DiscardTake end the current take and the next one
+// starts at once, on the source which keeps running so nothing said in
+// between is lost. The takes are received from Takes.
+func (r *Recorder) SetContinuous(continuous bool) {
+	r.continuous = continuous
+}
+
+// StartRecording starts recording audio from the source, the samples are
+// collected in the background until StopRecording is called or the source
+// ends on its own
 func (r *Recorder) StartRecording() error {
 	if r.recording {
 		return fmt.Errorf("already recording")
 	}
 
-	// If we're in test mode, just set recording flag and return
+	source := r.source
 	if testMode {
-		r.recording = true
-		return nil
+		source = &sampleSource{samples: testSamples}
 	}
-
-	// Create a temporary file for recording
-	tempFile, err := os.CreateTemp("", "recording-*.wav")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+	if source == nil {
+		var err error
+		if source, err = DetectSource(); err != nil {
+			return err
+		}
+		r.source = source
 	}
-	tempFile.Close()
-	tempFileName := tempFile.Name()
-	
-	// Check if sox is installed
-	_, err = exec.LookPath("sox")
-	if err != nil {
-		return fmt.Errorf("sox audio tool is required: %w", err)
-	}
-	
-	// Start recording to the temporary file using sox in a separate process
-	// This will continue until StopRecording is called
-	cmd := execCommand("sox", "-d", "-r", fmt.Sprintf("%d", r.sampleRate), "-c", "1", tempFileName)
-	
-	// Start the recording process
-	err = cmd.Start()
-	if err != nil {
+
+	if err := source.Start(); err != nil {
 		return fmt.Errorf("failed to start recording: %w", err)
 	}
-	
+
 	r.recording = true
-	
-	// Store the command process to stop it later
-	r.cmd = cmd
-	r.tempFile = tempFileName
-	
+	r.active = source
+	r.samples = make([]float32, 0)
+	r.stopReason = ""
+	r.stopped.Store(false)
+	r.paused.Store(false)
+	r.setStatus(Status{})
+	r.done = make(chan struct{})
+	var take *Take
+	if r.continuous {
+		take = newTake(r.frames)
+		r.takes = make(chan *Take, 1)
+		r.takes <- take
+		r.requests = make(chan takeRequest, 1)
+	}
+	go r.collect(source, r.frames, take)
+	r.frames = nil
+
 	return nil
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	source, err := os.Open(src)
-	if err != nil {
-		return err
+// Frames returns a channel receiving the samples of the next recording as
+// they are recorded, about 100ms at a time, and closed when it ends. It is
+// called before StartRecording, and the receiver has to keep up as the
+// recording waits for it.
+func (r *Recorder) Frames() <-chan []float32 {
+	if r.frames == nil {
+		r.frames = make(chan []float32, 16)
 	}
-	defer source.Close()
+	return r.frames
+}
 
-	destination, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destination.Close()
+// Takes returns the channel receiving every take of a continuous session as
+// it starts, it is closed once the source has ended
+func (r *Recorder) Takes() <-chan *Take {
+	return r.takes
+}
 
-	_, err = io.Copy(destination, source)
-	return err
+// EndTake ends the take of a continuous session, the next one starts at once
+// with the next block read from the source. A take which has already ended
+// is left as it is.
+func (r *Recorder) EndTake(take *Take) error {
+	return r.requestTake(takeRequest{take: take})
 }
 
-// StopRecording stops recording audio and returns the path to the recorded file
-func (r *Recorder) StopRecording() (string, error) {
-	if !r.recording {
-		return "", fmt.Errorf("not currently recording")
+// DiscardTake throws the take of a continuous session away, the next one
+// starts at once with the next block read from the source. A take which has
+// already ended is left as it is.
+func (r *Recorder) DiscardTake(take *Take) error {
+	return r.requestTake(takeRequest{take: take, discard: true})
+}
+
+// requestTake asks collect to end a take, a request already pending is kept
+func (r *Recorder) requestTake(request takeRequest) error {
+	if !r.recording || !r.continuous {
+		return fmt.Errorf("not recording continuously")
 	}
+	select {
+	case r.requests <- request:
+	default:
+	}
+	return nil
+}
 
-	r.recording = false
+// collect reads the source until it ends, passing the samples on to frames
+// if it is set, and stops it when a stop condition is met. In a continuous
+// session take is the current take, which a stop condition ends instead.
+func (r *Recorder) collect(source Source, frames chan []float32, take *Take) {
+	defer close(r.done)
+	defer func() {
+		if frames != nil {
+			close(frames)
+		}
+	}()
 
-	// If we're in test mode, use the test samples
-	if testMode {
-		r.samples = testSamples
-		if r.outputFile != "" {
-			// Create a dummy file for testing
-			if err := r.Save(r.outputFile); err != nil {
-				return "", err
+	vad := r.autoStop.VAD
+	if vad == (audioloader.VADOptions{}) {
+		vad = audioloader.DefaultVADOptions()
+	}
+	var detector *audioloader.SpeechDetector
+	var waiting bool
+	// recorded counts all samples read, dropped those discarded while waiting
+	var recorded, dropped int
+	begin := func() {
+		detector = audioloader.NewSpeechDetector(vad)
+		waiting = r.autoStop.WaitForSpeech
+		recorded, dropped = 0, 0
+	}
+	begin()
+	maxSamples := int(r.autoStop.MaxDuration * audioloader.SampleRate / time.Second)
+	stopping := false
+
+	// endTake hands the current take over with its audio
+	live := frames != nil
+	endTake := func(reason string, discard bool) {
+		if waiting || discard {
+			r.samples = r.samples[:0]
+		}
+		take.samples, take.stopReason = r.samples, reason
+		if frames != nil {
+			close(frames)
+			frames = nil
+		}
+		close(take.done)
+	}
+	// nextTake starts the next take on the running source
+	nextTake := func() {
+		begin()
+		r.samples = make([]float32, 0)
+		r.setStatus(Status{Paused: r.paused.Load()})
+		if live {
+			frames = make(chan []float32, 16)
+		}
+		take = newTake(frames)
+		r.takes <- take
+	}
+	// requested returns whether the current take is to be ended and discarded
+	requested := func() (end, discard bool) {
+		select {
+		case request := <-r.requests:
+			if request.take == nil || request.take == take {
+				return true, request.discard
 			}
+		default:
 		}
-		return r.outputFile, nil
+		return false, false
 	}
+	// finish ends the last take of a continuous session with the source
+	finish := func(reason string) {
+		if take == nil {
+			return
+		}
+		_, discard := requested()
+		endTake(reason, discard)
+		close(r.takes)
+	}
+
+	buf := make([]float32, readSize)
+	for {
+		size := len(buf)
+		if take != nil && maxSamples > 0 {
+			// What is read past the maximum belongs to the next take
+			size = min(size, maxSamples-recorded)
+		}
+		n, err := source.Read(buf[:size])
+		if take != nil && err == nil {
+			if end, discard := requested(); end {
+				// What was read since belongs to the next take
+				endTake("", discard)
+				nextTake()
+			}
+		}
+		level := audioloader.MeasureLevel(buf[:n])
+		paused := r.paused.Load()
+		if stopping || paused {
+			// What arrives after the stop or while paused is dropped
+			n = 0
+		} else if maxSamples > 0 {
+			n = min(n, maxSamples-recorded)
+		}
+		block := buf[:n]
+		r.samples = append(r.samples, block...)
+		recorded += n
+		detector.Process(block)
+
+		if waiting {
+			if detector.Heard() {
+				// The recording starts with the speech
+				waiting = false
+				start := min(max(detector.SpeechStart()-dropped, 0), len(r.samples))
+				r.samples = append([]float32(nil), r.samples[start:]...)
+				dropped += start
+				block = r.samples
+			} else {
+				if keep := int(speechWaitKeep * audioloader.SampleRate / time.Second); len(r.samples) > keep {
+					dropped += len(r.samples) - keep
+					r.samples = append(r.samples[:0], r.samples[len(r.samples)-keep:]...)
+				}
+				block = nil
+			}
+		}
+		if n > 0 || paused {
+			// MaxDuration counts the wait, the meter only what is kept
+			kept := 0
+			if !waiting {
+				kept = recorded - dropped
+			}
+			r.setStatus(Status{
+				Level:   level,
+				Elapsed: time.Duration(kept) * time.Second / audioloader.SampleRate,
+				Silence: detector.Silence(),
+				Heard:   detector.Heard(),
+				Paused:  paused,
+			})
+		}
+		if frames != nil && len(block) > 0 {
+			frames <- append([]float32(nil), block...)
+		}
 
-	// Stop the recording process
-	if r.cmd != nil && r.cmd.Process != nil {
-		// Send SIGTERM to the sox process
-		r.cmd.Process.Signal(syscall.SIGTERM)
-		
-		// Wait for the process to exit
-		r.cmd.Wait()
+		if err == io.EOF {
+			if r.stopReason == "" && !r.stopped.Load() {
+				r.stopReason = StopSourceEnded
+			}
+			finish(r.stopReason)
+			if waiting {
+				// No speech, nothing was recorded
+				r.samples = r.samples[:0]
+			}
+			return
+		}
+		if err != nil {
+			r.err = err
+			finish("")
+			return
+		}
+
+		if stopping {
+			continue
+		}
+		if reason := r.autoStopReason(detector, recorded, maxSamples); reason != "" {
+			if take != nil {
+				// The source keeps running for the next take
+				endTake(reason, false)
+				nextTake()
+				continue
+			}
+			// The source is still read to its end, so the tool exits
+			stopping = true
+			r.stopReason = reason
+			source.Stop()
+		}
 	}
+}
 
-	// Check if the temporary file exists
-	if _, err := os.Stat(r.tempFile); os.IsNotExist(err) {
-		return "", fmt.Errorf("recording file not found, recording may have failed")
+// autoStopReason returns why the recording should stop on its own, if it should
+func (r *Recorder) autoStopReason(detector *audioloader.SpeechDetector, recorded, maxSamples int) string {
+	if maxSamples > 0 && recorded >= maxSamples {
+		return StopMaxDuration
+	}
+	if r.autoStop.Silence > 0 && detector.Heard() && detector.Silence() >= r.autoStop.Silence {
+		return StopSilence
 	}
+	return ""
+}
 
-	// Load the recorded audio file
-	samples, err := audioloader.LoadAudioFile(r.tempFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to load recorded audio: %w", err)
+// Done is closed when the source has ended, after StopRecording or on its
+// own, e.g. at the end of a file
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Pause drops the audio recorded until Resume, the source keeps running so
+// the Status still shows the level
+func (r *Recorder) Pause() {
+	r.paused.Store(true)
+}
+
+// Resume continues recording after Pause
+func (r *Recorder) Resume() {
+	r.paused.Store(false)
+}
+
+// Paused reports whether the recording is paused
+func (r *Recorder) Paused() bool {
+	return r.paused.Load()
+}
+
+// Status returns the state of the current recording, it is safe to call
+// while recording
+func (r *Recorder) Status() Status {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.status
+}
+
+// setStatus updates the status of the recording
+func (r *Recorder) setStatus(status Status) {
+	r.statusMu.Lock()
+	r.status = status
+	r.statusMu.Unlock()
+}
+
+// StopReason returns why the recording stopped on its own, one of the Stop
+// reasons, once Done is closed; it is empty when StopRecording stopped it
+func (r *Recorder) StopReason() string {
+	return r.stopReason
+}
+
+// StopRecording stops recording audio and returns the path to the recorded file
+func (r *Recorder) StopRecording() (string, error) {
+	if !r.recording {
+		return "", fmt.Errorf("not currently recording")
+	}
+
+	if err := r.stop(); err != nil {
+		return "", err
 	}
-	
-	// Store the samples
-	r.samples = samples
 
 	// Save to the output file if specified
 	if r.outputFile != "" {
-		err = copyFile(r.tempFile, r.outputFile)
-		if err != nil {
+		if err := r.Save(r.outputFile); err != nil {
 			return "", fmt.Errorf("failed to save recording to output file: %w", err)
 		}
-		// Clean up the temporary file after successful copy
-		os.Remove(r.tempFile)
-	} else {
-		// Clean up the temporary file if we're not keeping it
-		os.Remove(r.tempFile)
 	}
 
 	return r.outputFile, nil
 }
 
-// GetAudioData returns the recorded audio data
+// DiscardRecording stops recording audio and throws it away, nothing is saved
+func (r *Recorder) DiscardRecording() error {
+	if !r.recording {
+		return fmt.Errorf("not currently recording")
+	}
+	if r.continuous {
+		// The last take is thrown away as well
+		r.requestTake(takeRequest{discard: true})
+	}
+	err := r.stop()
+	r.samples = make([]float32, 0)
+	return err
+}
+
+// stop stops the source and waits for the rest of the recording
+func (r *Recorder) stop() error {
+	r.recording = false
+	r.stopped.Store(true)
+
+	if err := r.active.Stop(); err != nil {
+		return fmt.Errorf("failed to stop recording: %w", err)
+	}
+	<-r.done
+	if r.err != nil {
+		return fmt.Errorf("recording failed: %w", r.err)
+	}
+	return nil
+}
+
+// GetAudioData returns the recorded audio data, once StopRecording has returned
 func (r *Recorder) GetAudioData() []float32 {
 	return r.samples
 }
@@ -196,26 +584,26 @@ func (r *Recorder) Save(path string) error {
 
 	// Create a new encoder
 	enc := wavgo.NewEncoder(f, r.sampleRate, 16, 1, 1) // 1 is for PCM format
-	
+
 	// Convert float32 samples to int
 	intSamples := make([]int, len(r.samples))
 	for i, sample := range r.samples {
 		// Convert normalized float32 [-1.0,1.0] to int16 range
 		intSamples[i] = int(sample * 32767)
 	}
-	
+
 	// Create audio.IntBuffer
 	buf := &audio.IntBuffer{
 		Data:           intSamples,
 		Format:         &audio.Format{SampleRate: r.sampleRate, NumChannels: 1},
 		SourceBitDepth: 16,
 	}
-	
+
 	// Write the buffer to the file
 	if err := enc.Write(buf); err != nil {
 		return fmt.Errorf("failed to write audio data: %w", err)
 	}
-	
+
 	// Close the encoder
 	if err := enc.Close(); err != nil {
 		return fmt.Errorf("failed to close encoder: %w", err)
@@ -1,8 +1,13 @@
 package recorder
 
 import (
+	"io"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -73,4 +78,365 @@ func TestRecorder(t *testing.T) {
 		data := rec.GetAudioData()
 		assert.Equal(t, testSamples, data)
 	})
+
+	t.Run("frames", func(t *testing.T) {
+		// Enable test mode
+		EnableTestMode(testSamples)
+		defer DisableTestMode()
+
+		rec := NewRecorder("")
+		frames := rec.Frames()
+		err := rec.StartRecording()
+		require.NoError(t, err)
+
+		// The channel is closed when the recording ends
+		var received []float32
+		for frame := range frames {
+			received = append(received, frame...)
+		}
+		_, err = rec.StopRecording()
+		require.NoError(t, err)
+		assert.Equal(t, testSamples, received)
+	})
+}
+
+// speechRecording is quiet noise with a tone standing in for speech
+func speechRecording(length, speechStart, speechEnd time.Duration) []float32 {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float32, int(length.Seconds()*16000))
+	for i := range samples {
+		samples[i] = float32(rng.NormFloat64() * 0.001)
+		if t := time.Duration(i) * time.Second / 16000; t >= speechStart && t < speechEnd {
+			samples[i] += float32(0.1 * math.Sin(2*math.Pi*300*float64(i)/16000))
+		}
+	}
+	return samples
+}
+
+// recordUntilDone records the test samples until the recording stops on its own
+func recordUntilDone(t *testing.T, autoStop AutoStop) *Recorder {
+	rec := NewRecorder("")
+	require.NoError(t, rec.SetAutoStop(autoStop))
+	require.NoError(t, rec.StartRecording())
+	<-rec.Done()
+	_, err := rec.StopRecording()
+	require.NoError(t, err)
+	return rec
+}
+
+func TestAutoStop(t *testing.T) {
+	EnableTestMode(speechRecording(8*time.Second, time.Second, 2*time.Second))
+	defer DisableTestMode()
+
+	t.Run("silence", func(t *testing.T) {
+		rec := recordUntilDone(t, AutoStop{Silence: time.Second})
+		assert.Equal(t, StopSilence, rec.StopReason())
+		// Stopped a second after the speech, within a read
+		assert.InDelta(t, 3*16000, len(rec.GetAudioData()), 1600+480)
+	})
+
+	t.Run("wait for speech", func(t *testing.T) {
+		rec := recordUntilDone(t, AutoStop{Silence: time.Second, WaitForSpeech: true})
+		assert.Equal(t, StopSilence, rec.StopReason())
+		// The recording starts with the padded speech
+		assert.InDelta(t, 2.2*16000, len(rec.GetAudioData()), 1600+480)
+		assert.Less(t, math.Abs(float64(rec.GetAudioData()[0])), 0.01)
+	})
+
+	t.Run("max duration", func(t *testing.T) {
+		rec := recordUntilDone(t, AutoStop{MaxDuration: 500 * time.Millisecond})
+		assert.Equal(t, StopMaxDuration, rec.StopReason())
+		assert.Len(t, rec.GetAudioData(), 8000)
+	})
+
+	t.Run("no speech", func(t *testing.T) {
+		EnableTestMode(speechRecording(2*time.Second, 0, 0))
+		rec := recordUntilDone(t, AutoStop{WaitForSpeech: true})
+		assert.Equal(t, StopSourceEnded, rec.StopReason())
+		assert.Empty(t, rec.GetAudioData())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rec := NewRecorder("")
+		assert.ErrorContains(t, rec.SetAutoStop(AutoStop{Silence: -time.Second}), "invalid silence duration")
+		assert.ErrorContains(t, rec.SetAutoStop(AutoStop{MaxDuration: -time.Second}), "invalid maximum duration")
+	})
+}
+
+// blockSource records the blocks sent to it
+type blockSource struct {
+	blocks chan []float32
+	frame  []float32
+}
+
+func (s *blockSource) Name() string { return "blocks" }
+
+func (s *blockSource) Start() error { return nil }
+
+func (s *blockSource) Read(p []float32) (int, error) {
+	if len(s.frame) == 0 {
+		block, ok := <-s.blocks
+		if !ok {
+			return 0, io.EOF
+		}
+		s.frame = block
+	}
+	n := copy(p, s.frame)
+	s.frame = s.frame[n:]
+	return n, nil
+}
+
+func (s *blockSource) Stop() error { return nil }
+
+func TestRecorderControls(t *testing.T) {
+	tone := speechRecording(time.Second, 0, time.Second)
+	quiet := speechRecording(time.Second, 0, 0)
+
+	source := &blockSource{blocks: make(chan []float32)}
+	rec := NewRecorder("")
+	rec.SetSource(source)
+	require.NoError(t, rec.StartRecording())
+
+	source.blocks <- quiet
+	require.Eventually(t, func() bool { return rec.Status().Elapsed == time.Second }, time.Second, time.Millisecond)
+	assert.False(t, rec.Status().Heard)
+	assert.Equal(t, time.Second, rec.Status().Silence)
+
+	source.blocks <- tone
+	require.Eventually(t, func() bool { return rec.Status().Elapsed == 2*time.Second }, time.Second, time.Millisecond)
+	status := rec.Status()
+	assert.InDelta(t, -23, status.RMS, 0.5)
+	assert.InDelta(t, -20, status.Peak, 0.5)
+	assert.True(t, status.Heard)
+	assert.Less(t, status.Silence, 100*time.Millisecond)
+
+	// While paused the level is shown but nothing is recorded
+	rec.Pause()
+	source.blocks <- quiet
+	require.Eventually(t, func() bool { return rec.Status().Paused && rec.Status().RMS < -50 }, time.Second, time.Millisecond)
+	assert.Equal(t, 2*time.Second, rec.Status().Elapsed)
+
+	rec.Resume()
+	source.blocks <- quiet
+	require.Eventually(t, func() bool { return rec.Status().Elapsed == 3*time.Second }, time.Second, time.Millisecond)
+	assert.False(t, rec.Status().Paused)
+	assert.InDelta(t, float64(time.Second), float64(rec.Status().Silence), float64(50*time.Millisecond))
+
+	close(source.blocks)
+	_, err := rec.StopRecording()
+	require.NoError(t, err)
+	assert.Equal(t, append(append(append([]float32(nil), quiet...), tone...), quiet...), rec.GetAudioData())
+}
+
+func TestRecorderStatus_WaitForSpeech(t *testing.T) {
+	source := &blockSource{blocks: make(chan []float32)}
+	rec := NewRecorder("")
+	rec.SetSource(source)
+	require.NoError(t, rec.SetAutoStop(AutoStop{WaitForSpeech: true}))
+	require.NoError(t, rec.StartRecording())
+
+	// The wait is not part of the recording
+	source.blocks <- speechRecording(time.Second, 0, 0)
+	require.Eventually(t, func() bool { return rec.Status().Silence == time.Second }, time.Second, time.Millisecond)
+	assert.Zero(t, rec.Status().Elapsed)
+
+	source.blocks <- speechRecording(time.Second, 0, time.Second)
+	require.Eventually(t, func() bool { return rec.Status().Heard }, time.Second, time.Millisecond)
+	close(source.blocks)
+	_, err := rec.StopRecording()
+	require.NoError(t, err)
+	// The speech and its padding
+	assert.Equal(t, len(rec.GetAudioData()), int(rec.Status().Elapsed*16000/time.Second))
+	assert.InDelta(t, float64(1200*time.Millisecond), float64(rec.Status().Elapsed), float64(100*time.Millisecond))
+}
+
+func TestDiscardRecording(t *testing.T) {
+	EnableTestMode([]float32{0.1, 0.2, 0.3})
+	defer DisableTestMode()
+
+	path := filepath.Join(t.TempDir(), "discarded.wav")
+	rec := NewRecorder(path)
+	require.NoError(t, rec.StartRecording())
+	require.NoError(t, rec.DiscardRecording())
+	assert.Empty(t, rec.GetAudioData())
+	assert.NoFileExists(t, path)
+
+	assert.Error(t, rec.DiscardRecording())
+}
+
+// collectTakes receives the takes of a continuous session until it ends
+func collectTakes(t *testing.T, rec *Recorder) []*Take {
+	var takes []*Take
+	for take := range rec.Takes() {
+		select {
+		case <-take.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("take did not end")
+		}
+		takes = append(takes, take)
+	}
+	return takes
+}
+
+func TestContinuous(t *testing.T) {
+	t.Run("max duration", func(t *testing.T) {
+		samples := speechRecording(3500*time.Millisecond, 0, 0)
+		EnableTestMode(samples)
+		defer DisableTestMode()
+
+		rec := NewRecorder("")
+		rec.SetContinuous(true)
+		require.NoError(t, rec.SetAutoStop(AutoStop{MaxDuration: time.Second}))
+		frames := rec.Frames()
+		require.NoError(t, rec.StartRecording())
+
+		takes := collectTakes(t, rec)
+		require.Len(t, takes, 4)
+		assert.Equal(t, frames, takes[0].Frames)
+		// Takes follow each other without a gap
+		var recorded []float32
+		for i, take := range takes {
+			recorded = append(recorded, take.Samples()...)
+			if i < 3 {
+				assert.Equal(t, StopMaxDuration, take.StopReason())
+				assert.Len(t, take.Samples(), 16000)
+			}
+		}
+		assert.Equal(t, StopSourceEnded, takes[3].StopReason())
+		assert.Equal(t, samples, recorded)
+
+		_, err := rec.StopRecording()
+		require.NoError(t, err)
+	})
+
+	t.Run("silence", func(t *testing.T) {
+		samples := append(speechRecording(4*time.Second, time.Second, 2*time.Second), speechRecording(4*time.Second, time.Second, 2*time.Second)...)
+		EnableTestMode(samples)
+		defer DisableTestMode()
+
+		rec := NewRecorder("")
+		rec.SetContinuous(true)
+		require.NoError(t, rec.SetAutoStop(AutoStop{Silence: time.Second, WaitForSpeech: true}))
+		require.NoError(t, rec.StartRecording())
+
+		takes := collectTakes(t, rec)
+		require.Len(t, takes, 3)
+		for _, take := range takes[:2] {
+			assert.Equal(t, StopSilence, take.StopReason())
+			// Every take starts with its padded speech
+			assert.InDelta(t, 2.2*16000, len(take.Samples()), 1600+480)
+		}
+		// No speech followed
+		assert.Equal(t, StopSourceEnded, takes[2].StopReason())
+		assert.Empty(t, takes[2].Samples())
+
+		_, err := rec.StopRecording()
+		require.NoError(t, err)
+	})
+
+	t.Run("end and discard", func(t *testing.T) {
+		block := func(value float32) []float32 {
+			samples := make([]float32, 16000)
+			for i := range samples {
+				samples[i] = value
+			}
+			return samples
+		}
+		source := &blockSource{blocks: make(chan []float32)}
+		rec := NewRecorder("")
+		rec.SetSource(source)
+		rec.SetContinuous(true)
+		assert.Error(t, rec.EndTake(nil))
+		frames := rec.Frames()
+		require.NoError(t, rec.StartRecording())
+
+		first := <-rec.Takes()
+		go func() {
+			for range frames {
+			}
+		}()
+		source.blocks <- block(0.1)
+		require.Eventually(t, func() bool { return rec.Status().Elapsed == time.Second }, time.Second, time.Millisecond)
+		require.NoError(t, rec.EndTake(first))
+		source.blocks <- block(0.2)
+		<-first.Done()
+		assert.Equal(t, block(0.1), first.Samples())
+		assert.Empty(t, first.StopReason())
+
+		// The next take has its own frames and status
+		second := <-rec.Takes()
+		require.NotNil(t, second.Frames)
+		assert.NotEqual(t, frames, second.Frames)
+		go func() {
+			for range second.Frames {
+			}
+		}()
+		require.Eventually(t, func() bool { return rec.Status().Elapsed == time.Second }, time.Second, time.Millisecond)
+		require.NoError(t, rec.DiscardTake(second))
+		source.blocks <- block(0.3)
+		<-second.Done()
+		assert.Empty(t, second.Samples())
+
+		// Ending a take which has already ended leaves the next one running
+		require.NoError(t, rec.EndTake(second))
+
+		third := <-rec.Takes()
+		go func() {
+			for range third.Frames {
+			}
+		}()
+		close(source.blocks)
+		<-third.Done()
+		assert.Equal(t, block(0.3), third.Samples())
+		assert.Equal(t, StopSourceEnded, third.StopReason())
+		_, open := <-rec.Takes()
+		assert.False(t, open)
+
+		_, err := rec.StopRecording()
+		require.NoError(t, err)
+	})
+}
+
+func TestRecorderReuse(t *testing.T) {
+	pcm := filepath.Join(t.TempDir(), "audio.raw")
+	require.NoError(t, os.WriteFile(pcm, make([]byte, 2*16000), 0644))
+	fakeBackend(t, "cat "+pcm)
+
+	source, err := newCommandSource(BackendParec, Capture{})
+	require.NoError(t, err)
+	rec := NewRecorder("")
+	rec.SetSource(source)
+
+	// The first recording ends with the source and streams its frames
+	frames := rec.Frames()
+	require.NoError(t, rec.StartRecording())
+	<-rec.Done()
+	_, err = rec.StopRecording()
+	require.NoError(t, err)
+	assert.Equal(t, StopSourceEnded, rec.StopReason())
+	assert.Len(t, rec.GetAudioData(), 16000)
+	streamed := 0
+	for frame := range frames {
+		streamed += len(frame)
+	}
+	assert.Equal(t, 16000, streamed)
+
+	// The next one restarts the tool, stops on its own and has no frames left over
+	require.NoError(t, rec.SetAutoStop(AutoStop{MaxDuration: 500 * time.Millisecond}))
+	require.NoError(t, rec.StartRecording())
+	<-rec.Done()
+	_, err = rec.StopRecording()
+	require.NoError(t, err)
+	assert.Equal(t, StopMaxDuration, rec.StopReason())
+	assert.Len(t, rec.GetAudioData(), 8000)
+
+	// Stopped by hand the reason is cleared again
+	fakeBackend(t, "exec cat /dev/zero")
+	require.NoError(t, rec.SetAutoStop(AutoStop{}))
+	require.NoError(t, rec.StartRecording())
+	require.Eventually(t, func() bool { return rec.Status().Elapsed > 0 }, time.Second, time.Millisecond)
+	_, err = rec.StopRecording()
+	require.NoError(t, err)
+	assert.Empty(t, rec.StopReason())
+	assert.NotEmpty(t, rec.GetAudioData())
 }
@@ -0,0 +1,368 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	audioloader "github.com/piotrjaromin/transcript/internal/audio"
+)
+
+// Recorder backends, selected by name with NewSource
+const (
+	// BackendAuto picks the first backend whose tool is installed, in the
+	// order of DetectOrder
+	BackendAuto = "auto"
+	// BackendSox records the default device with SoX
+	BackendSox = "sox"
+	// BackendArecord records the default ALSA device
+	BackendArecord = "arecord"
+	// BackendParec records the default PulseAudio or PipeWire source
+	BackendParec = "parec"
+	// BackendFFmpeg records the platform's default input device with FFmpeg
+	BackendFFmpeg = "ffmpeg"
+	// BackendFile reads audio from a file, or standard input for "-", given
+	// as "file:<path>", e.g. for testing without a microphone
+	BackendFile = "file"
+)
+
+// DetectOrder is the order in which BackendAuto looks for recording tools
+var DetectOrder = []string{BackendSox, BackendParec, BackendArecord, BackendFFmpeg}
+
+// Source captures audio, e.g. from a microphone. It yields mono samples at
+// whisper's sample rate as they are recorded.
+type Source interface {
+	// Name is the backend the source records with
+	Name() string
+	// Start begins capturing
+	Start() error
+	// Read fills p with the next samples, blocking until some are recorded.
+	// It returns io.EOF once the source has ended, after Stop or on its own.
+	Read(p []float32) (int, error)
+	// Stop ends capturing, Read still returns what was recorded until then
+	Stop() error
+}
+
+// Capture describes what a source records. Whatever the format, the samples
+// are mixed down to mono and resampled to whisper's sample rate.
+type Capture struct {
+	// Device is the backend's name of the input device, as listed by
+	// ListDevices, empty records the default device
+	Device string
+	// SampleRate is the rate the device is recorded at, zero records at
+	// whisper's sample rate
+	SampleRate int
+	// Channels is the number of channels recorded, zero records mono
+	Channels int
+}
+
+// Validate checks the sample rate and channel count
+func (c Capture) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > maxSampleRate {
+		return fmt.Errorf("invalid sample rate %d: use up to %d Hz, e.g. %d", c.SampleRate, maxSampleRate, audioloader.SampleRate)
+	}
+	if c.Channels < 0 || c.Channels > maxChannels {
+		return fmt.Errorf("invalid channel count %d: use 1 to %d", c.Channels, maxChannels)
+	}
+	return nil
+}
+
+// Limits of the capture format
+const (
+	maxSampleRate = 384000
+	maxChannels   = 32
+)
+
+// format returns the raw PCM format the command backends record in
+func (c Capture) format() audioloader.RawFormat {
+	format := audioloader.RawFormat{Encoding: "s16le", SampleRate: c.SampleRate, Channels: c.Channels}
+	if format.SampleRate == 0 {
+		format.SampleRate = audioloader.SampleRate
+	}
+	if format.Channels == 0 {
+		format.Channels = 1
+	}
+	return format
+}
+
+// NewSource creates the source of a backend: one of the Backend names or
+// "file:<path>", empty selects BackendAuto. A file is read as it is, the
+// capture settings apply to the other backends. With a device BackendAuto
+// skips sox, as ListDevices does.
+func NewSource(backend string, capture Capture) (Source, error) {
+	if err := capture.Validate(); err != nil {
+		return nil, err
+	}
+	if path, ok := strings.CutPrefix(backend, BackendFile+":"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("invalid recorder backend %q: give the file to read, e.g. file:speech.wav", backend)
+		}
+		return NewFileSource(path), nil
+	}
+
+	switch backend {
+	case "", BackendAuto:
+		// A device name is one listed by ListDevices, so the same backend records it
+		if backend, ok := detectBackend(capture.Device != ""); ok {
+			return newCommandSource(backend, capture)
+		}
+		if _, err := exec.LookPath(BackendSox); err == nil && capture.Device != "" {
+			return nil, errSoxDevice
+		}
+		return nil, errNoBackend
+	case BackendSox, BackendArecord, BackendParec, BackendFFmpeg:
+		if _, err := exec.LookPath(backend); err != nil {
+			return nil, fmt.Errorf("recorder backend %s is not available: %w", backend, err)
+		}
+		return newCommandSource(backend, capture)
+	default:
+		return nil, fmt.Errorf("invalid recorder backend %q: use %s, %s or file:<path>", backend, BackendAuto, strings.Join(DetectOrder, ", "))
+	}
+}
+
+// DetectSource returns the source of the first backend in DetectOrder whose
+// tool is installed, recording the default device in whisper's format
+func DetectSource() (Source, error) {
+	return NewSource(BackendAuto, Capture{})
+}
+
+// errNoBackend reports that none of the recording tools is installed
+var errNoBackend = fmt.Errorf("no audio recording tool found: install sox, parec (PulseAudio), arecord (ALSA) or ffmpeg")
+
+// errSoxDevice reports that only sox is installed, which is not picked for a
+// named device as it cannot list the devices
+var errSoxDevice = fmt.Errorf("no audio recording tool found for device: sox cannot list devices to record a named one, use --recorder-backend sox or install parec (PulseAudio), arecord (ALSA) or ffmpeg")
+
+// detectBackend returns the first installed backend of DetectOrder, only
+// those which can list their devices when listing is set
+func detectBackend(listing bool) (string, bool) {
+	for _, backend := range DetectOrder {
+		if listing && backend == BackendSox {
+			continue
+		}
+		if _, err := exec.LookPath(backend); err == nil {
+			return backend, true
+		}
+	}
+	return "", false
+}
+
+// commandSource records with an external tool writing 16-bit little-endian
+// PCM to its standard output, which is decoded as raw audio
+type commandSource struct {
+	frameReader
+	backend string
+	args    []string
+	env     []string
+	format  audioloader.RawFormat
+
+	cmd     *exec.Cmd
+	stderr  bytes.Buffer
+	stopped atomic.Bool
+	waited  bool
+}
+
+// newCommandSource creates the source of an installed command backend
+func newCommandSource(backend string, capture Capture) (*commandSource, error) {
+	format := capture.format()
+	rate, channels := strconv.Itoa(format.SampleRate), strconv.Itoa(format.Channels)
+	s := &commandSource{backend: backend, format: format}
+
+	switch backend {
+	case BackendSox:
+		// SoX's default device is chosen by its audio driver, which reads AUDIODEV
+		if capture.Device != "" {
+			s.env = append(os.Environ(), "AUDIODEV="+capture.Device)
+		}
+		s.args = []string{"-q", "-d", "-t", "raw", "-r", rate, "-c", channels, "-e", "signed-integer", "-b", "16", "-L", "-"}
+	case BackendArecord:
+		s.args = []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", rate, "-c", channels}
+		if capture.Device != "" {
+			s.args = append(s.args, "-D", capture.Device)
+		}
+	case BackendParec:
+		s.args = []string{"--raw", "--format=s16le", "--rate=" + rate, "--channels=" + channels}
+		if capture.Device != "" {
+			s.args = append(s.args, "--device="+capture.Device)
+		}
+	case BackendFFmpeg:
+		input, device := ffmpegInputDevice()
+		if capture.Device != "" {
+			device = capture.Device
+		}
+		if device == "" {
+			return nil, fmt.Errorf("ffmpeg needs a device on %s, list them with the devices command", runtime.GOOS)
+		}
+		s.args = []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-f", input, "-i", device,
+			"-f", "s16le", "-ac", channels, "-ar", rate, "pipe:1"}
+	}
+	return s, nil
+}
+
+// ffmpegInputDevice returns FFmpeg's input format and default device of the
+// platform, DirectShow has no default device
+func ffmpegInputDevice() (input, device string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "avfoundation", ":0"
+	case "windows":
+		return "dshow", ""
+	default:
+		return "pulse", "default"
+	}
+}
+
+func (s *commandSource) Name() string { return s.backend }
+
+func (s *commandSource) Start() error {
+	// A source records again after it was stopped, e.g. for the next take
+	s.stopped.Store(false)
+	s.waited = false
+	s.stderr.Reset()
+	s.frame = nil
+
+	s.cmd = execCommand(s.backend, s.args...)
+	s.cmd.Env = s.env
+	s.cmd.Stderr = &s.stderr
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", s.backend, err)
+	}
+	if err := s.cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", s.backend, err)
+	}
+
+	// Mixed down and resampled as it arrives, in frames short enough for live use
+	s.stream, err = audioloader.NewStream(stdout, readSize, audioloader.Options{Filter: audioloader.FilterNone, Raw: &s.format})
+	if err != nil {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+		return err
+	}
+	return nil
+}
+
+func (s *commandSource) Read(p []float32) (int, error) {
+	n, err := s.frameReader.read(p)
+	if err == io.EOF && !s.waited {
+		s.waited = true
+		return n, s.wait()
+	}
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("failed to read audio from %s: %w", s.backend, err)
+	}
+	return n, err
+}
+
+// wait reaps the process once its output has ended, reporting a failure
+// unless it was stopped
+func (s *commandSource) wait() error {
+	if err := s.cmd.Wait(); err != nil && !s.stopped.Load() {
+		if output := strings.TrimSpace(s.stderr.String()); output != "" {
+			return fmt.Errorf("%s failed: %w: %s", s.backend, err, output)
+		}
+		return fmt.Errorf("%s failed: %w", s.backend, err)
+	}
+	return io.EOF
+}
+
+func (s *commandSource) Stop() error {
+	if s.cmd == nil || s.cmd.Process == nil || s.stopped.Swap(true) {
+		return nil
+	}
+	// The tools flush what they recorded on SIGTERM, Windows can only kill
+	if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		// A tool which has exited on its own has nothing left to stop
+		if err := s.cmd.Process.Kill(); !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameReader reads the frames of a decoding stream in blocks of any size
+type frameReader struct {
+	stream *audioloader.Stream
+	frame  []float32
+}
+
+// read copies the next samples into p, io.EOF marks the end of the stream
+func (r *frameReader) read(p []float32) (int, error) {
+	if len(r.frame) == 0 {
+		frame, ok := <-r.stream.Frames()
+		if !ok {
+			if err := r.stream.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		r.frame = frame
+	}
+	n := copy(p, r.frame)
+	r.frame = r.frame[n:]
+	return n, nil
+}
+
+// FileSource reads audio from a file, or standard input for
+// audioloader.StdinPath, as fast as it can be decoded. It stands in for a
+// microphone, e.g. in tests.
+type FileSource struct {
+	frameReader
+	path string
+}
+
+// NewFileSource creates a source reading the audio file at path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (s *FileSource) Name() string { return BackendFile }
+
+func (s *FileSource) Start() error {
+	// The audio is recorded as it is, without the default normalization
+	stream, err := audioloader.OpenStream(s.path, readSize, audioloader.Options{Filter: audioloader.FilterNone})
+	if err != nil {
+		return err
+	}
+	s.stream = stream
+	return nil
+}
+
+func (s *FileSource) Read(p []float32) (int, error) {
+	return s.frameReader.read(p)
+}
+
+func (s *FileSource) Stop() error {
+	if s.stream == nil {
+		return nil
+	}
+	return s.stream.Close()
+}
+
+// sampleSource replays samples given up front, used in test mode
+type sampleSource struct {
+	samples []float32
+}
+
+func (s *sampleSource) Name() string { return "test" }
+
+func (s *sampleSource) Start() error { return nil }
+
+func (s *sampleSource) Read(p []float32) (int, error) {
+	if len(s.samples) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.samples)
+	s.samples = s.samples[n:]
+	return n, nil
+}
+
+func (s *sampleSource) Stop() error { return nil }
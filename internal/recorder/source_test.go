@@ -0,0 +1,177 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSource(t *testing.T) {
+	source, err := NewSource("file:speech.wav", Capture{})
+	require.NoError(t, err)
+	assert.Equal(t, BackendFile, source.Name())
+
+	_, err = NewSource("file:", Capture{})
+	assert.Error(t, err)
+
+	_, err = NewSource("portaudio", Capture{})
+	assert.ErrorContains(t, err, "invalid recorder backend")
+
+	_, err = NewSource("file:speech.wav", Capture{SampleRate: -1})
+	assert.ErrorContains(t, err, "invalid sample rate")
+	_, err = NewSource("file:speech.wav", Capture{Channels: 64})
+	assert.ErrorContains(t, err, "invalid channel count")
+}
+
+func TestNewSource_SoxOnly(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sox"), []byte("#!/bin/sh\n"), 0755))
+	t.Setenv("PATH", bin)
+
+	source, err := NewSource(BackendAuto, Capture{})
+	require.NoError(t, err)
+	assert.Equal(t, BackendSox, source.Name())
+
+	// sox is not picked for a device since it cannot list them
+	_, err = NewSource(BackendAuto, Capture{Device: "hw:1"})
+	assert.ErrorContains(t, err, "use --recorder-backend sox")
+
+	source, err = NewSource(BackendSox, Capture{Device: "hw:1"})
+	require.NoError(t, err)
+	assert.Equal(t, BackendSox, source.Name())
+}
+
+func TestCommandArgs(t *testing.T) {
+	source, err := newCommandSource(BackendArecord, Capture{Device: "hw:1,0", SampleRate: 48000, Channels: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", "48000", "-c", "2", "-D", "hw:1,0"}, source.args)
+
+	source, err = newCommandSource(BackendParec, Capture{Device: "alsa_input.usb"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--raw", "--format=s16le", "--rate=16000", "--channels=1", "--device=alsa_input.usb"}, source.args)
+
+	source, err = newCommandSource(BackendSox, Capture{Device: "hw:1"})
+	require.NoError(t, err)
+	assert.Contains(t, source.env, "AUDIODEV=hw:1")
+}
+
+// fakeBackend makes every backend run the shell command instead
+func fakeBackend(t *testing.T, script string) {
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", script)
+	}
+	t.Cleanup(func() { execCommand = exec.Command })
+}
+
+// readAll reads the source in blocks of an odd number of bytes' worth
+func readAll(source Source) ([]float32, error) {
+	var samples []float32
+	buf := make([]float32, 3)
+	for {
+		n, err := source.Read(buf)
+		samples = append(samples, buf[:n]...)
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return samples, err
+		}
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	t.Run("pcm output", func(t *testing.T) {
+		pcm := filepath.Join(t.TempDir(), "audio.raw")
+		data := make([]byte, 0, 10)
+		for _, v := range []int16{0, 16384, -16384, 32767, -32768} {
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		}
+		require.NoError(t, os.WriteFile(pcm, data, 0644))
+		fakeBackend(t, "cat "+pcm)
+
+		source, err := newCommandSource(BackendSox, Capture{})
+		require.NoError(t, err)
+		require.NoError(t, source.Start())
+		samples, err := readAll(source)
+		require.NoError(t, err)
+		assert.InDeltaSlice(t, []float32{0, 0.5, -0.5, 1, -1}, samples, 1e-4)
+	})
+
+	t.Run("resampled", func(t *testing.T) {
+		// One second of stereo at 8kHz with a constant level per channel
+		pcm := filepath.Join(t.TempDir(), "audio.raw")
+		data := make([]byte, 0, 4*8000)
+		for i := 0; i < 8000; i++ {
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(8192)))
+			data = binary.LittleEndian.AppendUint16(data, uint16(int16(16384)))
+		}
+		require.NoError(t, os.WriteFile(pcm, data, 0644))
+		fakeBackend(t, "cat "+pcm)
+
+		source, err := newCommandSource(BackendParec, Capture{SampleRate: 8000, Channels: 2})
+		require.NoError(t, err)
+		require.NoError(t, source.Start())
+		samples, err := readAll(source)
+		require.NoError(t, err)
+		assert.InDelta(t, 16000, len(samples), 16)
+		// Mixed down, away from the edges of the resampling filter
+		assert.InDelta(t, 0.375, samples[8000], 1e-3)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		fakeBackend(t, "exec cat /dev/zero")
+
+		source, err := newCommandSource(BackendArecord, Capture{})
+		require.NoError(t, err)
+		require.NoError(t, source.Start())
+		time.AfterFunc(50*time.Millisecond, func() { source.Stop() })
+		samples, err := readAll(source)
+		require.NoError(t, err)
+		assert.NotEmpty(t, samples)
+
+		// A stopped source records again
+		require.NoError(t, source.Start())
+		time.AfterFunc(50*time.Millisecond, func() { source.Stop() })
+		samples, err = readAll(source)
+		require.NoError(t, err)
+		assert.NotEmpty(t, samples)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		fakeBackend(t, "echo 'no such device' >&2; exit 1")
+
+		source, err := newCommandSource(BackendParec, Capture{})
+		require.NoError(t, err)
+		require.NoError(t, source.Start())
+		_, err = readAll(source)
+		assert.ErrorContains(t, err, "no such device")
+	})
+}
+
+func TestFileSource(t *testing.T) {
+	samples := []float32{0.1, 0.2, 0.3, 0.4, 0.5}
+	path := filepath.Join(t.TempDir(), "speech.wav")
+	input := &Recorder{samples: samples, sampleRate: 16000}
+	require.NoError(t, input.Save(path))
+
+	rec := NewRecorder("")
+	rec.SetSource(NewFileSource(path))
+	require.NoError(t, rec.StartRecording())
+
+	// The recording ends with the file
+	select {
+	case <-rec.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording did not end with the file")
+	}
+	_, err := rec.StopRecording()
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, samples, rec.GetAudioData(), 1e-4)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piotrjaromin/transcript/internal/audio"
+)
+
+// Error codes returned in the code field of failed requests, clients may rely
+// on them not changing
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeMissingAudio        = "missing_audio"
+	codeFileTooLarge        = "file_too_large"
+	codeInvalidAudio        = "invalid_audio"
+	codeUnsupportedFormat   = "unsupported_format"
+	codeCorruptAudio        = "corrupt_audio"
+	codeEmptyAudio          = "empty_audio"
+	codeAudioTooLong        = "audio_too_long"
+	codeDecoderUnavailable  = "decoder_unavailable"
+	codeTranscriptionFailed = "transcription_failed"
+	codeInternal            = "internal_error"
+)
+
+// audioErrorStatus maps a decoding error to the HTTP status and error code of the response
+func audioErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, audio.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType, codeUnsupportedFormat
+	case errors.Is(err, audio.ErrCorrupt):
+		return http.StatusUnprocessableEntity, codeCorruptAudio
+	case errors.Is(err, audio.ErrEmpty):
+		return http.StatusUnprocessableEntity, codeEmptyAudio
+	case errors.Is(err, audio.ErrTooLong):
+		return http.StatusRequestEntityTooLarge, codeAudioTooLong
+	case errors.Is(err, audio.ErrDecoderMissing):
+		return http.StatusServiceUnavailable, codeDecoderUnavailable
+	default:
+		return http.StatusBadRequest, codeInvalidAudio
+	}
+}
+
+// respondError writes an error response with a machine-readable code
+func respondError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{
+		"error": message,
+		"code":  code,
+	})
+}
+
+// respondAudioError writes the error response for audio which could not be decoded
+func respondAudioError(c *gin.Context, err error) {
+	status, code := audioErrorStatus(err)
+	respondError(c, status, code, fmt.Sprintf("Invalid audio file: %v", err))
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{audio.ErrUnsupportedFormat, http.StatusUnsupportedMediaType, "unsupported_format"},
+		{fmt.Errorf("audio stream 2: %w", audio.ErrCorrupt), http.StatusUnprocessableEntity, "corrupt_audio"},
+		{audio.CheckAudible(nil), http.StatusUnprocessableEntity, "empty_audio"},
+		{audio.ErrTooLong, http.StatusRequestEntityTooLarge, "audio_too_long"},
+		{audio.ErrDecoderMissing, http.StatusServiceUnavailable, "decoder_unavailable"},
+		{errors.New("audio has 2 channels, there is no channel 3"), http.StatusBadRequest, "invalid_audio"},
+	}
+
+	for _, test := range tests {
+		status, code := audioErrorStatus(test.err)
+		assert.Equal(t, test.status, status, test.err.Error())
+		assert.Equal(t, test.code, code, test.err.Error())
+	}
+}
@@ -2,32 +2,89 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/transcriber"
 	"github.com/piotrjaromin/transcript/internal/whisper"
 )
 
+// Config holds the server settings
+type Config struct {
+	Port       int
+	ModelPath  string
+	Language   string
+	NumThreads int
+	// Routes maps languages to models, when set ModelPath is only used to
+	// detect the language and for languages without a route
+	Routes map[string]string
+	// AudioFilter is the default preprocessing, see audio.ParseFilter, requests
+	// may override it with presets in the audio_filter field
+	AudioFilter string
+	// MaxAudioDuration rejects longer uploads, zero accepts any length
+	MaxAudioDuration time.Duration
+	// Tempo speeds the audio up before transcription, see audio.Options.Tempo,
+	// requests may override it with the tempo field
+	Tempo float64
+	// NoiseSuppression is the default in-process noise suppression in dB, see
+	// audio.Options.NoiseSuppression, requests may override it with the
+	// noise_suppression field
+	NoiseSuppression float64
+	// VAD is the voice activity detection of requests with the vad field,
+	// which may tune it with the vad_margin, vad_min_energy, vad_padding and
+	// vad_min_silence fields, zero uses audio.DefaultVADOptions
+	VAD audio.VADOptions
+}
+
+// Request context keys describing the uploaded audio
+const (
+	// containerKey holds the container format
+	containerKey = "container"
+	// qualityKey holds the audio.QualityReport
+	qualityKey = "quality"
+)
+
 // Server represents the HTTP server for transcription
 type Server struct {
-	port          int
-	modelPath     string
-	language      string
-	numThreads    int
-	whisperClient whisper.Client
+	port             int
+	modelPath        string
+	language         string
+	numThreads       int
+	routes           map[string]string
+	audioFilter      string
+	maxDuration      time.Duration
+	tempo            float64
+	noiseSuppression float64
+	vad              audio.VADOptions
+	whisperClient    whisper.Client
+	router           *whisper.Router
+	transcriber      *transcriber.FileTranscriber
 }
 
 // NewServer creates a new transcription server
-func NewServer(port int, modelPath, language string, threads int) *Server {
+func NewServer(cfg Config) *Server {
+	if cfg.VAD == (audio.VADOptions{}) {
+		cfg.VAD = audio.DefaultVADOptions()
+	}
 	return &Server{
-		port:       port,
-		modelPath:  modelPath,
-		language:   language,
-		numThreads: threads,
+		port:             cfg.Port,
+		modelPath:        cfg.ModelPath,
+		language:         cfg.Language,
+		numThreads:       cfg.NumThreads,
+		routes:           cfg.Routes,
+		audioFilter:      cfg.AudioFilter,
+		maxDuration:      cfg.MaxAudioDuration,
+		tempo:            cfg.Tempo,
+		noiseSuppression: cfg.NoiseSuppression,
+		vad:              cfg.VAD,
 	}
 }
 
@@ -37,6 +94,22 @@ func (s *Server) Start() error {
 		return fmt.Errorf("invalid port number: %d", s.port)
 	}
 
+	if _, err := audio.ParseFilter(s.audioFilter); err != nil {
+		return err
+	}
+	if err := whisper.CheckRouting(s.language, s.routes); err != nil {
+		return err
+	}
+	if err := audio.CheckTempo(s.tempo); err != nil {
+		return err
+	}
+	if err := audio.CheckNoiseSuppression(s.noiseSuppression); err != nil {
+		return err
+	}
+	if err := audio.CheckVAD(s.vad); err != nil {
+		return err
+	}
+
 	// Check if model file exists
 	if _, err := os.Stat(s.modelPath); os.IsNotExist(err) {
 		return fmt.Errorf("model file not found: %s", s.modelPath)
@@ -44,71 +117,530 @@ func (s *Server) Start() error {
 
 	// Initialize whisper client
 	var err error
-	s.whisperClient, err = whisper.NewClient(s.modelPath, s.language, s.numThreads)
+	if len(s.routes) > 0 {
+		s.router, err = whisper.NewRouter(s.modelPath, s.routes, s.numThreads)
+		s.whisperClient = s.router
+	} else {
+		s.whisperClient, err = whisper.NewClient(s.modelPath, s.language, s.numThreads)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to initialize whisper client: %w", err)
 	}
 	defer s.whisperClient.Close()
+	s.transcriber = transcriber.NewFileTranscriberWithClient(s.whisperClient)
 
 	r := gin.Default()
-	r.MaxMultipartMemory = 8 << 20  // 8 MB limit for uploaded files
+	r.MaxMultipartMemory = 8 << 20 // 8 MB limit for uploaded files
 
 	r.POST("/transcribe", s.handleTranscribe)
+	r.POST("/detect-language", s.handleDetectLanguage)
+	r.POST("/probe", s.handleProbe)
 
 	return r.Run(":" + strconv.Itoa(s.port))
 }
 
-// handleTranscribe handles the transcription endpoint
-func (s *Server) handleTranscribe(c *gin.Context) {
+// saveUploadedAudio stores the uploaded audio file in a temporary location.
+// On failure the error response has already been written and ok is false.
+func (s *Server) saveUploadedAudio(c *gin.Context) (path string, ok bool) {
 	// Get audio file from request
 	file, err := c.FormFile("audio")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing audio file"})
-		return
+		respondError(c, http.StatusBadRequest, codeMissingAudio, "Missing audio file")
+		return "", false
 	}
 
 	// Check file size
 	if file.Size > 10*1024*1024 { // 10MB limit
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 10MB)"})
-		return
+		respondError(c, http.StatusRequestEntityTooLarge, codeFileTooLarge, "File too large (max 10MB)")
+		return "", false
+	}
+
+	// Recognise the format by content, the file name is up to the client
+	container, err := sniffUpload(file)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeInternal, "Failed to read audio file")
+		return "", false
 	}
+	if container == "" {
+		respondError(c, http.StatusUnsupportedMediaType, codeUnsupportedFormat, "Unsupported audio format: the upload is not a known audio or video format")
+		return "", false
+	}
+	c.Set(containerKey, container)
 
 	// Create a secure temporary file
-	tempFile, err := ioutil.TempFile("", "audio-*.wav")
+	tempFile, err := ioutil.TempFile("", "audio-*"+audio.ContainerExtension(container))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create temp file"})
-		return
+		respondError(c, http.StatusInternalServerError, codeInternal, "Failed to create temp file")
+		return "", false
 	}
 	tempFile.Close()
-	
+
 	// Save uploaded file to temp location
 	if err := c.SaveUploadedFile(file, tempFile.Name()); err != nil {
 		os.Remove(tempFile.Name())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save audio file"})
+		respondError(c, http.StatusInternalServerError, codeInternal, "Failed to save audio file")
+		return "", false
+	}
+
+	return tempFile.Name(), true
+}
+
+// sniffUpload identifies the container format of an uploaded file
+func sniffUpload(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, audio.SniffSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return audio.SniffContainer(header[:n]), nil
+}
+
+// respondOK writes a successful response, reporting the container format and
+// the quality of the uploaded audio
+func respondOK(c *gin.Context, body gin.H) {
+	if container := c.GetString(containerKey); container != "" {
+		body["container"] = container
+	}
+	if quality, ok := c.Get(qualityKey); ok {
+		body["quality"] = quality
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// measureQuality measures the quality of the uploaded audio for the response
+// when the request asks for it with quality=true, decoding the audio once
+// more. On failure the error response has already been written and it
+// returns false.
+func (s *Server) measureQuality(c *gin.Context, audioPath string, opts audio.Options) bool {
+	if c.PostForm("quality") != "true" {
+		return true
+	}
+	report, err := audio.AnalyzeQuality(audioPath, opts)
+	if err != nil {
+		respondAudioError(c, err)
+		return false
+	}
+	c.Set(qualityKey, report)
+	return true
+}
+
+// audioOptions returns the decoding options of the request, the audio_filter
+// field overrides the configured filter with presets, custom filters are only
+// allowed in the configuration. On failure the error response has already
+// been written and ok is false.
+func (s *Server) audioOptions(c *gin.Context) (opts audio.Options, ok bool) {
+	filter := s.audioFilter
+	if value, set := c.GetPostForm("audio_filter"); set {
+		if _, err := audio.ParsePresetFilter(value); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return audio.Options{}, false
+		}
+		filter = value
+	}
+	suppression := s.noiseSuppression
+	if value := c.PostForm("noise_suppression"); value != "" {
+		var err error
+		if suppression, err = strconv.ParseFloat(value, 64); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid noise_suppression")
+			return audio.Options{}, false
+		}
+	}
+	if err := audio.CheckNoiseSuppression(suppression); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return audio.Options{}, false
+	}
+	return audio.Options{Filter: filter, DurationLimit: s.maxDuration, NoiseSuppression: suppression}, true
+}
+
+// requestTempo returns the tempo change of a transcription request
+func (s *Server) requestTempo(c *gin.Context) (float64, bool) {
+	tempo := s.tempo
+	if value := c.PostForm("tempo"); value != "" {
+		var err error
+		if tempo, err = strconv.ParseFloat(value, 64); err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid tempo")
+			return 0, false
+		}
+	}
+	if err := audio.CheckTempo(tempo); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return 0, false
+	}
+	return tempo, true
+}
+
+// requestVAD returns the voice activity detection of a transcription request,
+// nil unless the vad field is true. On failure the error response has already
+// been written and ok is false.
+func (s *Server) requestVAD(c *gin.Context) (vad *audio.VADOptions, ok bool) {
+	if c.PostForm("vad") != "true" {
+		return nil, true
+	}
+
+	opts := s.vad
+	for field, value := range map[string]*float64{"vad_margin": &opts.EnergyMargin, "vad_min_energy": &opts.MinEnergy} {
+		if form := c.PostForm(field); form != "" {
+			var err error
+			if *value, err = strconv.ParseFloat(form, 64); err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid "+field)
+				return nil, false
+			}
+		}
+	}
+	for field, value := range map[string]*time.Duration{"vad_padding": &opts.Padding, "vad_min_silence": &opts.MinSilence} {
+		if form := c.PostForm(field); form != "" {
+			var err error
+			if *value, err = time.ParseDuration(form); err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid "+field)
+				return nil, false
+			}
+		}
+	}
+	if err := audio.CheckVAD(opts); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return nil, false
+	}
+	return &opts, true
+}
+
+// handleTranscribe handles the transcription endpoint
+func (s *Server) handleTranscribe(c *gin.Context) {
+	opts, ok := s.audioOptions(c)
+	if !ok {
+		return
+	}
+	if opts.Tempo, ok = s.requestTempo(c); !ok {
+		return
+	}
+	vad, ok := s.requestVAD(c)
+	if !ok {
+		return
+	}
+	channelMode, channel, err := audio.ParseChannels(c.PostForm("channels"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if channelMode == audio.ChannelsSplit && c.PostForm("multilingual") == "true" {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "channels=split cannot be combined with multilingual=true")
 		return
 	}
-	defer os.Remove(tempFile.Name())
 
-	// Load audio samples
-	samples, err := audio.LoadAudioFile(tempFile.Name())
+	audioPath, ok := s.saveUploadedAudio(c)
+	if !ok {
+		return
+	}
+	defer os.Remove(audioPath)
+
+	if strings.EqualFold(c.PostForm("audio_stream"), audio.StreamsAll) {
+		s.transcribeTracks(c, audioPath, opts, vad)
+		return
+	}
+	if opts.Stream, err = audio.ResolveStream(audioPath, c.PostForm("audio_stream")); err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	switch channelMode {
+	case audio.ChannelsSingle:
+		opts.Channel = channel
+	case audio.ChannelsLoudest:
+		if opts.Channel, err = audio.LoudestChannel(audioPath, opts); err != nil {
+			respondAudioError(c, err)
+			return
+		}
+	}
+
+	if !s.measureQuality(c, audioPath, opts) {
+		return
+	}
+	if channelMode == audio.ChannelsSplit {
+		s.transcribeChannels(c, audioPath, opts, vad)
+		return
+	}
+
+	// These modes need the whole input to decide on the language or find the speech
+	if c.PostForm("multilingual") == "true" || vad != nil || s.router != nil {
+		s.transcribeSamples(c, audioPath, opts, vad)
+		return
+	}
+
+	// Long and silent audio is rejected before the model runs
+	if err := audio.CheckDuration(audioPath, opts); err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	// Decode and transcribe window by window to bound memory use
+	stream, err := audio.OpenStream(audioPath, audio.DefaultFrameSize, opts)
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+	defer stream.Close()
+	frames, err := stream.AudibleFrames()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("Invalid audio file: %v", err),
-		})
+		respondAudioError(c, err)
 		return
 	}
 
-	// Transcribe audio
-	transcript, err := s.whisperClient.Transcribe(samples)
+	transcript, err := s.transcriber.TranscribeStream(frames)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Transcription failed: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+	// The decoder may still stop the audio at the duration limit
+	if err := stream.Err(); err != nil {
+		respondAudioError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	respondOK(c, gin.H{
 		"transcript": transcript,
 		"language":   s.language,
 	})
 }
+
+// transcribeTracks responds with the transcript of every audio stream of the
+// file, each decoded and transcribed separately
+func (s *Server) transcribeTracks(c *gin.Context, audioPath string, opts audio.Options, vad *audio.VADOptions) {
+	tracks, err := audio.ProbeTracks(audioPath)
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	var results []gin.H
+	for _, track := range tracks {
+		opts.Stream = track.Number
+		samples, err := audio.LoadAudioFileWithOptions(audioPath, opts)
+		if err != nil {
+			respondAudioError(c, fmt.Errorf("audio stream %d: %w", track.Number, err))
+			return
+		}
+
+		// Every stream may be in another language, e.g. dubs
+		segments, language, err := s.transcriber.TranscribeWithLanguage(samples, s.language, vad)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+			return
+		}
+		result := gin.H{
+			"track":      track,
+			"transcript": whisper.JoinSegments(segments),
+			"language":   language,
+		}
+		if c.PostForm("quality") == "true" {
+			if result["quality"], err = audio.AnalyzeQuality(audioPath, opts); err != nil {
+				respondAudioError(c, fmt.Errorf("audio stream %d: %w", track.Number, err))
+				return
+			}
+		}
+		if vad != nil {
+			result["segments"] = transcriber.OriginalTimeline(segments, opts)
+		}
+		results = append(results, result)
+	}
+
+	respondOK(c, gin.H{
+		"tracks": results,
+	})
+}
+
+// transcribeSamples loads the whole audio file and transcribes it either in
+// multilingual mode, only where there is speech, or with the model routed for
+// its language
+func (s *Server) transcribeSamples(c *gin.Context, audioPath string, opts audio.Options, vad *audio.VADOptions) {
+	// Load audio samples
+	samples, err := audio.LoadAudioFileWithOptions(audioPath, opts)
+	if err == nil {
+		err = audio.CheckAudible(samples)
+	}
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	if c.PostForm("multilingual") == "true" {
+		s.transcribeMultilingual(c, samples, opts)
+		return
+	}
+	if vad != nil {
+		s.transcribeSpeech(c, samples, opts, *vad)
+		return
+	}
+
+	// When routing the language is detected first
+	transcript, language, err := s.router.TranscribeWithLanguage(samples)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+
+	respondOK(c, gin.H{
+		"transcript": transcript,
+		"language":   language,
+	})
+}
+
+// transcribeMultilingual responds with segments whose language is detected per chunk
+func (s *Server) transcribeMultilingual(c *gin.Context, samples []float32, opts audio.Options) {
+	var languages []string
+	if value := c.PostForm("languages"); value != "" {
+		languages = strings.Split(value, ",")
+	}
+
+	segments, err := s.transcriber.TranscribeMultilingual(samples, transcriber.MultilingualOptions{
+		Languages: languages,
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+
+	respondOK(c, gin.H{
+		"transcript": whisper.JoinSegments(segments),
+		"segments":   transcriber.OriginalTimeline(segments, opts),
+	})
+}
+
+// transcribeChannels responds with the segments of every channel transcribed
+// separately, labelled with the speakers given in channel_labels
+func (s *Server) transcribeChannels(c *gin.Context, audioPath string, opts audio.Options, vad *audio.VADOptions) {
+	channels, err := audio.LoadChannels(audioPath, opts)
+	if err == nil {
+		// Silent channels are fine as long as somebody speaks
+		err = audio.CheckAudible(channels[0])
+		for _, samples := range channels[1:] {
+			if err != nil {
+				err = audio.CheckAudible(samples)
+			}
+		}
+	}
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	var channelOpts transcriber.ChannelOptions
+	if value := c.PostForm("channel_labels"); value != "" {
+		channelOpts.Labels = strings.Split(value, ",")
+	}
+	channelOpts.VAD = vad
+
+	segments, err := s.transcriber.TranscribeChannels(channels, channelOpts)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+
+	respondOK(c, gin.H{
+		"transcript": whisper.JoinSegments(segments),
+		"segments":   transcriber.OriginalTimeline(segments, opts),
+	})
+}
+
+// transcribeSpeech responds with segments transcribed only where voice
+// activity detection found speech
+func (s *Server) transcribeSpeech(c *gin.Context, samples []float32, opts audio.Options, vad audio.VADOptions) {
+	segments, err := s.transcriber.TranscribeSpeech(samples, vad)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+
+	respondOK(c, gin.H{
+		"transcript": whisper.JoinSegments(segments),
+		"segments":   transcriber.OriginalTimeline(segments, opts),
+	})
+}
+
+// handleDetectLanguage handles the language identification endpoint
+func (s *Server) handleDetectLanguage(c *gin.Context) {
+	// Seconds of audio to analyse and number of languages to return
+	duration, err := strconv.Atoi(c.DefaultPostForm("duration", "30"))
+	if err != nil || duration <= 0 {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid duration")
+		return
+	}
+	top, err := strconv.Atoi(c.DefaultPostForm("top", "5"))
+	if err != nil || top < 0 {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "Invalid top")
+		return
+	}
+	opts, ok := s.audioOptions(c)
+	if !ok {
+		return
+	}
+
+	audioPath, ok := s.saveUploadedAudio(c)
+	if !ok {
+		return
+	}
+	defer os.Remove(audioPath)
+
+	if opts.Stream, err = audio.ResolveStream(audioPath, c.PostForm("audio_stream")); err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	// Only the beginning of the audio is decoded
+	opts.MaxDuration = time.Duration(duration) * time.Second
+	opts.DurationLimit = 0
+	samples, err := audio.LoadAudioFileWithOptions(audioPath, opts)
+	if err == nil {
+		err = audio.CheckAudible(samples)
+	}
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	languages, err := s.whisperClient.DetectLanguage(samples)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeTranscriptionFailed, fmt.Sprintf("Language detection failed: %v", err))
+		return
+	}
+
+	languages = whisper.TopLanguages(languages, top)
+
+	respondOK(c, gin.H{
+		"language":  languages[0].Language,
+		"languages": languages,
+	})
+}
+
+// handleProbe describes the uploaded audio without transcribing it, so clients
+// can estimate the cost or reject unsuitable files
+func (s *Server) handleProbe(c *gin.Context) {
+	opts, ok := s.audioOptions(c)
+	if !ok {
+		return
+	}
+
+	audioPath, ok := s.saveUploadedAudio(c)
+	if !ok {
+		return
+	}
+	defer os.Remove(audioPath)
+
+	var err error
+	if opts.Stream, err = audio.ResolveStream(audioPath, c.PostForm("audio_stream")); err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	info, err := audio.Probe(audioPath, opts)
+	if err != nil {
+		respondAudioError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postForm sends a multipart request with the fields and, unless it is nil,
+// the audio to the handler and returns the status and decoded response
+func postForm(t *testing.T, handler gin.HandlerFunc, fields map[string]string, audio []byte) (int, map[string]interface{}) {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	if audio != nil {
+		part, err := writer.CreateFormFile("audio", "audio.wav")
+		require.NoError(t, err)
+		_, err = part.Write(audio)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/", handler)
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestHandleDetectLanguage_InvalidRequest(t *testing.T) {
+	s := NewServer(Config{})
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"zero duration", map[string]string{"duration": "0"}},
+		{"negative duration", map[string]string{"duration": "-5"}},
+		{"duration not a number", map[string]string{"duration": "half"}},
+		{"negative top", map[string]string{"top": "-1"}},
+		{"top not a number", map[string]string{"top": "all"}},
+		{"unknown audio filter", map[string]string{"audio_filter": "echo"}},
+		{"custom audio filter", map[string]string{"audio_filter": "custom:amovie=/etc/passwd"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, response := postForm(t, s.handleDetectLanguage, test.fields, []byte("RIFF"))
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, "invalid_request", response["code"])
+		})
+	}
+
+	t.Run("missing audio", func(t *testing.T) {
+		status, response := postForm(t, s.handleDetectLanguage, map[string]string{"top": "0"}, nil)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "missing_audio", response["code"])
+	})
+}
+
+// formContext returns the context of a request with the URL encoded form
+func formContext(form string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c
+}
+
+func TestAudioOptions(t *testing.T) {
+	s := NewServer(Config{AudioFilter: "custom:highpass=f=300"})
+	options := func(form string) (audio.Options, bool) {
+		return s.audioOptions(formContext(form))
+	}
+
+	// The configured filter may be custom
+	opts, ok := options("")
+	require.True(t, ok)
+	assert.Equal(t, "custom:highpass=f=300", opts.Filter)
+
+	opts, ok = options("audio_filter=highpass,denoise")
+	require.True(t, ok)
+	assert.Equal(t, "highpass,denoise", opts.Filter)
+
+	_, ok = options("audio_filter=custom:amovie=/etc/passwd")
+	assert.False(t, ok)
+}
+
+func TestRequestVAD(t *testing.T) {
+	configured := audio.DefaultVADOptions()
+	configured.EnergyMargin = 6
+	s := NewServer(Config{VAD: configured})
+
+	vad, ok := s.requestVAD(formContext("vad_margin=3"))
+	require.True(t, ok)
+	assert.Nil(t, vad, "disabled without the vad field")
+
+	vad, ok = s.requestVAD(formContext("vad=true"))
+	require.True(t, ok)
+	assert.Equal(t, configured, *vad)
+
+	vad, ok = s.requestVAD(formContext("vad=true&vad_margin=3&vad_min_energy=-40&vad_padding=50ms&vad_min_silence=1s"))
+	require.True(t, ok)
+	assert.Equal(t, 3.0, vad.EnergyMargin)
+	assert.Equal(t, -40.0, vad.MinEnergy)
+	assert.Equal(t, 50*time.Millisecond, vad.Padding)
+	assert.Equal(t, time.Second, vad.MinSilence)
+
+	for _, form := range []string{"vad=true&vad_margin=loud", "vad=true&vad_padding=50", "vad=true&vad_margin=-1"} {
+		_, ok := s.requestVAD(formContext(form))
+		assert.False(t, ok, form)
+	}
+
+	// Without configuration the defaults apply
+	vad, ok = NewServer(Config{}).requestVAD(formContext("vad=true"))
+	require.True(t, ok)
+	assert.Equal(t, audio.DefaultVADOptions(), *vad)
+}
+
+func TestHandleTranscribe_InvalidRequest(t *testing.T) {
+	s := NewServer(Config{})
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"unknown channels", map[string]string{"channels": "center"}},
+		{"split and multilingual", map[string]string{"channels": "split", "multilingual": "true"}},
+		{"invalid tempo", map[string]string{"tempo": "10"}},
+		{"invalid vad margin", map[string]string{"vad": "true", "vad_margin": "-3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, response := postForm(t, s.handleTranscribe, test.fields, []byte("RIFF"))
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, "invalid_request", response["code"])
+		})
+	}
+}
+
+// toneWAV builds a 16 kHz mono WAV of silence, a second of a 300 Hz tone and
+// silence again, each a second long
+func toneWAV() []byte {
+	samples := make([]int16, 3*audio.SampleRate)
+	for i := audio.SampleRate; i < 2*audio.SampleRate; i++ {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*300*float64(i)/audio.SampleRate))
+	}
+
+	var wav bytes.Buffer
+	size := uint32(len(samples) * 2)
+	wav.WriteString("RIFF")
+	binary.Write(&wav, binary.LittleEndian, 36+size)
+	wav.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{uint32(16), uint16(1), uint16(1), uint32(audio.SampleRate), uint32(audio.SampleRate * 2), uint16(2), uint16(16)} {
+		binary.Write(&wav, binary.LittleEndian, field)
+	}
+	wav.WriteString("data")
+	binary.Write(&wav, binary.LittleEndian, size)
+	binary.Write(&wav, binary.LittleEndian, samples)
+	return wav.Bytes()
+}
+
+func TestHandleProbe(t *testing.T) {
+	s := NewServer(Config{})
+
+	status, response := postForm(t, s.handleProbe, map[string]string{"audio_filter": "none"}, toneWAV())
+	require.Equal(t, http.StatusOK, status, response)
+	assert.Equal(t, "wav", response["container"])
+	assert.Equal(t, float64(audio.SampleRate), response["sample_rate"])
+	assert.Equal(t, float64(1), response["channels"])
+	assert.InDelta(t, 3, response["duration"], 0.01)
+	assert.InDelta(t, 1.0/3, response["speech_ratio"], 0.05)
+	assert.Len(t, response["tracks"], 1)
+
+	t.Run("invalid request", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			fields map[string]string
+			audio  []byte
+			status int
+			code   string
+		}{
+			{"custom filter", map[string]string{"audio_filter": "custom:anull"}, toneWAV(), http.StatusBadRequest, "invalid_request"},
+			{"missing audio", nil, nil, http.StatusBadRequest, "missing_audio"},
+			{"not audio", nil, []byte("hello, world"), http.StatusUnsupportedMediaType, "unsupported_format"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				status, response := postForm(t, s.handleProbe, test.fields, test.audio)
+				assert.Equal(t, test.status, status)
+				assert.Equal(t, test.code, response["code"])
+			})
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package transcriber
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// ChannelOptions controls transcription of separately recorded channels
+type ChannelOptions struct {
+	// Labels names the channels in order, e.g. the speakers, channels without
+	// a label are called "channel N"
+	Labels []string
+	// VAD transcribes only the speech of every channel when set
+	VAD *audio.VADOptions
+}
+
+// TranscribeChannels transcribes every channel on its own and interleaves the
+// segments by time, each labelled with the speaker of its channel
+func (t *FileTranscriber) TranscribeChannels(channels [][]float32, opts ChannelOptions) ([]whisper.Segment, error) {
+	segments := []whisper.Segment{}
+	for i, samples := range channels {
+		speaker := fmt.Sprintf("channel %d", i+1)
+		if i < len(opts.Labels) && opts.Labels[i] != "" {
+			speaker = opts.Labels[i]
+		}
+
+		var channelSegments []whisper.Segment
+		var err error
+		if opts.VAD != nil {
+			channelSegments, err = t.TranscribeSpeech(samples, *opts.VAD)
+		} else {
+			t.mu.Lock()
+			channelSegments, err = t.client.TranscribeSegments(samples, "")
+			t.mu.Unlock()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe %s: %w", speaker, err)
+		}
+
+		for _, segment := range channelSegments {
+			segment.Speaker = speaker
+			segments = append(segments, segment)
+		}
+	}
+
+	// Channels are ordered by speaker when segments start together
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, nil
+}
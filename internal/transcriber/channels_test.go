@@ -0,0 +1,45 @@
+package transcriber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTranscriber_TranscribeChannels(t *testing.T) {
+	agent := make([]float32, 1)
+	customer := make([]float32, 2)
+
+	client := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			// The channels are told apart by their length
+			if len(samples) == len(agent) {
+				return []whisper.Segment{
+					{Start: 0, End: 2 * time.Second, Text: "hello, how can I help"},
+					{Start: 5 * time.Second, End: 6 * time.Second, Text: "sure"},
+				}, nil
+			}
+			return []whisper.Segment{
+				{Start: 2 * time.Second, End: 5 * time.Second, Text: "I need a refund"},
+			}, nil
+		},
+	}
+
+	transcriber := NewFileTranscriberWithClient(client)
+	segments, err := transcriber.TranscribeChannels([][]float32{agent, customer, make([]float32, 3)}, ChannelOptions{
+		Labels: []string{"agent", "customer"},
+	})
+	require.NoError(t, err)
+
+	var speakers, texts []string
+	for _, segment := range segments {
+		speakers = append(speakers, segment.Speaker)
+		texts = append(texts, segment.Text)
+	}
+	// Segments starting together keep the channel order
+	assert.Equal(t, []string{"agent", "customer", "channel 3", "agent"}, speakers)
+	assert.Equal(t, []string{"hello, how can I help", "I need a refund", "I need a refund", "sure"}, texts)
+}
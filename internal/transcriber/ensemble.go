@@ -0,0 +1,325 @@
+package transcriber
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+const (
+	// roverAlpha weighs word frequency against confidence when voting
+	roverAlpha = 0.5
+	// roverNullConfidence is the confidence given to a model omitting a word
+	roverNullConfidence = 0.7
+	// nullWordKey groups the models omitting a word, it cannot match any text
+	nullWordKey = "\x00"
+)
+
+// EnsembleModel is a named model taking part in an ensemble
+type EnsembleModel struct {
+	Name string
+	Path string
+}
+
+// EnsembleWord is a word chosen by voting together with the model it came from
+type EnsembleWord struct {
+	Start      time.Duration
+	End        time.Duration
+	Text       string
+	Confidence float32
+	// Source is the model whose hypothesis of the word was used
+	Source string
+	// Votes is the number of models which recognised the word
+	Votes int
+}
+
+// ensembleWordJSON is the wire format of an ensemble word with timestamps in seconds
+type ensembleWordJSON struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float32 `json:"confidence"`
+	Source     string  `json:"source"`
+	Votes      int     `json:"votes"`
+}
+
+// MarshalJSON encodes the word with timestamps in seconds
+func (w EnsembleWord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ensembleWordJSON{
+		Start:      w.Start.Seconds(),
+		End:        w.End.Seconds(),
+		Text:       w.Text,
+		Confidence: w.Confidence,
+		Source:     w.Source,
+		Votes:      w.Votes,
+	})
+}
+
+// ensembleMember is a loaded model taking part in the vote
+type ensembleMember struct {
+	name   string
+	client whisperClient
+}
+
+// EnsembleTranscriber runs several models and combines their word sequences
+// by alignment and confidence-weighted voting (ROVER)
+type EnsembleTranscriber struct {
+	mu        sync.Mutex
+	members   []ensembleMember
+	audioOpts audio.Options
+}
+
+// NewEnsembleTranscriber loads every model of the ensemble
+func NewEnsembleTranscriber(models []EnsembleModel, language string, threads int) (*EnsembleTranscriber, error) {
+	if len(models) < 2 {
+		return nil, fmt.Errorf("an ensemble needs at least two models, got %d", len(models))
+	}
+
+	t := &EnsembleTranscriber{}
+	for _, model := range models {
+		client, err := whisper.NewClient(model.Path, language, threads)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("failed to create whisper client for %s: %w", model.Name, err)
+		}
+		// The hypotheses are aligned word by word
+		whisper.SetWordTimestamps(client, true)
+		t.members = append(t.members, ensembleMember{name: model.Name, client: client})
+	}
+
+	return t, nil
+}
+
+// SetAudioOptions sets how audio files are decoded, e.g. the preprocessing filter
+func (t *EnsembleTranscriber) SetAudioOptions(opts audio.Options) {
+	t.audioOpts = opts
+}
+
+// Close releases resources used by the transcriber
+func (t *EnsembleTranscriber) Close() {
+	for _, member := range t.members {
+		member.client.Close()
+	}
+}
+
+// Transcribe transcribes the audio file at the given path, the timestamps
+// refer to the file even when the audio options change the tempo
+func (t *EnsembleTranscriber) Transcribe(filePath string) ([]EnsembleWord, error) {
+	samples, err := audio.LoadAudioFileWithOptions(filePath, t.audioOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audio file: %w", err)
+	}
+
+	words, err := t.TranscribeFromSamples(samples)
+	if err != nil {
+		return nil, err
+	}
+	return originalWordTimeline(words, t.audioOpts), nil
+}
+
+// TranscribeFromSamples transcribes the samples with every model and votes on the words
+func (t *EnsembleTranscriber) TranscribeFromSamples(samples []float32) ([]EnsembleWord, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hypotheses := make([][]EnsembleWord, len(t.members))
+	for i, member := range t.members {
+		segments, err := member.client.TranscribeSegments(samples, "")
+		if err != nil {
+			return nil, fmt.Errorf("transcription with %s failed: %w", member.name, err)
+		}
+		hypotheses[i] = segmentsToWords(segments, member.name)
+	}
+
+	return rover(hypotheses), nil
+}
+
+// JoinWords builds the plain transcript text from ensemble words
+func JoinWords(words []EnsembleWord) string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// segmentsToWords flattens the words of all segments of one model
+func segmentsToWords(segments []whisper.Segment, source string) []EnsembleWord {
+	var words []EnsembleWord
+	for _, segment := range segments {
+		for _, word := range segment.Words {
+			words = append(words, EnsembleWord{
+				Start:      word.Start,
+				End:        word.End,
+				Text:       word.Text,
+				Confidence: word.Confidence,
+				Source:     source,
+			})
+		}
+	}
+	return words
+}
+
+// slot is one position of the word transition network, holding the word
+// each model put there or nil when the model has no word at this position
+type slot []*EnsembleWord
+
+// rover aligns the hypotheses into a word transition network and picks the
+// best scoring word (or no word) in every slot
+func rover(hypotheses [][]EnsembleWord) []EnsembleWord {
+	var network []slot
+	for i, hypothesis := range hypotheses {
+		network = alignHypothesis(network, hypothesis, i)
+	}
+
+	var words []EnsembleWord
+	for _, s := range network {
+		if word, ok := vote(s, len(hypotheses)); ok {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// alignHypothesis adds the words of model number system to the network using
+// a minimum edit distance alignment
+func alignHypothesis(network []slot, hypothesis []EnsembleWord, system int) []slot {
+	n, m := len(network), len(hypothesis)
+
+	// cost[i][j] is the cost of aligning the first i slots with the first j words
+	cost := make([][]int, n+1)
+	for i := range cost {
+		cost[i] = make([]int, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		cost[i][0] = cost[i-1][0] + skipCost(network[i-1])
+	}
+	for j := 1; j <= m; j++ {
+		cost[0][j] = j
+	}
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best := cost[i-1][j-1] + matchCost(network[i-1], hypothesis[j-1])
+			if c := cost[i-1][j] + skipCost(network[i-1]); c < best {
+				best = c
+			}
+			if c := cost[i][j-1] + 1; c < best {
+				best = c
+			}
+			cost[i][j] = best
+		}
+	}
+
+	// Walk back through the alignment building the new network from the end
+	var aligned []slot
+	i, j := n, m
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+matchCost(network[i-1], hypothesis[j-1]):
+			aligned = append(aligned, append(network[i-1], &hypothesis[j-1]))
+			i--
+			j--
+		case i > 0 && cost[i][j] == cost[i-1][j]+skipCost(network[i-1]):
+			aligned = append(aligned, append(network[i-1], nil))
+			i--
+		default:
+			// A word no earlier model produced gets a slot of its own
+			inserted := make(slot, system, system+1)
+			aligned = append(aligned, append(inserted, &hypothesis[j-1]))
+			j--
+		}
+	}
+
+	for l, r := 0, len(aligned)-1; l < r; l, r = l+1, r-1 {
+		aligned[l], aligned[r] = aligned[r], aligned[l]
+	}
+	return aligned
+}
+
+// matchCost is zero when some model already has the same word in the slot
+func matchCost(s slot, word EnsembleWord) int {
+	key := normalizeWord(word.Text)
+	for _, w := range s {
+		if w != nil && normalizeWord(w.Text) == key {
+			return 0
+		}
+	}
+	return 1
+}
+
+// skipCost is zero when some model already has no word in the slot
+func skipCost(s slot) int {
+	for _, w := range s {
+		if w == nil {
+			return 0
+		}
+	}
+	return 1
+}
+
+// vote scores every candidate of a slot by how many models agree and how
+// confident they are, returning false when leaving the word out wins
+func vote(s slot, systems int) (EnsembleWord, bool) {
+	type candidate struct {
+		best       *EnsembleWord
+		votes      int
+		confidence float32
+	}
+
+	var candidates []*candidate
+	byKey := make(map[string]*candidate)
+	for _, w := range s {
+		key, confidence := nullWordKey, float32(roverNullConfidence)
+		if w != nil {
+			key, confidence = normalizeWord(w.Text), w.Confidence
+		}
+
+		c, ok := byKey[key]
+		if !ok {
+			c = &candidate{}
+			byKey[key] = c
+			candidates = append(candidates, c)
+		}
+		c.votes++
+		if w != nil && (c.best == nil || w.Confidence > c.best.Confidence) {
+			c.best = w
+		}
+		if confidence > c.confidence {
+			c.confidence = confidence
+		}
+	}
+
+	var winner *candidate
+	var winnerScore float32
+	for _, c := range candidates {
+		score := roverAlpha*float32(c.votes)/float32(systems) + (1-roverAlpha)*c.confidence
+		if winner == nil || score > winnerScore {
+			winner, winnerScore = c, score
+		}
+	}
+
+	if winner.best == nil {
+		return EnsembleWord{}, false
+	}
+
+	word := *winner.best
+	word.Votes = winner.votes
+	return word, true
+}
+
+// normalizeWord lowercases a word and strips punctuation for comparison
+func normalizeWord(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
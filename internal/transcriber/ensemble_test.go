@@ -0,0 +1,87 @@
+package transcriber
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsembleTranscriber_TranscribeFromSamples(t *testing.T) {
+	member := func(name, text string, confidence float32) ensembleMember {
+		return ensembleMember{
+			name: name,
+			client: &mockWhisperClient{
+				transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+					var words []whisper.Word
+					for _, w := range strings.Fields(text) {
+						words = append(words, whisper.Word{Text: w, Confidence: confidence})
+					}
+					return []whisper.Segment{{Text: text, Words: words}}, nil
+				},
+			},
+		}
+	}
+
+	transcriber := &EnsembleTranscriber{
+		members: []ensembleMember{
+			member("small", "the cat sat on mat", 0.6),
+			member("medium", "a cat sat on the mat", 0.7),
+			member("large", "the cat sat on the mat.", 0.9),
+		},
+	}
+
+	words, err := transcriber.TranscribeFromSamples([]float32{0.1})
+	require.NoError(t, err)
+
+	assert.Equal(t, "the cat sat on the mat.", JoinWords(words))
+
+	// The most confident hypothesis of each winning word is used
+	sources := make([]string, len(words))
+	votes := make([]int, len(words))
+	for i, word := range words {
+		sources[i] = word.Source
+		votes[i] = word.Votes
+	}
+	assert.Equal(t, []string{"large", "large", "large", "large", "large", "large"}, sources)
+	assert.Equal(t, []int{2, 3, 3, 3, 2, 3}, votes)
+}
+
+func TestRover(t *testing.T) {
+	words := func(source string, confidence float32, texts ...string) []EnsembleWord {
+		var result []EnsembleWord
+		for _, text := range texts {
+			result = append(result, EnsembleWord{Text: text, Confidence: confidence, Source: source})
+		}
+		return result
+	}
+
+	t.Run("confident minority word wins a split vote", func(t *testing.T) {
+		result := rover([][]EnsembleWord{
+			words("a", 0.3, "hello", "word"),
+			words("b", 0.95, "hello", "world"),
+		})
+		assert.Equal(t, "hello world", JoinWords(result))
+		assert.Equal(t, "b", result[1].Source)
+	})
+
+	t.Run("word inserted by a single model is dropped", func(t *testing.T) {
+		result := rover([][]EnsembleWord{
+			words("a", 0.8, "good", "morning"),
+			words("b", 0.8, "good", "um", "morning"),
+			words("c", 0.8, "good", "morning"),
+		})
+		assert.Equal(t, "good morning", JoinWords(result))
+	})
+
+	t.Run("punctuation and case do not split votes", func(t *testing.T) {
+		result := rover([][]EnsembleWord{
+			words("a", 0.8, "Yes,"),
+			words("b", 0.9, "yes"),
+		})
+		require.Len(t, result, 1)
+		assert.Equal(t, 2, result[0].Votes)
+	})
+}
@@ -2,7 +2,9 @@ package transcriber
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/piotrjaromin/transcript/internal/audio"
 	"github.com/piotrjaromin/transcript/internal/whisper"
@@ -11,16 +13,23 @@ import (
 // whisperClient defines the interface for whisper clients
 type whisperClient interface {
 	Transcribe(samples []float32) (string, error)
+	TranscribeSegments(samples []float32, language string) ([]whisper.Segment, error)
+	DetectLanguage(samples []float32) ([]whisper.LanguageProbability, error)
 	Close()
 }
 
+// DefaultWindowDuration is the length of audio transcribed at once when streaming
+const DefaultWindowDuration = 10 * time.Minute
+
 // FileTranscriber handles transcription of audio files
 type FileTranscriber struct {
-	mu        sync.Mutex
-	modelPath string
-	language  string
-	client    whisperClient
-	newClient func(modelPath, language string, threads int) (whisperClient, error)
+	mu         sync.Mutex
+	modelPath  string
+	language   string
+	client     whisperClient
+	newClient  func(modelPath, language string, threads int) (whisperClient, error)
+	windowSize int
+	audioOpts  audio.Options
 }
 
 // NewFileTranscriber creates a new file transcriber
@@ -28,13 +37,13 @@ func NewFileTranscriber(modelPath, language string, threads int) (*FileTranscrib
 	clientFactory := func(modelPath, language string, threads int) (whisperClient, error) {
 		return whisper.NewClient(modelPath, language, threads)
 	}
-	
+
 	// Create a client that will be reused for all transcriptions
 	client, err := clientFactory(modelPath, language, threads)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create whisper client: %w", err)
 	}
-	
+
 	return &FileTranscriber{
 		modelPath: modelPath,
 		language:  language,
@@ -43,6 +52,19 @@ func NewFileTranscriber(modelPath, language string, threads int) (*FileTranscrib
 	}, nil
 }
 
+// NewFileTranscriberWithClient creates a file transcriber around an existing
+// client, e.g. a whisper.Router choosing the model by language
+func NewFileTranscriberWithClient(client whisperClient) *FileTranscriber {
+	return &FileTranscriber{
+		client: client,
+	}
+}
+
+// SetAudioOptions sets how audio files are decoded, e.g. the preprocessing filter
+func (t *FileTranscriber) SetAudioOptions(opts audio.Options) {
+	t.audioOpts = opts
+}
+
 // Close releases resources used by the transcriber
 func (t *FileTranscriber) Close() {
 	if t.client != nil {
@@ -58,22 +80,108 @@ func (t *FileTranscriber) TranscribeFromSamples(samples []float32) (string, erro
 	return t.client.Transcribe(samples)
 }
 
-// Transcribe transcribes the audio file at the given path
+// TranscribeWithLanguage transcribes audio samples in the language, which is
+// detected from the samples when it is empty or auto, and also returns the
+// language. With vad only the speech is transcribed, as by TranscribeSpeech,
+// and the language is detected from the speech.
+func (t *FileTranscriber) TranscribeWithLanguage(samples []float32, language string, vad *audio.VADOptions) ([]whisper.Segment, string, error) {
+	if language == "" || language == "auto" {
+		speech := samples
+		if vad != nil {
+			speech, _ = audio.PackSpeech(samples, audio.DetectSpeech(samples, *vad))
+		}
+		if len(speech) == 0 {
+			// There is nothing to detect the language of or to transcribe
+			return []whisper.Segment{}, "", nil
+		}
+
+		t.mu.Lock()
+		languages, err := t.client.DetectLanguage(speech)
+		t.mu.Unlock()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to detect language: %w", err)
+		}
+		if len(languages) == 0 {
+			return nil, "", fmt.Errorf("failed to detect language: no language found")
+		}
+		language = languages[0].Language
+	}
+
+	if vad != nil {
+		segments, err := t.transcribeSpeech(samples, language, *vad)
+		return segments, language, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	segments, err := t.client.TranscribeSegments(samples, language)
+	return segments, language, err
+}
+
+// Transcribe transcribes the audio file at the given path. The file is
+// decoded as a stream so memory use does not grow with the file size.
 func (t *FileTranscriber) Transcribe(filePath string) (string, error) {
-	// Load the audio file
-	samples, err := audio.LoadAudioFile(filePath)
+	stream, err := audio.OpenStream(filePath, audio.DefaultFrameSize, t.audioOpts)
 	if err != nil {
 		return "", fmt.Errorf("failed to load audio file: %w", err)
 	}
+	defer stream.Close()
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	
-	// Use the existing client
-	transcript, err := t.client.Transcribe(samples)
+	transcript, err := t.TranscribeStream(stream.Frames())
 	if err != nil {
-		return "", fmt.Errorf("failed to transcribe audio: %w", err)
+		return "", err
+	}
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("failed to load audio file: %w", err)
 	}
 
 	return transcript, nil
 }
+
+// TranscribeStream transcribes decoded frames window by window, cutting at
+// quiet points, so only about one window of audio is held in memory
+func (t *FileTranscriber) TranscribeStream(frames <-chan []float32) (string, error) {
+	window := t.windowSize
+	if window <= 0 {
+		window = durationToSamples(DefaultWindowDuration)
+	}
+	search := window / 4
+	if search > audio.SampleRate {
+		search = audio.SampleRate
+	}
+
+	var parts []string
+	transcribe := func(samples []float32) error {
+		text, err := t.TranscribeFromSamples(samples)
+		if err != nil {
+			return fmt.Errorf("failed to transcribe audio: %w", err)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
+		return nil
+	}
+
+	var buf []float32
+	for frame := range frames {
+		buf = append(buf, frame...)
+		if len(buf) < window+search {
+			continue
+		}
+
+		cut := quietestCut(buf, window, search)
+		if err := transcribe(buf[:cut]); err != nil {
+			return "", err
+		}
+		// Copy the remainder so the transcribed window can be released
+		buf = append([]float32(nil), buf[cut:]...)
+	}
+
+	if len(buf) > 0 {
+		if err := transcribe(buf); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
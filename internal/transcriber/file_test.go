@@ -2,7 +2,10 @@ package transcriber
 
 import (
 	"testing"
+	"time"
 
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -31,16 +34,101 @@ func TestFileTranscriber_TranscribeFromSamples(t *testing.T) {
 }
 
 type mockWhisperClient struct {
-	transcribeFunc func([]float32) (string, error)
-	closeFunc      func()
+	transcribeFunc         func([]float32) (string, error)
+	transcribeSegmentsFunc func([]float32, string) ([]whisper.Segment, error)
+	detectLanguageFunc     func([]float32) ([]whisper.LanguageProbability, error)
+	closeFunc              func()
 }
 
 func (m *mockWhisperClient) Transcribe(samples []float32) (string, error) {
 	return m.transcribeFunc(samples)
 }
 
+func (m *mockWhisperClient) TranscribeSegments(samples []float32, language string) ([]whisper.Segment, error) {
+	return m.transcribeSegmentsFunc(samples, language)
+}
+
+func (m *mockWhisperClient) DetectLanguage(samples []float32) ([]whisper.LanguageProbability, error) {
+	return m.detectLanguageFunc(samples)
+}
+
 func (m *mockWhisperClient) Close() {
 	if m.closeFunc != nil {
 		m.closeFunc()
 	}
 }
+
+func TestFileTranscriber_TranscribeStream(t *testing.T) {
+	var windows []int
+	mockClient := &mockWhisperClient{
+		transcribeFunc: func(samples []float32) (string, error) {
+			windows = append(windows, len(samples))
+			return " part ", nil
+		},
+	}
+
+	window := durationToSamples(4 * time.Second)
+	transcriber := &FileTranscriber{
+		client:     mockClient,
+		windowSize: window,
+	}
+
+	// Ten seconds of audio delivered in one second frames
+	frames := make(chan []float32, 10)
+	for i := 0; i < 10; i++ {
+		frame := make([]float32, durationToSamples(time.Second))
+		for j := range frame {
+			frame[j] = 0.5
+		}
+		frames <- frame
+	}
+	close(frames)
+
+	transcript, err := transcriber.TranscribeStream(frames)
+	require.NoError(t, err)
+	assert.Equal(t, "part part part", transcript)
+	assert.Equal(t, []int{window, window, durationToSamples(2 * time.Second)}, windows)
+}
+
+func TestFileTranscriber_TranscribeWithLanguage(t *testing.T) {
+	var detected int
+	var transcribedIn []string
+	mockClient := &mockWhisperClient{
+		detectLanguageFunc: func(samples []float32) ([]whisper.LanguageProbability, error) {
+			detected++
+			return []whisper.LanguageProbability{{Language: "de", Probability: 0.9}, {Language: "en", Probability: 0.1}}, nil
+		},
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			transcribedIn = append(transcribedIn, language)
+			return []whisper.Segment{{Start: 0, End: time.Second, Text: "hallo", Language: language}}, nil
+		},
+	}
+	transcriber := NewFileTranscriberWithClient(mockClient)
+	samples := make([]float32, durationToSamples(time.Second))
+	for i := range samples {
+		samples[i] = 0.5
+	}
+
+	// The language is detected and the samples are transcribed in it
+	segments, language, err := transcriber.TranscribeWithLanguage(samples, "auto", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "de", language)
+	assert.Equal(t, "hallo", whisper.JoinSegments(segments))
+	assert.Equal(t, []string{"de"}, transcribedIn)
+	assert.Equal(t, 1, detected)
+
+	// A set language is used as it is
+	_, language, err = transcriber.TranscribeWithLanguage(samples, "pl", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pl", language)
+	assert.Equal(t, []string{"de", "pl"}, transcribedIn)
+	assert.Equal(t, 1, detected)
+
+	// Silence has no speech to detect the language of
+	opts := audio.DefaultVADOptions()
+	segments, language, err = transcriber.TranscribeWithLanguage(make([]float32, audio.SampleRate), "", &opts)
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+	assert.Empty(t, language)
+	assert.Equal(t, 1, detected)
+}
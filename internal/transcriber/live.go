@@ -0,0 +1,306 @@
+package transcriber
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// LiveOptions tunes live transcription
+type LiveOptions struct {
+	// Step is how much new audio is awaited before the audio is transcribed
+	// again and the caption updated
+	Step time.Duration
+	// MaxWindow is the most audio transcribed at once, when the uncommitted
+	// audio grows longer its text is committed as it is
+	MaxWindow time.Duration
+}
+
+// DefaultLiveOptions returns settings which update the caption every second
+func DefaultLiveOptions() LiveOptions {
+	return LiveOptions{
+		Step:      time.Second,
+		MaxWindow: 20 * time.Second,
+	}
+}
+
+const (
+	// liveOverlapWords is how many of the last committed words are looked for
+	// at the start of a new hypothesis, which repeats them when the window
+	// still holds their audio
+	liveOverlapWords = 5
+	// liveTimeTolerance is how far before the committed end a word may start
+	// and still count as new, word timestamps are not exact
+	liveTimeTolerance = 100 * time.Millisecond
+	// liveSilenceKeep is how much of a window without speech is kept, so a
+	// word starting at its end is not cut
+	liveSilenceKeep = 500 * time.Millisecond
+	// liveSentenceGap is the pause after which committed words start a new segment
+	liveSentenceGap = time.Second
+)
+
+// Caption is the text of a live transcription so far
+type Caption struct {
+	// Committed is the text which no longer changes
+	Committed string
+	// Tentative is the text of the latest audio, which may still change as
+	// more audio arrives
+	Tentative string
+}
+
+// TranscribeLive transcribes audio while it is recorded. The uncommitted
+// audio is transcribed again whenever Step more has arrived, and the words on
+// which two passes in a row agree are committed; update is called with the
+// caption after every pass, it may be nil. When frames is closed the rest is transcribed
+// and committed, and all committed text is returned as segments timed from
+// the start of the audio. Once ctx is done no more passes or updates are
+// made and its error is returned, e.g. when the recording was discarded.
+func (t *FileTranscriber) TranscribeLive(ctx context.Context, frames <-chan []float32, opts LiveOptions, update func(Caption)) ([]whisper.Segment, error) {
+	if opts.Step <= 0 {
+		opts.Step = DefaultLiveOptions().Step
+	}
+	if opts.MaxWindow < opts.Step {
+		opts.MaxWindow = max(DefaultLiveOptions().MaxWindow, opts.Step)
+	}
+
+	// Words are committed by their timing
+	whisper.SetWordTimestamps(t.client, true)
+	defer whisper.SetWordTimestamps(t.client, false)
+
+	// Frames are collected in the background so the producer never waits
+	// for a transcription pass
+	var mu sync.Mutex
+	var pending []float32
+	closed := false
+	arrived := make(chan struct{}, 1)
+	go func() {
+		for frame := range frames {
+			mu.Lock()
+			pending = append(pending, frame...)
+			mu.Unlock()
+			select {
+			case arrived <- struct{}{}:
+			default:
+			}
+		}
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+		close(arrived)
+	}()
+
+	live := &liveState{transcriber: t, opts: opts}
+	step := durationToSamples(opts.Step)
+	fresh := 0
+	for {
+		var open bool
+		select {
+		case _, open = <-arrived:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		live.buffer = append(live.buffer, pending...)
+		fresh += len(pending)
+		pending = nil
+		done := closed
+		mu.Unlock()
+
+		if done || !open {
+			if err := live.pass(true); err != nil {
+				return nil, err
+			}
+			if update != nil {
+				update(live.caption())
+			}
+			return live.segments(), nil
+		}
+		if fresh < step {
+			continue
+		}
+		fresh = 0
+		if err := live.pass(false); err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+		if update != nil {
+			update(live.caption())
+		}
+	}
+}
+
+// liveState is the progress of a live transcription
+type liveState struct {
+	transcriber *FileTranscriber
+	opts        LiveOptions
+
+	// buffer holds the uncommitted audio, which starts offset samples into
+	// the recording
+	buffer []float32
+	offset int
+
+	committed    []whisper.Word
+	committedEnd time.Duration
+	// tentative is the uncommitted part of the latest hypothesis
+	tentative []whisper.Word
+}
+
+// pass transcribes the buffer and commits what two passes agree on, or
+// everything for the final pass
+func (l *liveState) pass(final bool) error {
+	vad := audio.DefaultVADOptions()
+	if len(audio.DetectSpeech(l.buffer, vad)) == 0 {
+		// Transcribing silence only produces hallucinations
+		if final {
+			l.commit(l.tentative)
+		} else {
+			l.trim(max(len(l.buffer)-durationToSamples(liveSilenceKeep), 0))
+		}
+		l.tentative = nil
+		return nil
+	}
+
+	l.transcriber.mu.Lock()
+	segments, err := l.transcriber.client.TranscribeSegments(l.buffer, "")
+	l.transcriber.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	hypothesis := l.newWords(segments)
+
+	if final {
+		l.commit(hypothesis)
+		l.tentative = nil
+		l.trim(len(l.buffer))
+		return nil
+	}
+
+	agreed := 0
+	for agreed < len(hypothesis) && agreed < len(l.tentative) &&
+		normalizeWord(hypothesis[agreed].Text) == normalizeWord(l.tentative[agreed].Text) {
+		agreed++
+	}
+	l.commit(hypothesis[:agreed])
+	l.tentative = hypothesis[agreed:]
+
+	if samplesToDuration(len(l.buffer)) >= l.opts.MaxWindow {
+		// No agreement within the window, the latest text is the best there is
+		l.commit(l.tentative)
+		l.tentative = nil
+		l.trim(len(l.buffer))
+		return nil
+	}
+	if samplesToDuration(len(l.buffer)) > l.opts.MaxWindow/2 {
+		// Later passes start at the committed end, keeping the window short
+		l.trim(durationToSamples(l.committedEnd) - l.offset)
+	}
+	return nil
+}
+
+// newWords returns the words of the segments on the recording's timeline,
+// without those already committed
+func (l *liveState) newWords(segments []whisper.Segment) []whisper.Word {
+	shift := samplesToDuration(l.offset)
+	var words []whisper.Word
+	for _, segment := range segments {
+		for _, word := range segment.Words {
+			word.Start += shift
+			word.End += shift
+			if word.Text == "" || word.Start < l.committedEnd-liveTimeTolerance {
+				continue
+			}
+			words = append(words, word)
+		}
+	}
+
+	// The window may still hold the audio of the last committed words
+	for n := min(liveOverlapWords, len(l.committed), len(words)); n > 0; n-- {
+		if sameWords(l.committed[len(l.committed)-n:], words[:n]) {
+			return words[n:]
+		}
+	}
+	return words
+}
+
+// sameWords reports whether the words read the same, ignoring case and punctuation
+func sameWords(a, b []whisper.Word) bool {
+	for i := range a {
+		if normalizeWord(a[i].Text) != normalizeWord(b[i].Text) {
+			return false
+		}
+	}
+	return true
+}
+
+// commit appends the words to the committed text
+func (l *liveState) commit(words []whisper.Word) {
+	if len(words) == 0 {
+		return
+	}
+	l.committed = append(l.committed, words...)
+	l.committedEnd = max(l.committedEnd, words[len(words)-1].End)
+}
+
+// trim drops the first n samples of the buffer
+func (l *liveState) trim(n int) {
+	n = min(max(n, 0), len(l.buffer))
+	l.buffer = append([]float32(nil), l.buffer[n:]...)
+	l.offset += n
+}
+
+// caption returns the committed and tentative text
+func (l *liveState) caption() Caption {
+	return Caption{Committed: joinWords(l.committed), Tentative: joinWords(l.tentative)}
+}
+
+// segments groups the committed words into segments, a sentence ending or a
+// long pause starts a new one
+func (l *liveState) segments() []whisper.Segment {
+	segments := []whisper.Segment{}
+	var current []whisper.Word
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		segment := whisper.Segment{
+			Start: current[0].Start,
+			End:   current[len(current)-1].End,
+			Text:  joinWords(current),
+			Words: current,
+		}
+		for _, word := range current {
+			segment.Confidence += word.Confidence / float32(len(current))
+		}
+		segments = append(segments, segment)
+		current = nil
+	}
+
+	for i, word := range l.committed {
+		if i > 0 && word.Start-l.committed[i-1].End > liveSentenceGap {
+			flush()
+		}
+		current = append(current, word)
+		if strings.ContainsAny(word.Text[len(word.Text)-1:], ".?!") {
+			flush()
+		}
+	}
+	flush()
+	return segments
+}
+
+// joinWords builds the text of the words
+func joinWords(words []whisper.Word) string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,165 @@
+package transcriber
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// liveRecording is noise with a burst of tone for every word, word i spoken
+// from 0.5s+i*0.5s for 0.4s
+func liveRecording(words int) []float32 {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]float32, durationToSamples(time.Second+time.Duration(words)*500*time.Millisecond))
+	for i := range samples {
+		samples[i] = float32(rng.NormFloat64() * 0.001)
+	}
+	for i := 0; i < words; i++ {
+		start := durationToSamples(liveWordStart(i))
+		for j := 0; j < durationToSamples(400*time.Millisecond); j++ {
+			samples[start+j] += float32(0.2 * math.Sin(2*math.Pi*300*float64(j)/audio.SampleRate))
+		}
+	}
+	return samples
+}
+
+func liveWordStart(i int) time.Duration {
+	return 500*time.Millisecond + time.Duration(i)*500*time.Millisecond
+}
+
+// liveClient transcribes windows of the recording: the words whose audio the
+// window holds, those near its end misheard differently on every pass
+func liveClient(t *testing.T, recording []float32, count int, passes *int) *mockWhisperClient {
+	return &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			*passes++
+			// The window is found in the recording by its noise
+			offset := -1
+			for i := 0; i+16 <= len(recording); i++ {
+				if assert.ObjectsAreEqual(recording[i:i+16], samples[:16]) {
+					offset = i
+					break
+				}
+			}
+			require.GreaterOrEqual(t, offset, 0)
+			start, end := samplesToDuration(offset), samplesToDuration(offset+len(samples))
+
+			var words []whisper.Word
+			for i := 0; i < count && liveWordStart(i) < end; i++ {
+				wordStart, wordEnd := liveWordStart(i), liveWordStart(i)+400*time.Millisecond
+				if wordStart < start {
+					continue
+				}
+				text := fmt.Sprintf("w%d", i)
+				if wordEnd > end-500*time.Millisecond {
+					text = fmt.Sprintf("maybe%d", *passes)
+				}
+				words = append(words, whisper.Word{Text: text, Start: wordStart - start, End: wordEnd - start, Confidence: 0.9})
+			}
+			return []whisper.Segment{{Words: words}}, nil
+		},
+	}
+}
+
+func TestTranscribeLive(t *testing.T) {
+	const count = 12
+	recording := liveRecording(count)
+	passes := 0
+	transcriber := NewFileTranscriberWithClient(liveClient(t, recording, count, &passes))
+
+	// The audio arrives in real time: a second of it per pass
+	frames := make(chan []float32)
+	passed := make(chan struct{}, 1)
+	go func() {
+		for i := 0; i < len(recording); i += 1600 {
+			frames <- recording[i:min(i+1600, len(recording))]
+			if (i/1600)%10 == 9 {
+				<-passed
+			}
+		}
+		close(frames)
+	}()
+
+	var captions []Caption
+	segments, err := transcriber.TranscribeLive(context.Background(), frames, LiveOptions{Step: time.Second, MaxWindow: 4 * time.Second}, func(caption Caption) {
+		captions = append(captions, caption)
+		select {
+		case passed <- struct{}{}:
+		default:
+		}
+	})
+	require.NoError(t, err)
+
+	var expected []string
+	for i := 0; i < count; i++ {
+		expected = append(expected, fmt.Sprintf("w%d", i))
+	}
+	require.Len(t, segments, 1)
+	assert.Equal(t, strings.Join(expected, " "), segments[0].Text)
+	assert.Equal(t, liveWordStart(0), segments[0].Start)
+	assert.Equal(t, liveWordStart(count-1)+400*time.Millisecond, segments[0].End)
+
+	// Committed text only grows, the misheard words are never committed
+	require.NotEmpty(t, captions)
+	previous := ""
+	for _, caption := range captions {
+		assert.True(t, strings.HasPrefix(caption.Committed, previous), "%q does not continue %q", caption.Committed, previous)
+		assert.NotContains(t, caption.Committed, "maybe")
+		previous = caption.Committed
+	}
+	assert.Greater(t, passes, 1)
+}
+
+func TestTranscribeLiveDiscarded(t *testing.T) {
+	const count = 4
+	recording := liveRecording(count)
+	passes := 0
+	transcriber := NewFileTranscriberWithClient(liveClient(t, recording, count, &passes))
+
+	// The recording is discarded before it ends, too short for a pass
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := make(chan []float32)
+	go func() {
+		for i := 0; i < len(recording); i += 1600 {
+			frames <- recording[i:min(i+1600, len(recording))]
+		}
+		cancel()
+		close(frames)
+	}()
+
+	segments, err := transcriber.TranscribeLive(ctx, frames, LiveOptions{Step: time.Minute}, func(caption Caption) {
+		t.Errorf("discarded recording captioned: %+v", caption)
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, segments)
+	assert.Zero(t, passes)
+}
+
+func TestTranscribeLiveSilence(t *testing.T) {
+	client := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			t.Fatal("silence was transcribed")
+			return nil, nil
+		},
+	}
+	transcriber := NewFileTranscriberWithClient(client)
+
+	frames := make(chan []float32, 30)
+	for i := 0; i < 30; i++ {
+		frames <- make([]float32, 1600)
+	}
+	close(frames)
+
+	segments, err := transcriber.TranscribeLive(context.Background(), frames, DefaultLiveOptions(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, segments)
+}
@@ -0,0 +1,163 @@
+package transcriber
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// DefaultChunkDuration is the length of audio classified at once in multilingual mode
+const DefaultChunkDuration = 10 * time.Second
+
+// MultilingualOptions controls transcription of code-switched audio
+type MultilingualOptions struct {
+	// ChunkDuration is the length of the audio chunks whose language is detected
+	ChunkDuration time.Duration
+	// Languages restricts detection to the listed languages, empty allows any.
+	// Audio starting in none of them is taken to be in the first one.
+	Languages []string
+}
+
+// languageRun is a stretch of audio detected to be in a single language
+type languageRun struct {
+	start, end int
+	language   string
+}
+
+// TranscribeMultilingual transcribes audio which switches between languages.
+// The language is detected per chunk, consecutive chunks in the same
+// language are decoded together and every segment records its language.
+func (t *FileTranscriber) TranscribeMultilingual(samples []float32, opts MultilingualOptions) ([]whisper.Segment, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no audio data to transcribe")
+	}
+
+	chunkSize := durationToSamples(opts.ChunkDuration)
+	if chunkSize <= 0 {
+		chunkSize = durationToSamples(DefaultChunkDuration)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var runs []languageRun
+	bounds := chunkBoundaries(samples, chunkSize)
+	for i := 0; i < len(bounds)-1; i++ {
+		start, end := bounds[i], bounds[i+1]
+
+		probs, err := t.client.DetectLanguage(samples[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect language at %s: %w", samplesToDuration(start), err)
+		}
+
+		language := pickLanguage(probs, opts.Languages)
+		if language == "" {
+			// None of the allowed languages were recognised, assume no switch
+			// or, at the start, the first allowed language
+			if len(runs) > 0 {
+				language = runs[len(runs)-1].language
+			} else {
+				language = opts.Languages[0]
+			}
+		}
+
+		if len(runs) > 0 && runs[len(runs)-1].language == language {
+			runs[len(runs)-1].end = end
+			continue
+		}
+		runs = append(runs, languageRun{start: start, end: end, language: language})
+	}
+
+	var segments []whisper.Segment
+	for _, run := range runs {
+		runSegments, err := t.client.TranscribeSegments(samples[run.start:run.end], run.language)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe audio at %s: %w", samplesToDuration(run.start), err)
+		}
+
+		// Map the timestamps back onto the timeline of the whole input
+		offset := samplesToDuration(run.start)
+		for _, segment := range runSegments {
+			segment.Start += offset
+			segment.End += offset
+			segment.Language = run.language
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments, nil
+}
+
+// pickLanguage returns the most likely language from the allowed set,
+// or an empty string when none of them were detected
+func pickLanguage(probs []whisper.LanguageProbability, allowed []string) string {
+	for _, prob := range probs {
+		if len(allowed) == 0 {
+			return prob.Language
+		}
+		for _, lang := range allowed {
+			if prob.Language == lang {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// chunkBoundaries splits the samples into chunks of roughly chunkSize. Cuts
+// are moved to the quietest nearby frame so words are not split in half and
+// a short remainder is merged into the last chunk.
+func chunkBoundaries(samples []float32, chunkSize int) []int {
+	bounds := []int{0}
+	search := chunkSize / 4
+	if search > audio.SampleRate {
+		search = audio.SampleRate
+	}
+
+	for {
+		last := bounds[len(bounds)-1]
+		if len(samples)-last < chunkSize+chunkSize/2 {
+			break
+		}
+
+		bounds = append(bounds, quietestCut(samples, last+chunkSize, search))
+	}
+
+	return append(bounds, len(samples))
+}
+
+// quietestCut returns the start of the quietest 20ms frame within search
+// samples of cut, keeping cut itself unless a strictly quieter frame is found.
+// The samples must extend at least search samples past cut.
+func quietestCut(samples []float32, cut, search int) int {
+	const frameSize = audio.SampleRate / 50 // 20ms
+
+	best, bestEnergy := cut, frameEnergy(samples[cut:cut+frameSize])
+	for pos := cut - search; pos+frameSize <= cut+search; pos += frameSize {
+		if energy := frameEnergy(samples[pos : pos+frameSize]); energy < bestEnergy {
+			best, bestEnergy = pos, energy
+		}
+	}
+	return best
+}
+
+// frameEnergy returns the sum of squares of the samples
+func frameEnergy(frame []float32) float64 {
+	var energy float64
+	for _, s := range frame {
+		energy += float64(s) * float64(s)
+	}
+	return energy
+}
+
+// durationToSamples converts a duration to a number of samples at whisper's rate
+func durationToSamples(d time.Duration) int {
+	return int(d * audio.SampleRate / time.Second)
+}
+
+// samplesToDuration converts a number of samples at whisper's rate to a duration
+func samplesToDuration(n int) time.Duration {
+	return time.Duration(n) * time.Second / audio.SampleRate
+}
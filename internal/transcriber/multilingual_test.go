@@ -0,0 +1,95 @@
+package transcriber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTranscriber_TranscribeMultilingual(t *testing.T) {
+	chunk := durationToSamples(time.Second)
+
+	// Four one second chunks, marked by their quieter first sample: pl, pl, en, pl
+	samples := make([]float32, 4*chunk)
+	for i := range samples {
+		samples[i] = 0.5
+	}
+	marks := []float32{0.1, 0.1, 0.2, 0.1}
+	for i, mark := range marks {
+		samples[i*chunk] = mark
+	}
+
+	var decoded []string
+	mockClient := &mockWhisperClient{
+		detectLanguageFunc: func(samples []float32) ([]whisper.LanguageProbability, error) {
+			if samples[0] == 0.2 {
+				return []whisper.LanguageProbability{{Language: "de"}, {Language: "en"}, {Language: "pl"}}, nil
+			}
+			return []whisper.LanguageProbability{{Language: "pl"}, {Language: "en"}}, nil
+		},
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			decoded = append(decoded, language)
+			return []whisper.Segment{{Start: 0, End: samplesToDuration(len(samples)), Text: language}}, nil
+		},
+	}
+
+	transcriber := NewFileTranscriberWithClient(mockClient)
+
+	segments, err := transcriber.TranscribeMultilingual(samples, MultilingualOptions{
+		ChunkDuration: time.Second,
+		Languages:     []string{"pl", "en"},
+	})
+	require.NoError(t, err)
+
+	// Consecutive chunks in the same language are decoded together
+	assert.Equal(t, []string{"pl", "en", "pl"}, decoded)
+	assert.Equal(t, []whisper.Segment{
+		{Start: 0, End: 2 * time.Second, Text: "pl", Language: "pl"},
+		{Start: 2 * time.Second, End: 3 * time.Second, Text: "en", Language: "en"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "pl", Language: "pl"},
+	}, segments)
+
+	t.Run("start in no allowed language uses the first one", func(t *testing.T) {
+		decoded = nil
+		_, err := transcriber.TranscribeMultilingual(samples, MultilingualOptions{
+			ChunkDuration: time.Second,
+			Languages:     []string{"fr", "de"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fr", "de"}, decoded)
+	})
+}
+
+func TestChunkBoundaries(t *testing.T) {
+	chunk := durationToSamples(4 * time.Second)
+
+	t.Run("cuts at the quietest frame", func(t *testing.T) {
+		samples := make([]float32, 2*chunk)
+		for i := range samples {
+			samples[i] = 0.5
+		}
+		// Silence slightly before the nominal cut
+		quiet := chunk - durationToSamples(500*time.Millisecond)
+		for i := quiet; i < quiet+durationToSamples(20*time.Millisecond); i++ {
+			samples[i] = 0
+		}
+
+		assert.Equal(t, []int{0, quiet, len(samples)}, chunkBoundaries(samples, chunk))
+	})
+
+	t.Run("short remainder is merged", func(t *testing.T) {
+		samples := make([]float32, chunk+chunk/3)
+		assert.Equal(t, []int{0, len(samples)}, chunkBoundaries(samples, chunk))
+	})
+}
+
+func TestPickLanguage(t *testing.T) {
+	probs := []whisper.LanguageProbability{{Language: "de"}, {Language: "en"}, {Language: "pl"}}
+
+	assert.Equal(t, "de", pickLanguage(probs, nil))
+	assert.Equal(t, "en", pickLanguage(probs, []string{"pl", "en"}))
+	assert.Equal(t, "", pickLanguage(probs, []string{"fr"}))
+}
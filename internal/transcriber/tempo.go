@@ -0,0 +1,32 @@
+package transcriber
+
+import (
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// OriginalTimeline maps the timestamps of segments transcribed from audio
+// decoded with the options back onto the timeline of the input, undoing a
+// tempo change. The segments are changed in place.
+func OriginalTimeline(segments []whisper.Segment, opts audio.Options) []whisper.Segment {
+	for i := range segments {
+		segment := &segments[i]
+		segment.Start = opts.OriginalTime(segment.Start)
+		segment.End = opts.OriginalTime(segment.End)
+		for j := range segment.Words {
+			word := &segment.Words[j]
+			word.Start = opts.OriginalTime(word.Start)
+			word.End = opts.OriginalTime(word.End)
+		}
+	}
+	return segments
+}
+
+// originalWordTimeline maps the timestamps of ensemble words like OriginalTimeline
+func originalWordTimeline(words []EnsembleWord, opts audio.Options) []EnsembleWord {
+	for i := range words {
+		words[i].Start = opts.OriginalTime(words[i].Start)
+		words[i].End = opts.OriginalTime(words[i].End)
+	}
+	return words
+}
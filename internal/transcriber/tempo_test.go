@@ -0,0 +1,24 @@
+package transcriber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginalTimeline(t *testing.T) {
+	segments := []whisper.Segment{
+		{Start: time.Second, End: 2 * time.Second, Text: "sped up", Words: []whisper.Word{
+			{Start: time.Second, End: 1500 * time.Millisecond, Text: "sped"},
+		}},
+	}
+
+	assert.Equal(t, []whisper.Segment{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "sped up", Words: []whisper.Word{
+			{Start: 1500 * time.Millisecond, End: 2250 * time.Millisecond, Text: "sped"},
+		}},
+	}, OriginalTimeline(segments, audio.Options{Tempo: 1.5}))
+}
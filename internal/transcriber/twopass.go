@@ -0,0 +1,189 @@
+package transcriber
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// DefaultRefineThreshold is the segment confidence below which segments are re-decoded
+const DefaultRefineThreshold = 0.6
+
+// minRefineDuration is the length of the shortest span which is re-decoded and
+// of the shortest segment spliced in, shorter ones are merged with a neighbour
+const minRefineDuration = 200 * time.Millisecond
+
+// TwoPassTranscriber transcribes with a fast draft model and re-decodes only
+// the segments it is unsure about with a larger refine model
+type TwoPassTranscriber struct {
+	mu        sync.Mutex
+	language  string
+	threshold float32
+	draft     whisperClient
+	refine    whisperClient
+	audioOpts audio.Options
+}
+
+// NewTwoPassTranscriber creates a new draft-then-refine transcriber
+func NewTwoPassTranscriber(draftModelPath, refineModelPath, language string, threads int, threshold float32) (*TwoPassTranscriber, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("refine threshold must be between 0 and 1, got %v", threshold)
+	}
+
+	draft, err := whisper.NewClient(draftModelPath, language, threads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create draft whisper client: %w", err)
+	}
+
+	refine, err := whisper.NewClient(refineModelPath, language, threads)
+	if err != nil {
+		draft.Close()
+		return nil, fmt.Errorf("failed to create refine whisper client: %w", err)
+	}
+
+	return &TwoPassTranscriber{
+		language:  language,
+		threshold: threshold,
+		draft:     draft,
+		refine:    refine,
+	}, nil
+}
+
+// SetAudioOptions sets how audio files are decoded, e.g. the preprocessing filter
+func (t *TwoPassTranscriber) SetAudioOptions(opts audio.Options) {
+	t.audioOpts = opts
+}
+
+// Close releases resources used by the transcriber
+func (t *TwoPassTranscriber) Close() {
+	if t.draft != nil {
+		t.draft.Close()
+	}
+	if t.refine != nil {
+		t.refine.Close()
+	}
+}
+
+// Transcribe transcribes the audio file at the given path, the timestamps
+// refer to the file even when the audio options change the tempo
+func (t *TwoPassTranscriber) Transcribe(filePath string) ([]whisper.Segment, error) {
+	samples, err := audio.LoadAudioFileWithOptions(filePath, t.audioOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audio file: %w", err)
+	}
+
+	segments, err := t.TranscribeFromSamples(samples)
+	if err != nil {
+		return nil, err
+	}
+	return OriginalTimeline(segments, t.audioOpts), nil
+}
+
+// TranscribeFromSamples drafts the whole audio and splices in re-decoded
+// versions of consecutive segments below the confidence threshold
+func (t *TwoPassTranscriber) TranscribeFromSamples(samples []float32) ([]whisper.Segment, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	drafted, err := t.draft.TranscribeSegments(samples, "")
+	if err != nil {
+		return nil, fmt.Errorf("draft transcription failed: %w", err)
+	}
+
+	var segments []whisper.Segment
+	for i := 0; i < len(drafted); {
+		if drafted[i].Confidence >= t.threshold {
+			segments = append(segments, drafted[i])
+			i++
+			continue
+		}
+
+		// Re-decode the whole run of uncertain segments to give the model context
+		end := i
+		for end+1 < len(drafted) && drafted[end+1].Confidence < t.threshold {
+			end++
+		}
+
+		refined, err := t.refineSpan(samples, drafted[i:end+1])
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, refined...)
+		i = end + 1
+	}
+
+	return segments, nil
+}
+
+// refineSpan re-decodes the audio of the drafted segments with the refine
+// model, mapping the results onto the original timeline. The draft is kept
+// when the span is too short to decode or nothing usable comes back.
+func (t *TwoPassTranscriber) refineSpan(samples []float32, drafted []whisper.Segment) ([]whisper.Segment, error) {
+	first, last := drafted[0], drafted[len(drafted)-1]
+	start := durationToSamples(first.Start)
+	end := durationToSamples(last.End)
+	if end > len(samples) {
+		end = len(samples)
+	}
+	if samplesToDuration(end-start) < minRefineDuration {
+		return drafted, nil
+	}
+
+	refined, err := t.refine.TranscribeSegments(samples[start:end], first.Language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refine audio at %s: %w", first.Start, err)
+	}
+
+	for i := range refined {
+		refined[i].Start += first.Start
+		refined[i].End += first.Start
+		// Keep the spliced segments inside the span they replace
+		if refined[i].End > last.End {
+			refined[i].End = last.End
+		}
+		if refined[i].Start > refined[i].End {
+			refined[i].Start = refined[i].End
+		}
+	}
+
+	refined = mergeShortSegments(refined, minRefineDuration)
+	for _, segment := range refined {
+		// Only possible when everything came back squeezed together
+		if segment.End-segment.Start < minRefineDuration {
+			return drafted, nil
+		}
+	}
+	return refined, nil
+}
+
+// mergeShortSegments merges segments shorter than minDuration into the one
+// before them, or the first one into the one after it
+func mergeShortSegments(segments []whisper.Segment, minDuration time.Duration) []whisper.Segment {
+	var merged []whisper.Segment
+	for _, segment := range segments {
+		n := len(merged)
+		if n > 0 && (segment.End-segment.Start < minDuration || merged[n-1].End-merged[n-1].Start < minDuration) {
+			merged[n-1] = joinSegments(merged[n-1], segment)
+			continue
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// joinSegments joins two consecutive segments into one, its confidence is the
+// mean over the words of both
+func joinSegments(a, b whisper.Segment) whisper.Segment {
+	wordsA, wordsB := max(len(strings.Fields(a.Text)), 1), max(len(strings.Fields(b.Text)), 1)
+	a.Confidence = (a.Confidence*float32(wordsA) + b.Confidence*float32(wordsB)) / float32(wordsA+wordsB)
+	a.End = max(a.End, b.End)
+	if text := strings.TrimSpace(b.Text); text != "" {
+		a.Text = strings.TrimRight(a.Text, " ") + " " + text
+	}
+	a.Words = append(a.Words, b.Words...)
+	return a
+}
@@ -0,0 +1,108 @@
+package transcriber
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoPassTranscriber_TranscribeFromSamples(t *testing.T) {
+	samples := make([]float32, durationToSamples(6*time.Second))
+
+	draft := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			return []whisper.Segment{
+				{Start: 0, End: time.Second, Text: "sure", Confidence: 0.9},
+				{Start: time.Second, End: 2 * time.Second, Text: "unsure", Confidence: 0.3},
+				{Start: 2 * time.Second, End: 4 * time.Second, Text: "unsure too", Confidence: 0.5},
+				{Start: 4 * time.Second, End: 6 * time.Second, Text: "sure again", Confidence: 0.8},
+			}, nil
+		},
+	}
+
+	var refinedLengths []int
+	refine := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			refinedLengths = append(refinedLengths, len(samples))
+			return []whisper.Segment{
+				{Start: 0, End: time.Second, Text: "refined", Confidence: 0.95},
+				{Start: time.Second, End: 4 * time.Second, Text: "refined overrun", Confidence: 0.95},
+			}, nil
+		},
+	}
+
+	transcriber := &TwoPassTranscriber{
+		threshold: DefaultRefineThreshold,
+		draft:     draft,
+		refine:    refine,
+	}
+
+	segments, err := transcriber.TranscribeFromSamples(samples)
+	require.NoError(t, err)
+
+	// Both uncertain segments are re-decoded together
+	assert.Equal(t, []int{durationToSamples(3 * time.Second)}, refinedLengths)
+	assert.Equal(t, []whisper.Segment{
+		{Start: 0, End: time.Second, Text: "sure", Confidence: 0.9},
+		{Start: time.Second, End: 2 * time.Second, Text: "refined", Confidence: 0.95},
+		{Start: 2 * time.Second, End: 4 * time.Second, Text: "refined overrun", Confidence: 0.95},
+		{Start: 4 * time.Second, End: 6 * time.Second, Text: "sure again", Confidence: 0.8},
+	}, segments)
+}
+
+func TestTwoPassTranscriber_ShortSpans(t *testing.T) {
+	samples := make([]float32, durationToSamples(4*time.Second))
+
+	var drafted []whisper.Segment
+	draft := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			return drafted, nil
+		},
+	}
+	var refineCalls int
+	refine := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			refineCalls++
+			return []whisper.Segment{
+				{Start: 0, End: 900 * time.Millisecond, Text: " one", Confidence: 0.9},
+				{Start: 900 * time.Millisecond, End: 950 * time.Millisecond, Text: " two", Confidence: 0.6},
+				{Start: 2 * time.Second, End: 3 * time.Second, Text: " three", Confidence: 0.9},
+			}, nil
+		},
+	}
+	transcriber := &TwoPassTranscriber{
+		threshold: DefaultRefineThreshold,
+		draft:     draft,
+		refine:    refine,
+	}
+
+	t.Run("segments clamped to nothing are merged", func(t *testing.T) {
+		drafted = []whisper.Segment{
+			{Start: 0, End: time.Second, Text: "unsure", Confidence: 0.3},
+			{Start: time.Second, End: 4 * time.Second, Text: "sure", Confidence: 0.9},
+		}
+
+		segments, err := transcriber.TranscribeFromSamples(samples)
+		require.NoError(t, err)
+		assert.Equal(t, []whisper.Segment{
+			{Start: 0, End: time.Second, Text: " one two three", Confidence: 0.8},
+			{Start: time.Second, End: 4 * time.Second, Text: "sure", Confidence: 0.9},
+		}, segments)
+	})
+
+	t.Run("too short span keeps the draft", func(t *testing.T) {
+		refineCalls = 0
+		drafted = []whisper.Segment{
+			{Start: 0, End: 100 * time.Millisecond, Text: "uh", Confidence: 0.3},
+			{Start: 100 * time.Millisecond, End: 4 * time.Second, Text: "sure", Confidence: 0.9},
+		}
+
+		segments, err := transcriber.TranscribeFromSamples(samples)
+		require.NoError(t, err)
+		assert.Equal(t, drafted, segments)
+		assert.Zero(t, refineCalls)
+	})
+}
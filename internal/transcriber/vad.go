@@ -0,0 +1,45 @@
+package transcriber
+
+import (
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+)
+
+// TranscribeSpeech transcribes only the speech found by voice activity
+// detection. The speech regions are packed together and transcribed at once,
+// and the timestamps are mapped back to the original audio.
+func (t *FileTranscriber) TranscribeSpeech(samples []float32, opts audio.VADOptions) ([]whisper.Segment, error) {
+	return t.transcribeSpeech(samples, "", opts)
+}
+
+// transcribeSpeech is TranscribeSpeech in the given language, empty uses the
+// language of the client
+func (t *FileTranscriber) transcribeSpeech(samples []float32, language string, opts audio.VADOptions) ([]whisper.Segment, error) {
+	regions := audio.DetectSpeech(samples, opts)
+	if len(regions) == 0 {
+		// Transcribing silence only produces hallucinations
+		return []whisper.Segment{}, nil
+	}
+
+	packed, timeline := audio.PackSpeech(samples, regions)
+
+	t.mu.Lock()
+	segments, err := t.client.TranscribeSegments(packed, language)
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range segments {
+		segment := &segments[i]
+		segment.Start = timeline.Start(segment.Start)
+		segment.End = timeline.End(segment.End)
+		for j := range segment.Words {
+			word := &segment.Words[j]
+			word.Start = timeline.Start(word.Start)
+			word.End = timeline.End(word.End)
+		}
+	}
+
+	return segments, nil
+}
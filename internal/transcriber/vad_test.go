@@ -0,0 +1,59 @@
+package transcriber
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/piotrjaromin/transcript/internal/audio"
+	"github.com/piotrjaromin/transcript/internal/whisper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTranscriber_TranscribeSpeech(t *testing.T) {
+	// Speech from 2s to 4s and from 10s to 11s of silence
+	samples := make([]float32, durationToSamples(12*time.Second))
+	for _, span := range [][2]time.Duration{{2 * time.Second, 4 * time.Second}, {10 * time.Second, 11 * time.Second}} {
+		for i := durationToSamples(span[0]); i < durationToSamples(span[1]); i++ {
+			samples[i] = float32(0.3 * math.Sin(2*math.Pi*300*float64(i)/audio.SampleRate))
+		}
+	}
+
+	var transcribed int
+	client := &mockWhisperClient{
+		transcribeSegmentsFunc: func(samples []float32, language string) ([]whisper.Segment, error) {
+			transcribed = len(samples)
+			return []whisper.Segment{
+				{Start: 0, End: 2400 * time.Millisecond, Text: "first", Words: []whisper.Word{
+					{Start: 200 * time.Millisecond, End: 2200 * time.Millisecond, Text: "first"},
+				}},
+				{Start: 2400 * time.Millisecond, End: 3800 * time.Millisecond, Text: "second"},
+			}, nil
+		},
+	}
+
+	// Frames which line up with the speech boundaries
+	opts := audio.DefaultVADOptions()
+	opts.FrameDuration = 20 * time.Millisecond
+	transcriber := NewFileTranscriberWithClient(client)
+	segments, err := transcriber.TranscribeSpeech(samples, opts)
+	require.NoError(t, err)
+
+	// Only the padded speech is transcribed
+	assert.Equal(t, durationToSamples(3*time.Second+4*opts.Padding), transcribed)
+	assert.Equal(t, []whisper.Segment{
+		{Start: 1800 * time.Millisecond, End: 4200 * time.Millisecond, Text: "first", Words: []whisper.Word{
+			{Start: 2 * time.Second, End: 4 * time.Second, Text: "first"},
+		}},
+		{Start: 9800 * time.Millisecond, End: 11200 * time.Millisecond, Text: "second"},
+	}, segments)
+
+	t.Run("silence is not transcribed", func(t *testing.T) {
+		transcribed = 0
+		segments, err := transcriber.TranscribeSpeech(make([]float32, audio.SampleRate), opts)
+		require.NoError(t, err)
+		assert.Empty(t, segments)
+		assert.Zero(t, transcribed)
+	})
+}
@@ -4,7 +4,7 @@ package version
 var (
 	// Version is the current version of the application
 	Version = "dev"
-	
+
 	// CommitSHA is the git commit SHA used to build the application
 	CommitSHA = "unknown"
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
 )
@@ -11,15 +12,36 @@ import (
 // Client interface for whisper transcription
 type Client interface {
 	Transcribe(samples []float32) (string, error)
+	// TranscribeSegments decodes audio in the given language, an empty
+	// language uses the language the client was created with
+	TranscribeSegments(samples []float32, language string) ([]Segment, error)
+	DetectLanguage(samples []float32) ([]LanguageProbability, error)
 	Close()
 }
 
+// WordTimer is implemented by clients which time the words of segments only
+// on request, as token timestamps slow decoding down
+type WordTimer interface {
+	SetWordTimestamps(enabled bool)
+}
+
+// SetWordTimestamps enables word timing on the client, when it supports
+// turning it on
+func SetWordTimestamps(client Client, enabled bool) {
+	if timer, ok := client.(WordTimer); ok {
+		timer.SetWordTimestamps(enabled)
+	}
+}
+
 // WhisperClient implements the Client interface
 type WhisperClient struct {
+	mu         sync.Mutex
 	model      whisper.Model
-	context    whisper.Context
+	modelPath  string
 	language   string
 	numThreads int
+	// wordTimestamps adds timed words to the segments
+	wordTimestamps bool
 }
 
 // NewClient creates a new whisper client
@@ -39,34 +61,51 @@ func NewClient(modelPath, language string, numThreads int) (Client, error) {
 		return nil, fmt.Errorf("failed to load model: %w", err)
 	}
 
-	// Create context
-	context, err := model.NewContext()
-	if err != nil {
+	client := &WhisperClient{
+		model:      model,
+		modelPath:  modelPath,
+		language:   language,
+		numThreads: numThreads,
+	}
+
+	// Validate the language up front rather than on the first transcription
+	if _, err := client.newContext(language); err != nil {
 		model.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// newContext creates a decoding context for the given language. A fresh
+// context is used for each run because segment iteration is not reset
+// between Process calls.
+func (c *WhisperClient) newContext(language string) (whisper.Context, error) {
+	context, err := c.model.NewContext()
+	if err != nil {
 		return nil, fmt.Errorf("failed to create context: %w", err)
 	}
 
+	// English-only models need no language setting to transcribe English
+	englishOnly := language == "en" && !c.model.IsMultilingual()
+
 	// Set language if specified
-	if language != "" && language != "auto" {
-		if !model.IsMultilingual() {
-			model.Close()
+	if language != "" && language != "auto" && !englishOnly {
+		if !c.model.IsMultilingual() {
 			return nil, fmt.Errorf("model is not multilingual but language '%s' was specified", language)
 		}
 		if err := context.SetLanguage(language); err != nil {
-			model.Close()
 			return nil, fmt.Errorf("unsupported language '%s' for this model: %v", language, err)
 		}
 	}
 
 	// Set number of threads to use
-	context.SetThreads(uint(numThreads))
+	context.SetThreads(uint(c.numThreads))
 
-	return &WhisperClient{
-		model:      model,
-		context:    context,
-		language:   language,
-		numThreads: numThreads,
-	}, nil
+	// Token timestamps give words their own timing
+	context.SetTokenTimestamps(c.wordTimestamps)
+
+	return context, nil
 }
 
 // Close releases resources
@@ -76,23 +115,133 @@ func (c *WhisperClient) Close() {
 	}
 }
 
+// SetWordTimestamps sets whether segments hold timed words, they are left
+// out by default
+func (c *WhisperClient) SetWordTimestamps(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wordTimestamps = enabled
+}
+
+// DetectLanguage ranks the languages spoken in the audio without transcribing it,
+// using the loaded model
+func (c *WhisperClient) DetectLanguage(samples []float32) ([]LanguageProbability, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	context, err := c.newContext("auto")
+	if err != nil {
+		return nil, err
+	}
+	return detectLanguage(context, samples, c.numThreads)
+}
+
 // Transcribe transcribes audio data
 func (c *WhisperClient) Transcribe(samples []float32) (string, error) {
-	// Process the audio data
-	err := c.context.Process(samples, nil, nil)
+	segments, err := c.TranscribeSegments(samples, "")
 	if err != nil {
-		return "", fmt.Errorf("failed to process audio: %w", err)
+		return "", err
 	}
 
 	// Build the transcript from all segments
-	var segments []string
+	texts := make([]string, len(segments))
+	for i, segment := range segments {
+		texts[i] = segment.Text
+	}
+
+	return strings.Join(texts, " "), nil
+}
+
+// TranscribeSegments transcribes audio data and returns the timed segments
+func (c *WhisperClient) TranscribeSegments(samples []float32, language string) ([]Segment, error) {
+	if language == "" {
+		language = c.language
+	}
+
+	// The model is shared, so concurrent callers (e.g. the router) are serialised
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	context, err := c.newContext(language)
+	if err != nil {
+		return nil, err
+	}
+
+	// Process the audio data
+	if err := context.Process(samples, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to process audio: %w", err)
+	}
+
+	// The language is only known when it was set explicitly
+	if language == "auto" {
+		language = ""
+	}
+
+	var segments []Segment
 	for {
-		segment, err := c.context.NextSegment()
+		segment, err := context.NextSegment()
 		if err != nil {
 			break // End of segments
 		}
-		segments = append(segments, segment.Text)
+		result := Segment{
+			Start:      segment.Start,
+			End:        segment.End,
+			Text:       segment.Text,
+			Language:   language,
+			Confidence: segmentConfidence(context, segment),
+		}
+		if c.wordTimestamps {
+			result.Words = segmentWords(context, segment)
+		}
+		segments = append(segments, result)
 	}
 
-	return strings.Join(segments, " "), nil
+	return segments, nil
+}
+
+// segmentConfidence returns the mean probability of the text tokens in a segment
+func segmentConfidence(context whisper.Context, segment whisper.Segment) float32 {
+	var sum float32
+	var count int
+	for _, token := range segment.Tokens {
+		if !context.IsText(token) {
+			continue
+		}
+		sum += token.P
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return sum / float32(count)
+}
+
+// segmentWords groups the text tokens of a segment into words, a token
+// starting with a space begins a new word
+func segmentWords(context whisper.Context, segment whisper.Segment) []Word {
+	var words []Word
+	var tokens int
+	for _, token := range segment.Tokens {
+		if !context.IsText(token) {
+			continue
+		}
+
+		if len(words) == 0 || strings.HasPrefix(token.Text, " ") {
+			words = append(words, Word{Start: token.Start})
+			tokens = 0
+		}
+
+		// Keep a running mean of the token probabilities
+		word := &words[len(words)-1]
+		word.Text += token.Text
+		word.End = token.End
+		tokens++
+		word.Confidence += (token.P - word.Confidence) / float32(tokens)
+	}
+
+	for i := range words {
+		words[i].Text = strings.TrimSpace(words[i].Text)
+	}
+	return words
 }
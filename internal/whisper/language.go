@@ -0,0 +1,87 @@
+package whisper
+
+import (
+	"fmt"
+	"sort"
+
+	whispercpp "github.com/ggerganov/whisper.cpp/bindings/go"
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// LanguageProbability is the likelihood that the audio is spoken in a given language
+type LanguageProbability struct {
+	Language    string  `json:"language"`
+	Probability float32 `json:"probability"`
+}
+
+// languageDetector is implemented by contexts which can rank the languages of
+// the spectrogram last computed on their model
+type languageDetector interface {
+	WhisperLangAutoDetect(offsetMs int, threads int) ([]float32, error)
+}
+
+// detectLanguage ranks the languages of the audio on a context of the loaded
+// model. Whisper only looks at the first 30 seconds of the provided samples.
+func detectLanguage(context whisper.Context, samples []float32, numThreads int) ([]LanguageProbability, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no audio data to analyse")
+	}
+
+	// English-only models have no language tokens to rank
+	if !context.IsMultilingual() {
+		return nil, fmt.Errorf("model is not multilingual, language detection is not supported")
+	}
+	detector, ok := context.(languageDetector)
+	if !ok {
+		return nil, fmt.Errorf("language detection is not supported by the whisper bindings")
+	}
+
+	// Processing computes the spectrogram the detection runs on, decoding
+	// stops after the first token
+	context.SetMaxTokensPerSegment(1)
+	if len(samples) > detectionSamples {
+		samples = samples[:detectionSamples]
+	}
+	if err := context.Process(samples, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to compute spectrogram: %w", err)
+	}
+
+	probs, err := detector.WhisperLangAutoDetect(0, numThreads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect language: %w", err)
+	}
+
+	ranked := rankLanguages(probs)
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("failed to detect language: no language probabilities returned")
+	}
+
+	return ranked, nil
+}
+
+// rankLanguages maps whisper language ids to codes and sorts them by probability
+func rankLanguages(probs []float32) []LanguageProbability {
+	ranked := make([]LanguageProbability, 0, len(probs))
+	for id, p := range probs {
+		lang := whispercpp.Whisper_lang_str(id)
+		if lang == "" {
+			continue
+		}
+		ranked = append(ranked, LanguageProbability{Language: lang, Probability: p})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Probability > ranked[j].Probability
+	})
+
+	return ranked
+}
+
+// TopLanguages returns the top most likely of the ranked languages, all of
+// them when top is zero
+func TopLanguages(languages []LanguageProbability, top int) []LanguageProbability {
+	if top > 0 && top < len(languages) {
+		return languages[:top]
+	}
+	return languages
+}
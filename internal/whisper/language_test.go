@@ -0,0 +1,45 @@
+package whisper
+
+import (
+	"testing"
+
+	whispercpp "github.com/ggerganov/whisper.cpp/bindings/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankLanguages(t *testing.T) {
+	probs := make([]float32, whispercpp.Whisper_lang_max_id()+2)
+	probs[0] = 0.2
+	probs[1] = 0.7
+	probs[2] = 0.1
+	// Ids past the last language have no code
+	probs[len(probs)-1] = 0.9
+
+	ranked := rankLanguages(probs)
+	assert.Len(t, ranked, whispercpp.Whisper_lang_max_id()+1)
+	assert.Equal(t, []LanguageProbability{
+		{Language: whispercpp.Whisper_lang_str(1), Probability: 0.7},
+		{Language: whispercpp.Whisper_lang_str(0), Probability: 0.2},
+		{Language: whispercpp.Whisper_lang_str(2), Probability: 0.1},
+	}, ranked[:3])
+
+	t.Run("equal probabilities keep the id order", func(t *testing.T) {
+		ranked := rankLanguages([]float32{0.5, 0.5})
+		assert.Equal(t, []string{whispercpp.Whisper_lang_str(0), whispercpp.Whisper_lang_str(1)},
+			[]string{ranked[0].Language, ranked[1].Language})
+	})
+}
+
+func TestTopLanguages(t *testing.T) {
+	languages := []LanguageProbability{
+		{Language: "en", Probability: 0.6},
+		{Language: "de", Probability: 0.3},
+		{Language: "pl", Probability: 0.1},
+	}
+
+	assert.Equal(t, languages[:2], TopLanguages(languages, 2))
+	assert.Equal(t, languages, TopLanguages(languages, 3))
+	assert.Equal(t, languages, TopLanguages(languages, 5))
+	// Zero returns all of them
+	assert.Equal(t, languages, TopLanguages(languages, 0))
+}
@@ -0,0 +1,173 @@
+package whisper
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// detectionSamples is how much audio the language is detected from, whisper
+// only looks at a single 30 second window
+const detectionSamples = 30 * whisper.SampleRate
+
+// Router detects the spoken language with a small multilingual model and
+// transcribes with the model configured for that language. Languages without
+// a configured model are transcribed by the detection model itself.
+type Router struct {
+	detector   Client
+	routes     map[string]string
+	numThreads int
+
+	mu             sync.Mutex
+	clients        map[string]Client
+	newClient      func(modelPath, language string, threads int) (Client, error)
+	wordTimestamps bool
+}
+
+// NewRouter creates a new language based router. Routes map language codes
+// (e.g. "en") to model paths which are loaded on first use.
+func NewRouter(detectModelPath string, routes map[string]string, numThreads int) (*Router, error) {
+	for lang, path := range routes {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, fmt.Errorf("model file for language '%s' not found: %s", lang, path)
+		}
+	}
+
+	detector, err := NewClient(detectModelPath, "auto", numThreads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create detection client: %w", err)
+	}
+
+	return &Router{
+		detector:   detector,
+		routes:     routes,
+		numThreads: numThreads,
+		clients:    make(map[string]Client),
+		newClient:  NewClient,
+	}, nil
+}
+
+// CheckRouting validates the language of a run with the given routes. Routing
+// detects the language, so a fixed language cannot be combined with it.
+func CheckRouting(language string, routes map[string]string) error {
+	if len(routes) > 0 && language != "" && language != "auto" {
+		return fmt.Errorf("invalid language '%s': routing detects the language, it cannot be combined with a fixed language", language)
+	}
+	return nil
+}
+
+// Close releases the detection model and every loaded per-language model
+func (r *Router) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, client := range r.clients {
+		client.Close()
+	}
+	r.clients = make(map[string]Client)
+	r.detector.Close()
+}
+
+// DetectLanguage ranks the spoken languages using the detection model
+func (r *Router) DetectLanguage(samples []float32) ([]LanguageProbability, error) {
+	return r.detector.DetectLanguage(samples)
+}
+
+// Transcribe transcribes audio data with the model routed for its language
+func (r *Router) Transcribe(samples []float32) (string, error) {
+	transcript, _, err := r.TranscribeWithLanguage(samples)
+	return transcript, err
+}
+
+// SetWordTimestamps sets whether segments hold timed words, on every model
+// loaded now or later
+func (r *Router) SetWordTimestamps(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wordTimestamps = enabled
+	SetWordTimestamps(r.detector, enabled)
+	for _, client := range r.clients {
+		SetWordTimestamps(client, enabled)
+	}
+}
+
+// TranscribeWithLanguage transcribes audio data and also returns the detected
+// language which was used to choose the model
+func (r *Router) TranscribeWithLanguage(samples []float32) (string, string, error) {
+	language, err := r.detect(samples)
+	if err != nil {
+		return "", "", err
+	}
+
+	client, err := r.clientFor(language)
+	if err != nil {
+		return "", "", err
+	}
+
+	transcript, err := client.Transcribe(samples)
+	if err != nil {
+		return "", "", err
+	}
+
+	return transcript, language, nil
+}
+
+// TranscribeSegments transcribes audio data with the model routed for the
+// given language, the language is detected when it is empty
+func (r *Router) TranscribeSegments(samples []float32, language string) ([]Segment, error) {
+	if language == "" || language == "auto" {
+		detected, err := r.detect(samples)
+		if err != nil {
+			return nil, err
+		}
+		language = detected
+	}
+
+	client, err := r.clientFor(language)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.TranscribeSegments(samples, language)
+}
+
+// detect returns the most likely language of the beginning of the audio
+func (r *Router) detect(samples []float32) (string, error) {
+	if len(samples) > detectionSamples {
+		samples = samples[:detectionSamples]
+	}
+
+	languages, err := r.detector.DetectLanguage(samples)
+	if err != nil {
+		return "", err
+	}
+
+	return languages[0].Language, nil
+}
+
+// clientFor returns the client for a language, loading its model if needed
+func (r *Router) clientFor(language string) (Client, error) {
+	modelPath, ok := r.routes[language]
+	if !ok {
+		return r.detector, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.clients[language]; ok {
+		return client, nil
+	}
+
+	client, err := r.newClient(modelPath, language, r.numThreads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model for language '%s': %w", language, err)
+	}
+	SetWordTimestamps(client, r.wordTimestamps)
+	r.clients[language] = client
+
+	return client, nil
+}
@@ -0,0 +1,123 @@
+package whisper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_TranscribeWithLanguage(t *testing.T) {
+	detector := &mockClient{
+		transcript: "detector transcript",
+		languages:  []LanguageProbability{{Language: "pl", Probability: 0.9}},
+	}
+	english := &mockClient{transcript: "english transcript"}
+
+	var loaded []string
+	router := &Router{
+		detector: detector,
+		routes:   map[string]string{"en": "en-model.bin"},
+		clients:  make(map[string]Client),
+		newClient: func(modelPath, language string, threads int) (Client, error) {
+			loaded = append(loaded, modelPath)
+			return english, nil
+		},
+	}
+
+	t.Run("unrouted language uses detection model", func(t *testing.T) {
+		transcript, language, err := router.TranscribeWithLanguage([]float32{0.1})
+		require.NoError(t, err)
+		assert.Equal(t, "pl", language)
+		assert.Equal(t, "detector transcript", transcript)
+		assert.Empty(t, loaded)
+	})
+
+	t.Run("routed language uses configured model", func(t *testing.T) {
+		detector.languages = []LanguageProbability{{Language: "en", Probability: 0.8}}
+
+		for i := 0; i < 2; i++ {
+			transcript, language, err := router.TranscribeWithLanguage([]float32{0.1})
+			require.NoError(t, err)
+			assert.Equal(t, "en", language)
+			assert.Equal(t, "english transcript", transcript)
+		}
+
+		// The model is loaded once and reused
+		assert.Equal(t, []string{"en-model.bin"}, loaded)
+	})
+
+	t.Run("segments are decoded in the routed language", func(t *testing.T) {
+		segments, err := router.TranscribeSegments([]float32{0.1}, "pl")
+		require.NoError(t, err)
+		assert.Equal(t, []Segment{{Text: "detector transcript", Language: "pl"}}, segments)
+	})
+
+	t.Run("detection only sees the first window", func(t *testing.T) {
+		router.Transcribe(make([]float32, detectionSamples*2))
+		assert.Len(t, detector.detected, detectionSamples)
+	})
+}
+
+type mockClient struct {
+	transcript     string
+	languages      []LanguageProbability
+	detected       []float32
+	closed         bool
+	wordTimestamps bool
+}
+
+func (m *mockClient) Transcribe(samples []float32) (string, error) {
+	return m.transcript, nil
+}
+
+func (m *mockClient) TranscribeSegments(samples []float32, language string) ([]Segment, error) {
+	return []Segment{{Text: m.transcript, Language: language}}, nil
+}
+
+func (m *mockClient) DetectLanguage(samples []float32) ([]LanguageProbability, error) {
+	m.detected = samples
+	return m.languages, nil
+}
+
+func (m *mockClient) SetWordTimestamps(enabled bool) {
+	m.wordTimestamps = enabled
+}
+
+func (m *mockClient) Close() {
+	m.closed = true
+}
+
+func TestRouter_SetWordTimestamps(t *testing.T) {
+	detector := &mockClient{languages: []LanguageProbability{{Language: "en", Probability: 0.8}}}
+	english := &mockClient{}
+	router := &Router{
+		detector: detector,
+		routes:   map[string]string{"en": "en-model.bin"},
+		clients:  make(map[string]Client),
+		newClient: func(modelPath, language string, threads int) (Client, error) {
+			return english, nil
+		},
+	}
+
+	router.SetWordTimestamps(true)
+	assert.True(t, detector.wordTimestamps)
+
+	// Models loaded later time words too
+	_, err := router.TranscribeSegments([]float32{0.1}, "")
+	require.NoError(t, err)
+	assert.True(t, english.wordTimestamps)
+
+	router.SetWordTimestamps(false)
+	assert.False(t, detector.wordTimestamps)
+	assert.False(t, english.wordTimestamps)
+}
+
+func TestCheckRouting(t *testing.T) {
+	routes := map[string]string{"en": "en-model.bin"}
+
+	assert.NoError(t, CheckRouting("auto", routes))
+	assert.NoError(t, CheckRouting("", routes))
+	assert.NoError(t, CheckRouting("pl", nil))
+	assert.Error(t, CheckRouting("pl", routes))
+}
@@ -0,0 +1,80 @@
+package whisper
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Segment is a timed piece of a transcript
+type Segment struct {
+	Start    time.Duration
+	End      time.Duration
+	Text     string
+	Language string
+	// Speaker labels the channel the segment was transcribed from, if split
+	Speaker string
+	// Confidence is the mean probability of the text tokens, between 0 and 1
+	Confidence float32
+	Words      []Word
+}
+
+// Word is a single timed word of a segment
+type Word struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+	// Confidence is the mean probability of the word's tokens, between 0 and 1
+	Confidence float32
+}
+
+// segmentJSON is the wire format of a segment with timestamps in seconds
+type segmentJSON struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Language   string  `json:"language,omitempty"`
+	Speaker    string  `json:"speaker,omitempty"`
+	Confidence float32 `json:"confidence"`
+	Words      []Word  `json:"words,omitempty"`
+}
+
+// MarshalJSON encodes the segment with timestamps in seconds
+func (s Segment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(segmentJSON{
+		Start:      s.Start.Seconds(),
+		End:        s.End.Seconds(),
+		Text:       s.Text,
+		Language:   s.Language,
+		Speaker:    s.Speaker,
+		Confidence: s.Confidence,
+		Words:      s.Words,
+	})
+}
+
+// wordJSON is the wire format of a word with timestamps in seconds
+type wordJSON struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float32 `json:"confidence"`
+}
+
+// MarshalJSON encodes the word with timestamps in seconds
+func (w Word) MarshalJSON() ([]byte, error) {
+	return json.Marshal(wordJSON{
+		Start:      w.Start.Seconds(),
+		End:        w.End.Seconds(),
+		Text:       w.Text,
+		Confidence: w.Confidence,
+	})
+}
+
+// JoinSegments builds the plain transcript text from segments
+func JoinSegments(segments []Segment) string {
+	texts := make([]string, len(segments))
+	for i, segment := range segments {
+		texts[i] = strings.TrimSpace(segment.Text)
+	}
+	return strings.Join(texts, " ")
+}